@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"strings"
 )
 
@@ -32,8 +34,8 @@ services:
     # What you see below are defaults mixed with examples where examples make more sense than the default.
     # You only need to modify things specific to your environment.
     # Remove apps and feature configs you do not use or need.
-    # ie. Remove all lines that begin with UN_CMDHOOK, UN_WEBHOOK,
-    #     UN_FOLDER, UN_WEBSERVER, and other apps you do not use.
+    # ie. Remove all lines that begin with UN_CMDHOOK, UN_WEBHOOK, UN_EMAIL, UN_MQTT,
+    #     UN_TRACING, UN_FOLDER, UN_WEBSERVER, and other apps you do not use.
     environment:
     - TZ=${TZ}`
 )
@@ -116,6 +118,15 @@ func (p *Param) Compose(prefix string) string {
 			fmt.Fprint(&out, prefix, p.EnvVar, idx, "=", sv, "\n")
 		}
 
+		return out.String()
+	case mapKind:
+		var out strings.Builder
+
+		vals, _ := val.(map[string]any)
+		for _, key := range slices.Sorted(maps.Keys(vals)) {
+			fmt.Fprint(&out, prefix, p.EnvVar, key, "=", vals[key], "\n")
+		}
+
 		return out.String()
 	case "conlist":
 		var out strings.Builder
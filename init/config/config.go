@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/BurntSushi/toml"
@@ -89,6 +91,10 @@ func (h *Header) makeSection(name section, showHeader, showValue bool) string {
 }
 
 func (p *Param) Value() string {
+	if p.Kind == mapKind {
+		return p.inlineTable()
+	}
+
 	// If example is not empty, use that commented out, otherwise use the default.
 	out, _ := toml.Marshal(p.Default)
 	if p.Example != nil {
@@ -103,6 +109,25 @@ func (p *Param) Value() string {
 	return string(out)
 }
 
+// inlineTable formats a map param as a toml inline table. The toml marshaller only makes sub-tables.
+func (p *Param) inlineTable() string {
+	vals, _ := p.Default.(map[string]any)
+	if p.Example != nil {
+		vals, _ = p.Example.(map[string]any)
+	}
+
+	pairs := make([]string, 0, len(vals))
+	for _, key := range slices.Sorted(maps.Keys(vals)) {
+		pairs = append(pairs, fmt.Sprintf("%q = %q", key, fmt.Sprint(vals[key])))
+	}
+
+	if len(pairs) == 0 {
+		return "{}"
+	}
+
+	return "{ " + strings.Join(pairs, ", ") + " }"
+}
+
 // makeDefinedSection duplicates sections from overrides, and prints it once for each override.
 func (h *Header) makeDefinedSection(defs Defs, order []section, showValue bool) string {
 	var buf bytes.Buffer
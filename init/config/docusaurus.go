@@ -128,6 +128,8 @@ func (h *Header) makeDocsTable(prefix string) string {
 		envVar := prefix + h.Prefix + hSuffix + param.EnvVar
 		if param.Kind == list {
 			envVar += "0"
+		} else if param.Kind == mapKind {
+			envVar += "name"
 		}
 
 		def := "No Default"
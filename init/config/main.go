@@ -21,6 +21,7 @@ import (
 
 const (
 	list           = "list"
+	mapKind        = "map"
 	dirMode        = 0o755
 	fileMode       = 0o644
 	outputDir      = "generated/"
@@ -67,7 +68,7 @@ type Param struct {
 	Example   any      `yaml:"example"`
 	Short     string   `yaml:"short"`
 	Desc      string   `yaml:"desc"`
-	Kind      string   `yaml:"kind"` // "", list, conlist
+	Kind      string   `yaml:"kind"` // "", list, conlist, map
 	Recommend []Option `yaml:"recommend"`
 	Apps      []string `yaml:"apps"` // If set, param only appears for these starr app names (e.g. lidarr).
 }
@@ -46,37 +46,48 @@ var (
 //
 //nolint:lll
 type Config struct {
-	Debug       bool             `json:"debug"              toml:"debug"         xml:"debug"         yaml:"debug"`
-	Quiet       bool             `json:"quiet"              toml:"quiet"         xml:"quiet"         yaml:"quiet"`
-	Activity    bool             `json:"activity"           toml:"activity"      xml:"activity"      yaml:"activity"`
-	Parallel    uint             `json:"parallel"           toml:"parallel"      xml:"parallel"      yaml:"parallel"`
-	ErrorStdErr bool             `json:"errorStderr"        toml:"error_stderr"  xml:"error_stderr"  yaml:"errorStderr"`
-	LogFile     string           `json:"logFile"            toml:"log_file"      xml:"log_file"      yaml:"logFile"`
-	LogFiles    int              `json:"logFiles"           toml:"log_files"     xml:"log_files"     yaml:"logFiles"`
-	LogFileMb   int              `json:"logFileMb"          toml:"log_file_mb"   xml:"log_file_mb"   yaml:"logFileMb"`
-	LogFileMode string           `json:"logFileMode"        toml:"log_file_mode" xml:"log_file_mode" yaml:"logFileMode"`
-	MaxRetries  uint             `json:"maxRetries"         toml:"max_retries"   xml:"max_retries"   yaml:"maxRetries"`
-	FileMode    string           `json:"fileMode"           toml:"file_mode"     xml:"file_mode"     yaml:"fileMode"`
-	DirMode     string           `json:"dirMode"            toml:"dir_mode"      xml:"dir_mode"      yaml:"dirMode"`
-	LogQueues   cnfg.Duration    `json:"logQueues"          toml:"log_queues"    xml:"log_queues"    yaml:"logQueues"`
-	Interval    cnfg.Duration    `json:"interval"           toml:"interval"      xml:"interval"      yaml:"interval"`
-	Timeout     cnfg.Duration    `json:"timeout"            toml:"timeout"       xml:"timeout"       yaml:"timeout"`
-	DeleteDelay cnfg.Duration    `json:"deleteDelay"        toml:"delete_delay"  xml:"delete_delay"  yaml:"deleteDelay"`
-	StartDelay  cnfg.Duration    `json:"startDelay"         toml:"start_delay"   xml:"start_delay"   yaml:"startDelay"`
-	RetryDelay  cnfg.Duration    `json:"retryDelay"         toml:"retry_delay"   xml:"retry_delay"   yaml:"retryDelay"`
-	Progress    cnfg.Duration    `json:"progress"           toml:"progress"      xml:"progress"      yaml:"progress"`
-	KeepHistory uint             `json:"keepHistory"        toml:"keep_history"  xml:"keep_history"  yaml:"keepHistory"` // undocumented.
-	Passwords   StringSlice      `json:"passwords"          toml:"passwords"     xml:"password"      yaml:"passwords"`
-	Webserver   *WebServer       `json:"webserver"          toml:"webserver"     xml:"webserver"     yaml:"webserver"`
-	Lidarr      []*LidarrConfig  `json:"lidarr,omitempty"   toml:"lidarr"        xml:"lidarr"        yaml:"lidarr,omitempty"`
-	Radarr      []*RadarrConfig  `json:"radarr,omitempty"   toml:"radarr"        xml:"radarr"        yaml:"radarr,omitempty"`
-	Whisparr    []*RadarrConfig  `json:"whisparr,omitempty" toml:"whisparr"      xml:"whisparr"      yaml:"whisparr,omitempty"`
-	Readarr     []*ReadarrConfig `json:"readarr,omitempty"  toml:"readarr"       xml:"readarr"       yaml:"readarr,omitempty"`
-	Sonarr      []*SonarrConfig  `json:"sonarr,omitempty"   toml:"sonarr"        xml:"sonarr"        yaml:"sonarr,omitempty"`
-	Folders     []*FolderConfig  `json:"folder,omitempty"   toml:"folder"        xml:"folder"        yaml:"folder,omitempty"`
-	Webhook     []*WebhookConfig `json:"webhook,omitempty"  toml:"webhook"       xml:"webhook"       yaml:"webhook,omitempty"`
-	Cmdhook     []*WebhookConfig `json:"cmdhook,omitempty"  toml:"cmdhook"       xml:"cmdhook"       yaml:"cmdhook,omitempty"`
-	Folder      FoldersConfig    `json:"folders"            toml:"folders"       xml:"folders"       yaml:"folders"` // undocumented.
+	Debug            bool             `json:"debug"              toml:"debug"             xml:"debug"             yaml:"debug"`
+	Quiet            bool             `json:"quiet"              toml:"quiet"             xml:"quiet"             yaml:"quiet"`
+	Activity         bool             `json:"activity"           toml:"activity"          xml:"activity"          yaml:"activity"`
+	Parallel         uint             `json:"parallel"           toml:"parallel"          xml:"parallel"          yaml:"parallel"`
+	ErrorStdErr      bool             `json:"errorStderr"        toml:"error_stderr"      xml:"error_stderr"      yaml:"errorStderr"`
+	LogFile          string           `json:"logFile"            toml:"log_file"          xml:"log_file"          yaml:"logFile"`
+	LogFiles         int              `json:"logFiles"           toml:"log_files"         xml:"log_files"         yaml:"logFiles"`
+	LogFileMb        int              `json:"logFileMb"          toml:"log_file_mb"       xml:"log_file_mb"       yaml:"logFileMb"`
+	LogFileMode      string           `json:"logFileMode"        toml:"log_file_mode"     xml:"log_file_mode"     yaml:"logFileMode"`
+	LogFormat        string           `json:"logFormat"          toml:"log_format"        xml:"log_format"        yaml:"logFormat"`
+	LogTarget        string           `json:"logTarget"          toml:"log_target"        xml:"log_target"        yaml:"logTarget"`
+	MaxRetries       uint             `json:"maxRetries"         toml:"max_retries"       xml:"max_retries"       yaml:"maxRetries"`
+	FileMode         string           `json:"fileMode"           toml:"file_mode"         xml:"file_mode"         yaml:"fileMode"`
+	DirMode          string           `json:"dirMode"            toml:"dir_mode"          xml:"dir_mode"          yaml:"dirMode"`
+	LogQueues        cnfg.Duration    `json:"logQueues"          toml:"log_queues"        xml:"log_queues"        yaml:"logQueues"`
+	Interval         cnfg.Duration    `json:"interval"           toml:"interval"          xml:"interval"          yaml:"interval"`
+	Timeout          cnfg.Duration    `json:"timeout"            toml:"timeout"           xml:"timeout"           yaml:"timeout"`
+	DeleteDelay      cnfg.Duration    `json:"deleteDelay"        toml:"delete_delay"      xml:"delete_delay"      yaml:"deleteDelay"`
+	StartDelay       cnfg.Duration    `json:"startDelay"         toml:"start_delay"       xml:"start_delay"       yaml:"startDelay"`
+	RetryDelay       cnfg.Duration    `json:"retryDelay"         toml:"retry_delay"       xml:"retry_delay"       yaml:"retryDelay"`
+	Progress         cnfg.Duration    `json:"progress"           toml:"progress"          xml:"progress"          yaml:"progress"`
+	KeepHistory      uint             `json:"keepHistory"        toml:"keep_history"      xml:"keep_history"      yaml:"keepHistory"` // undocumented.
+	HistoryFile      string           `json:"historyFile"        toml:"history_file"      xml:"history_file"      yaml:"historyFile"`
+	HistoryMax       uint             `json:"historyMax"         toml:"history_max"       xml:"history_max"       yaml:"historyMax"`
+	HistoryRetention cnfg.Duration    `json:"historyRetention"   toml:"history_retention" xml:"history_retention" yaml:"historyRetention"`
+	Passwords        StringSlice      `json:"passwords"          toml:"passwords"         xml:"password"          yaml:"passwords"`
+	PasswordSidecars StringSlice      `json:"passwordSidecars"   toml:"password_sidecars" xml:"password_sidecars" yaml:"passwordSidecars"`
+	Webserver        *WebServer       `json:"webserver"          toml:"webserver"         xml:"webserver"         yaml:"webserver"`
+	Lidarr           []*LidarrConfig  `json:"lidarr,omitempty"   toml:"lidarr"            xml:"lidarr"            yaml:"lidarr,omitempty"`
+	Radarr           []*RadarrConfig  `json:"radarr,omitempty"   toml:"radarr"            xml:"radarr"            yaml:"radarr,omitempty"`
+	Whisparr         []*RadarrConfig  `json:"whisparr,omitempty" toml:"whisparr"          xml:"whisparr"          yaml:"whisparr,omitempty"`
+	Readarr          []*ReadarrConfig `json:"readarr,omitempty"  toml:"readarr"           xml:"readarr"           yaml:"readarr,omitempty"`
+	Sonarr           []*SonarrConfig  `json:"sonarr,omitempty"   toml:"sonarr"            xml:"sonarr"            yaml:"sonarr,omitempty"`
+	Folders          []*FolderConfig  `json:"folder,omitempty"   toml:"folder"            xml:"folder"            yaml:"folder,omitempty"`
+	Webhook          []*WebhookConfig `json:"webhook,omitempty"  toml:"webhook"           xml:"webhook"           yaml:"webhook,omitempty"`
+	Cmdhook          []*WebhookConfig `json:"cmdhook,omitempty"  toml:"cmdhook"           xml:"cmdhook"           yaml:"cmdhook,omitempty"`
+	MQTT             *MQTTConfig      `json:"mqtt"               toml:"mqtt"              xml:"mqtt"              yaml:"mqtt"`
+	Tracing          *TracingConfig   `json:"tracing"            toml:"tracing"           xml:"tracing"           yaml:"tracing"`
+	Report           *ReportConfig    `json:"report"             toml:"report"            xml:"report"            yaml:"report"`
+	Email            []*EmailConfig   `json:"email,omitempty"    toml:"email"             xml:"email"             yaml:"email,omitempty"`
+	Folder           FoldersConfig    `json:"folders"            toml:"folders"           xml:"folders"           yaml:"folders"` // undocumented.
+	passwords        *passwordList
 }
 
 type FoldersConfig struct {
@@ -150,6 +161,10 @@ func (u *Unpackerr) validateApps() error {
 	for _, validate := range []func() error{
 		u.validateCmdhook,
 		u.validateWebhook,
+		u.validateEmail,
+		u.validateMQTT,
+		u.validateTracing,
+		u.validateReport,
 	} {
 		if err := validate(); err != nil {
 			u.Errorf("Config Warning: %v", err)
@@ -159,6 +174,33 @@ func (u *Unpackerr) validateApps() error {
 	return nil
 }
 
+// starrConfigs returns the shared config for every configured starr app.
+func (u *Unpackerr) starrConfigs() []*StarrConfig {
+	configs := []*StarrConfig{}
+
+	for _, app := range u.Lidarr {
+		configs = append(configs, &app.StarrConfig)
+	}
+
+	for _, app := range u.Radarr {
+		configs = append(configs, &app.StarrConfig)
+	}
+
+	for _, app := range u.Readarr {
+		configs = append(configs, &app.StarrConfig)
+	}
+
+	for _, app := range u.Sonarr {
+		configs = append(configs, &app.StarrConfig)
+	}
+
+	for _, app := range u.Whisparr {
+		configs = append(configs, &app.StarrConfig)
+	}
+
+	return configs
+}
+
 func (u *Unpackerr) haveQitem(name string, app starr.App) bool {
 	switch app {
 	case starr.Lidarr:
@@ -0,0 +1,189 @@
+package unpackerr
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golift.io/starr"
+	"golift.io/xtractr"
+)
+
+// atomicMoveCallback returns an xtractr callback that moves finished extractions from
+// the staging folder into the download path. Each file is renamed into place only
+// after every archive is extracted, so a Starr app never sees a partially written file.
+// This runs in the extraction go routine before the response reaches the main routine.
+func (u *Unpackerr) atomicMoveCallback(path string) func(*xtractr.Response) {
+	return func(resp *xtractr.Response) {
+		switch {
+		case !resp.Done:
+			return
+		case resp.Output == "" || filepath.Clean(resp.Output) == filepath.Clean(path):
+			return // Nothing was staged; should not happen.
+		case resp.Error != nil:
+			// Do not leave partial extractions in the staging folder.
+			u.Xtractr.DeleteFiles(resp.Output)
+			return
+		}
+
+		newFiles, err := u.moveStagedFiles(resp.Output, path)
+		if err != nil {
+			// Keep the staging folder, it may still contain files that did not move.
+			resp.Error = fmt.Errorf("moving staged files into place: %w", err)
+		} else {
+			u.debugf(logXtractr, "Moved %d staged files: %s -> %s", len(newFiles), resp.Output, path)
+			u.Xtractr.DeleteFiles(resp.Output)
+			resp.Output = path
+		}
+
+		resp.NewFiles = newFiles
+	}
+}
+
+// moveStagedFiles renames every file in the staging folder into the same relative location in the
+// destination folder. Existing files are replaced. Returns the list of files now in the destination.
+func (u *Unpackerr) moveStagedFiles(staging, dest string) ([]string, error) {
+	newFiles := []string{}
+
+	err := filepath.WalkDir(staging, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return fmt.Errorf("finding relative path: %w", err)
+		}
+
+		newFile := filepath.Join(dest, rel)
+		if err := os.MkdirAll(filepath.Dir(newFile), u.dirMode()); err != nil {
+			return fmt.Errorf("making folder: %w", err)
+		}
+
+		if err := moveFile(path, newFile); err != nil {
+			return err
+		}
+
+		newFiles = append(newFiles, newFile)
+
+		return nil
+	})
+	if err != nil {
+		return newFiles, fmt.Errorf("walking staging folder: %w", err)
+	}
+
+	return newFiles, nil
+}
+
+// moveFile renames a file into place. When that fails, like across file systems, the file is copied instead.
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	return copyIntoPlace(src, dest)
+}
+
+// copyIntoPlace copies a file to a temporary name beside the destination, and renames it into place
+// only once it's complete, so the destination never holds a partial file. The source is removed.
+func copyIntoPlace(src, dest string) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("opening staged file: %w", err)
+	}
+	defer source.Close()
+
+	stat, err := source.Stat()
+	if err != nil {
+		return fmt.Errorf("reading staged file: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".unpackerr-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	if err = copyFile(temp, source, stat.Mode()); err == nil {
+		err = os.Rename(temp.Name(), dest)
+	}
+
+	if err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("copying staged file into place: %w", err)
+	}
+
+	source.Close()
+
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("removing staged file: %w", err)
+	}
+
+	return nil
+}
+
+// copyFile copies a file's data into a temporary file, and closes the temporary file.
+func copyFile(temp *os.File, source io.Reader, mode os.FileMode) error {
+	_, err := io.Copy(temp, source)
+	if err == nil {
+		err = temp.Sync()
+	}
+
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(temp.Name(), mode)
+	}
+
+	return err //nolint:wrapcheck // The caller wraps it.
+}
+
+// checkStagingFileSystem warns when a staging folder is not on the same file system as the app's paths.
+// Staged files are copied into place then, which is slower than a rename.
+func (u *Unpackerr) checkStagingFileSystem(conf *StarrConfig, app starr.App) {
+	staging, err := fileSystemID(existingParent(conf.ExtractPath))
+	if err != nil {
+		return
+	}
+
+	for _, path := range conf.Paths {
+		if id, err := fileSystemID(existingParent(path)); err == nil && id != staging {
+			u.Printf("[%s] WARNING: extract_path %s is not on the same file system as %s; "+
+				"staged files are copied into place instead of renamed", app, conf.ExtractPath, path)
+		}
+	}
+}
+
+// existingParent returns the path, or its nearest parent folder that exists.
+func existingParent(path string) string {
+	for path = filepath.Clean(path); ; path = filepath.Dir(path) {
+		if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+			return path
+		}
+	}
+}
+
+// dirMode returns the configured mode for new folders.
+func (u *Unpackerr) dirMode() os.FileMode {
+	dirMode, err := strconv.ParseUint(u.DirMode, bits8, base32)
+	if err != nil {
+		return defaultDirMode
+	}
+
+	return os.FileMode(dirMode)
+}
+
+// chainCallbacks returns an xtractr callback that runs each provided callback in order.
+func chainCallbacks(callbacks ...func(*xtractr.Response)) func(*xtractr.Response) {
+	return func(resp *xtractr.Response) {
+		for _, callback := range callbacks {
+			if callback != nil {
+				callback(resp)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package unpackerr
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golift.io/xtractr"
+)
+
+func TestAtomicMoveCallback(t *testing.T) {
+	t.Parallel()
+
+	download := filepath.Join(t.TempDir(), "Some.Show.S01E01")
+	staging := t.TempDir()
+
+	zipBytes, err := buildZip(map[string][]byte{
+		"episode.mkv":  []byte("video"),
+		"Subs/eng.srt": []byte("subtitles"),
+	})
+	if err != nil {
+		t.Fatalf("building zip fixture: %v", err)
+	}
+
+	// The Subs folder already exists in the download, so the move must merge into it.
+	if err := os.MkdirAll(filepath.Join(download, "Subs"), defaultDirMode); err != nil {
+		t.Fatalf("making download folder: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(download, "episode.zip"), zipBytes, defaultFileMode); err != nil {
+		t.Fatalf("writing zip fixture: %v", err)
+	}
+
+	queue := xtractr.NewQueue(&xtractr.Config{Parallel: 1, Suffix: suffix, FileMode: defaultFileMode, DirMode: defaultDirMode})
+	t.Cleanup(func() { queue.Stop() })
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config:  &Config{DirMode: "755"},
+		Xtractr: queue,
+		Logger:  &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+	callbacks := make(chan *xtractr.Response, updateChanBuf)
+
+	_, err = queue.Extract(&xtractr.Xtract{
+		Name:       "Some.Show.S01E01",
+		Filter:     xtractr.Filter{Path: download},
+		TempFolder: true,
+		ExtractTo:  staging,
+		CBChannel:  callbacks,
+		CBFunction: unpackerr.atomicMoveCallback(download),
+	})
+	if err != nil {
+		t.Fatalf("queue.Extract returned error: %v", err)
+	}
+
+	resp := waitForDone(t, callbacks)
+	if resp.Error != nil {
+		t.Fatalf("unexpected extraction error: %v", resp.Error)
+	}
+
+	if resp.Output != download {
+		t.Fatalf("expected output to be the download path, got: %s", resp.Output)
+	}
+
+	for _, file := range []string{"episode.mkv", filepath.Join("Subs", "eng.srt")} {
+		if _, err := os.Stat(filepath.Join(download, file)); err != nil {
+			t.Fatalf("expected %s to be moved into the download path: %v", file, err)
+		}
+
+		if !containsString(resp.NewFiles, filepath.Join(download, file)) {
+			t.Fatalf("expected %s in new files, got: %v", file, resp.NewFiles)
+		}
+	}
+
+	if entries, _ := os.ReadDir(staging); len(entries) != 0 {
+		t.Fatalf("expected staging folder to be empty, found %d items", len(entries))
+	}
+}
+
+func waitForDone(t *testing.T, callbacks chan *xtractr.Response) *xtractr.Response {
+	t.Helper()
+
+	timeout := time.NewTimer(90 * time.Second)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case resp := <-callbacks:
+			if resp.Done {
+				return resp
+			}
+		case <-timeout.C:
+			t.Fatal("timed out waiting for extraction callback")
+		}
+	}
+}
+
+func TestCopyIntoPlace(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src, dest := filepath.Join(dir, "staged.mkv"), filepath.Join(dir, "episode.mkv")
+
+	if err := os.WriteFile(src, []byte("video"), 0o640); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	if err := os.WriteFile(dest, []byte("old"), defaultFileMode); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	if err := copyIntoPlace(src, dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if data, _ := os.ReadFile(dest); string(data) != "video" {
+		t.Fatalf("expected the destination to be replaced, got: %s", data)
+	}
+
+	if stat, err := os.Stat(dest); err != nil || stat.Mode().Perm() != 0o640 {
+		t.Fatalf("expected the source file mode to be kept: %v %v", stat.Mode(), err)
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected only the destination file to remain, got %d files", len(entries))
+	}
+}
+
+func TestExistingParent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if path := existingParent(filepath.Join(dir, "not", "made", "yet")); path != dir {
+		t.Fatalf("expected the nearest existing folder, got: %s", path)
+	}
+
+	if path := existingParent(dir); path != dir {
+		t.Fatalf("expected an existing folder to be returned, got: %s", path)
+	}
+}
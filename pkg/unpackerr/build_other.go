@@ -2,7 +2,13 @@
 
 package unpackerr
 
-import "syscall"
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
 
 const defaultSavePath = "/downloads"
 
@@ -12,3 +18,25 @@ func getUmask() int {
 
 	return umask
 }
+
+// diskSpace returns the free and total bytes on the file system holding a path.
+func diskSpace(path string) (uint64, uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, 0, fmt.Errorf("statfs %s: %w", path, err)
+	}
+
+	//nolint:unconvert // These types differ between platforms.
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
+
+// fileSystemID returns an identifier for the file system holding a path. Paths on the same file system
+// have the same identifier. The path must exist.
+func fileSystemID(path string) (string, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return "", fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	return strconv.FormatUint(uint64(stat.Dev), 10), nil //nolint:unconvert,gosec // These types differ between platforms.
+}
@@ -2,8 +2,42 @@
 
 package unpackerr
 
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
 const defaultSavePath = `C:\downloads`
 
 func getUmask() int {
 	return -1
 }
+
+// diskSpace returns the free and total bytes on the volume holding a path.
+func diskSpace(path string) (uint64, uint64, error) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, fmt.Errorf("converting path %s: %w", path, err)
+	}
+
+	var free, total uint64
+	if err := windows.GetDiskFreeSpaceEx(pathPtr, &free, &total, nil); err != nil {
+		return 0, 0, fmt.Errorf("disk space %s: %w", path, err)
+	}
+
+	return free, total, nil
+}
+
+// fileSystemID returns an identifier for the volume holding a path. Paths on the same volume
+// have the same identifier. The path must exist.
+func fileSystemID(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("finding absolute path %s: %w", path, err)
+	}
+
+	return strings.ToUpper(filepath.VolumeName(abs)), nil
+}
@@ -3,20 +3,28 @@ package unpackerr
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"text/template"
+	"time"
 
 	"golift.io/cnfg"
 )
 
 // Errors produced by this file.
 var (
-	ErrCmdhookNoCmd = errors.New("cmdhook without a command configured; fix it")
+	ErrCmdhookNoCmd   = errors.New("cmdhook without a command configured; fix it")
+	ErrUnclosedQuote  = errors.New("command has an unclosed quote")
+	ErrInvalidWorkdir = errors.New("cmdhook workdir is not a folder")
 )
 
 func (u *Unpackerr) validateCmdhook() error {
@@ -24,28 +32,56 @@ func (u *Unpackerr) validateCmdhook() error {
 		u.Cmdhook[idx].URL = ""
 
 		u.Cmdhook[idx].Command = expandHomedir(u.Cmdhook[idx].Command)
-		if u.Cmdhook[idx].Command == "" {
+		if strings.TrimSpace(u.Cmdhook[idx].Command) == "" {
 			return ErrCmdhookNoCmd
 		}
 
+		if err := u.Cmdhook[idx].parseCommand(); err != nil {
+			return err
+		}
+
 		if u.Cmdhook[idx].Name == "" {
-			u.Cmdhook[idx].Name = strings.Fields(u.Cmdhook[idx].Command)[0]
+			u.Cmdhook[idx].Name = u.Cmdhook[idx].program()
+		}
+
+		if err := u.Cmdhook[idx].validateWorkdir(); err != nil {
+			return err
 		}
 
 		if u.Cmdhook[idx].Timeout.Duration == 0 {
 			u.Cmdhook[idx].Timeout.Duration = u.Timeout.Duration
 		}
 
+		if strings.TrimSpace(u.Cmdhook[idx].Gate) != "" && len(u.Cmdhook[idx].Events) > 0 {
+			u.Printf("[Cmdhook] WARNING: %s: events do not apply to gates, ignoring: %v",
+				u.Cmdhook[idx].Name, u.Cmdhook[idx].Events)
+		}
+
 		if len(u.Cmdhook[idx].Events) == 0 {
 			u.Cmdhook[idx].Events = []ExtractStatus{WAITING}
 		}
+
+		if err := u.Cmdhook[idx].validateFilters(); err != nil {
+			return err
+		}
+
+		if err := u.Cmdhook[idx].validateGate(); err != nil {
+			return err
+		}
+
+		if u.Cmdhook[idx].Gate == "" {
+			u.Cmdhook[idx].setupQueue()
+		}
 	}
 
 	return nil
 }
 
 func (u *Unpackerr) runCmdhookWithLog(hook *WebhookConfig, payload *WebhookPayload) {
+	span := payload.span.child("cmdhook", spanKindInternal, time.Now(),
+		slog.String("hook", hook.Name), slog.String("event", payload.Event.String()))
 	out, err := u.runCmdhook(hook, payload)
+	span.finish(time.Now(), err)
 
 	hook.Lock() // we only lock for the integer increments.
 	defer hook.Unlock()
@@ -56,10 +92,10 @@ func (u *Unpackerr) runCmdhookWithLog(hook *WebhookConfig, payload *WebhookPaylo
 		u.Errorf("Command Hook (%s) %s: %v: %s", payload.Event, hook.Name, err, out.String())
 		hook.fails++
 	case hook.Silent || out == nil:
-		u.Printf("[Cmdhook] Queue: %d/%d. Ran command %s", len(u.hookChan), cap(u.hookChan), hook.Name)
+		u.Printf("[Cmdhook] Queue: %d/%d. Ran command %s", len(hook.queue), cap(hook.queue), hook.Name)
 	default:
 		u.Printf("[Cmdhook] Queue: %d/%d. Ran command %s: %s",
-			len(u.hookChan), cap(u.hookChan), hook.Name, strings.TrimSpace(out.String()))
+			len(hook.queue), cap(hook.queue), hook.Name, strings.TrimSpace(out.String()))
 	}
 }
 
@@ -68,6 +104,8 @@ func (u *Unpackerr) runCmdhook(hook *WebhookConfig, payload *WebhookPayload) (*b
 		return nil, ErrCmdhookNoCmd
 	}
 
+	defer u.hookMetrics("cmdhook", hook.Name, time.Now())
+
 	payload.Config = hook
 
 	env, err := cnfg.MarshalENV(payload, "UN")
@@ -75,49 +113,193 @@ func (u *Unpackerr) runCmdhook(hook *WebhookConfig, payload *WebhookPayload) (*b
 		return nil, fmt.Errorf("creating environment: %w", err)
 	}
 
+	stdin, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding payload: %w", err)
+	}
+
+	args, err := hook.commandArgs(payload)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), hook.Timeout.Duration)
 	defer cancel()
 
-	var cmd *exec.Cmd
+	var out bytes.Buffer
 
-	args := strings.Fields(hook.Command)
-	if len(args) == 0 {
-		return nil, ErrCmdhookNoCmd
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Dir = hook.Workdir
+	cmd.Env = env.Env()
+	cmd.Env = append(cmd.Env, "PATH="+os.Getenv("PATH"))
+
+	for _, name := range slices.Sorted(maps.Keys(hook.Env)) {
+		cmd.Env = append(cmd.Env, name+"="+hook.Env[name])
+	}
+
+	if err := cmd.Run(); err != nil {
+		return &out, fmt.Errorf("running cmd %q: %w", strings.Join(cmd.Args, " "), err)
+	}
+
+	return &out, nil
+}
+
+// parseCommand splits a command into quoted arguments, and parses each argument as a template.
+// Commands run in a shell are passed to the shell as they are, so they are not split or parsed.
+func (w *WebhookConfig) parseCommand() error {
+	if w.Shell {
+		return nil
+	}
+
+	args, err := splitCommand(w.Command)
+	if err != nil {
+		return fmt.Errorf("cmdhook %s: %w", w.Command, err)
+	} else if len(args) == 0 {
+		return ErrCmdhookNoCmd
+	}
+
+	w.args = make([]*template.Template, len(args))
+
+	for idx, arg := range args {
+		if w.args[idx], err = template.New("arg").Funcs(w.templateFuncs()).Parse(arg); err != nil {
+			return fmt.Errorf("cmdhook %s: parsing argument %d: %w", w.Command, idx, err)
+		}
+	}
+
+	return nil
+}
+
+// program returns the program a command runs, without its arguments. Shell commands
+// may not split like a plain command, so those fall back to the first word.
+func (w *WebhookConfig) program() string {
+	if args, err := splitCommand(w.Command); err == nil && len(args) > 0 {
+		return args[0]
 	}
 
-	if args[0], err = filepath.Abs(args[0]); err != nil {
-		return nil, fmt.Errorf("finding command hook command: %w", err)
+	return strings.Fields(w.Command)[0]
+}
+
+// validateWorkdir makes sure the working directory exists, if one is provided.
+func (w *WebhookConfig) validateWorkdir() error {
+	if w.Workdir == "" {
+		return nil
+	}
+
+	w.Workdir = expandHomedir(w.Workdir)
+	if stat, err := os.Stat(w.Workdir); err != nil || !stat.IsDir() {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidWorkdir, w.Name, w.Workdir)
 	}
 
-	if hook.Shell {
+	return nil
+}
+
+// commandArgs returns the command and arguments to run for a payload.
+func (w *WebhookConfig) commandArgs(payload *WebhookPayload) ([]string, error) {
+	if w.Shell {
 		if runtime.GOOS == windows {
-			args = append([]string{"cmd", "/C"}, args...)
-		} else {
-			args = append([]string{"/bin/sh", "-c"}, args...)
+			return []string{"cmd", "/C", w.Command}, nil
 		}
+
+		return []string{"/bin/sh", "-c", w.Command}, nil
 	}
 
-	switch len(args) {
-	case 0:
-		return nil, ErrCmdhookNoCmd
-	case 1:
-		cmd = exec.CommandContext(ctx, args[0]) //nolint:gosec
-	default:
-		cmd = exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec
+	if len(w.args) == 0 {
+		if err := w.parseCommand(); err != nil {
+			return nil, err
+		}
 	}
 
-	var out bytes.Buffer
+	args := make([]string, len(w.args))
 
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	cmd.Env = env.Env()
-	cmd.Env = append(cmd.Env, "PATH="+os.Getenv("PATH"))
+	for idx, tmpl := range w.args {
+		var arg strings.Builder
+		if err := tmpl.Execute(&arg, payload); err != nil {
+			return nil, fmt.Errorf("rendering command argument %d: %w", idx, err)
+		}
 
-	if err := cmd.Run(); err != nil {
-		return &out, fmt.Errorf("running cmd %q: %w", strings.Join(cmd.Args, " "), err)
+		args[idx] = arg.String()
 	}
 
-	return &out, nil
+	// A relative command path is relative to the workdir; without one, make it absolute.
+	// A command without a path is found in PATH.
+	if w.Workdir == "" && strings.ContainsAny(args[0], `/\`) {
+		var err error
+		if args[0], err = filepath.Abs(args[0]); err != nil {
+			return nil, fmt.Errorf("finding command hook command: %w", err)
+		}
+	}
+
+	return args, nil
+}
+
+// splitCommand splits a command line into arguments. Single quotes keep everything inside them,
+// and double quotes keep spaces. A backslash escapes a quote, a space or another backslash;
+// other backslashes are kept, so Windows paths work without escaping.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, char := range command {
+		switch {
+		case escaped:
+			if !strings.ContainsRune(`"' \`, char) || (quote == '"' && char == '\'') {
+				arg.WriteRune('\\')
+			}
+
+			arg.WriteRune(char)
+
+			escaped = false
+		case quote == '\'':
+			if char == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(char)
+			}
+		case char == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if char == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(char)
+			}
+		case char == '"' || char == '\'':
+			quote, inArg = char, true
+		case char == ' ' || char == '\t' || char == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+
+			inArg = false
+		default:
+			arg.WriteRune(char)
+
+			inArg = true
+		}
+	}
+
+	if escaped {
+		arg.WriteRune('\\')
+	}
+
+	if quote != 0 {
+		return nil, ErrUnclosedQuote
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
 }
 
 func (u *Unpackerr) logCmdhook() {
@@ -131,8 +313,25 @@ func (u *Unpackerr) logCmdhook() {
 	}
 
 	for _, f := range u.Cmdhook {
-		u.Printf("%s: %s, timeout: %v, silent: %v, events: %v, shell: %v, cmd: %s",
-			prefix, f.Name, f.Timeout, f.Silent, logEvents(f.Events), f.Shell, f.Command)
+		var vars string
+		if f.hasFilters() {
+			vars = ", filters: " + f.logFilters()
+		}
+
+		if f.Workdir != "" {
+			vars += ", workdir: " + f.Workdir
+		}
+
+		if len(f.Env) > 0 {
+			vars += fmt.Sprintf(", env: %d", len(f.Env))
+		}
+
+		if f.Gate != "" {
+			vars += fmt.Sprintf(", gate: %s (skip: %v)", f.Gate, f.GateSkip)
+		}
+
+		u.Printf("%s: %s, timeout: %v, silent: %v, events: %v, shell: %v, queue: %d%s, cmd: %s",
+			prefix, f.Name, f.Timeout, f.Silent, logEvents(f.Events), f.Shell, f.QueueSize, vars, f.Command)
 	}
 }
 
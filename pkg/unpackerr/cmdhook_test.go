@@ -0,0 +1,96 @@
+package unpackerr
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golift.io/cnfg"
+)
+
+func TestSplitCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]string{
+		`/usr/bin/env`:                                {"/usr/bin/env"},
+		`  script.sh   one  two `:                     {"script.sh", "one", "two"},
+		`"/my scripts/run.sh" "{{.Path}}" '{{.App}}'`: {"/my scripts/run.sh", "{{.Path}}", "{{.App}}"},
+		`run.sh it\'s "say \"hi\"" a\ b`:              {"run.sh", "it's", `say "hi"`, "a b"},
+		`C:\scripts\run.bat 'single \ quote' ""`:      {`C:\scripts\run.bat`, `single \ quote`, ""},
+	}
+
+	for command, expect := range tests {
+		args, err := splitCommand(command)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", command, err)
+		}
+
+		if !reflect.DeepEqual(args, expect) {
+			t.Fatalf("%s: expected %q, got %q", command, expect, args)
+		}
+	}
+
+	if _, err := splitCommand(`run.sh "unclosed`); err == nil {
+		t.Fatal("expected an error with an unclosed quote")
+	}
+}
+
+func TestRunCmdhook(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == windows {
+		t.Skip("uses /bin/sh")
+	}
+
+	workdir := t.TempDir()
+	unpackerr := &Unpackerr{Config: &Config{
+		Timeout: cnfg.Duration{Duration: 10 * time.Second},
+		Cmdhook: []*WebhookConfig{{
+			Command: `sh -c 'cat; echo; echo "$0|$1|$FOO|$UN_APP|$(pwd)"' "{{.Path}}" {{.Event}}`,
+			Workdir: workdir,
+			Env:     map[string]string{"FOO": "bar baz"},
+		}},
+	}}
+
+	if err := unpackerr.validateCmdhook(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	payload := &WebhookPayload{Path: "/downloads/Some Show S01", App: "Sonarr", Event: EXTRACTED}
+
+	out, err := unpackerr.runCmdhook(unpackerr.Cmdhook[0], payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v: %s", err, out)
+	}
+
+	stdin, result, _ := strings.Cut(strings.TrimSpace(out.String()), "\n")
+
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(stdin), &decoded); err != nil || decoded["path"] != payload.Path {
+		t.Fatalf("expected the payload json on stdin, got: %s: %v", stdin, err)
+	}
+
+	if expect := "/downloads/Some Show S01|extracted|bar baz|Sonarr|"; !strings.HasPrefix(result, expect) ||
+		!strings.HasSuffix(result, strings.TrimPrefix(workdir, "/private")) {
+		t.Fatalf("expected %s<workdir>, got: %s", expect, result)
+	}
+
+	unpackerr.Cmdhook = []*WebhookConfig{{Command: `"/opt/my scripts/notify.sh" {{.Path}}`}}
+	if err := unpackerr.validateCmdhook(); err != nil || unpackerr.Cmdhook[0].Name != "/opt/my scripts/notify.sh" {
+		t.Fatalf("expected the quoted program as the name, got: %q: %v", unpackerr.Cmdhook[0].Name, err)
+	}
+
+	unpackerr.Cmdhook = []*WebhookConfig{{Command: " \t ", Shell: true}}
+	if err := unpackerr.validateCmdhook(); !errors.Is(err, ErrCmdhookNoCmd) {
+		t.Fatalf("expected an error for a blank shell command, got: %v", err)
+	}
+
+	unpackerr.Cmdhook = []*WebhookConfig{{Command: "true", Workdir: "/this/does/not/exist"}}
+	if err := unpackerr.validateCmdhook(); err == nil {
+		t.Fatal("expected an error with a missing workdir")
+	}
+}
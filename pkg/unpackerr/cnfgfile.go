@@ -128,6 +128,8 @@ func (u *Unpackerr) validateConfig() (uint64, uint64) { //nolint:cyclop
 		u.LogFileMode = strconv.FormatUint(defaultLogFileMode, bits8)
 	}
 
+	u.LogFormat = validLogFormat(u.LogFormat)
+
 	fileMode, err := strconv.ParseUint(u.FileMode, bits8, base32)
 	if err != nil || u.FileMode == "" {
 		fileMode = defaultFileMode
@@ -176,6 +178,10 @@ func (u *Unpackerr) validateConfig() (uint64, uint64) { //nolint:cyclop
 		u.LogFile = filepath.Join("~", ".unpackerr", "unpackerr.log")
 	}
 
+	if ui.HasGUI() && u.HistoryFile == "" {
+		u.HistoryFile = filepath.Join("~", ".unpackerr", "history.jsonl")
+	}
+
 	if u.KeepHistory != 0 {
 		u.Items = make([]string, u.KeepHistory)
 	}
@@ -223,36 +229,30 @@ func (u *Unpackerr) createConfigFile(file string) (string, error) {
 	return file, nil
 }
 
-// This function checks if rar passwords need to be read from a file path.
-// Only runs once at startup to load passwords into memory.
+// setPasswords splits literal rar passwords from password files, for the global list and
+// every app and folder. Password files are read now, and re-read later when they change.
+// The password sidecar file names are checked here too.
+// This runs before the config file parser, so it does not replace filepath: passwords.
 func (u *Unpackerr) setPasswords() error {
-	const filePrefix = "filepath:"
-
-	newPasswords := []string{}
+	var err error
 
-	for _, pass := range u.Passwords {
-		if !strings.HasPrefix(pass, filePrefix) {
-			newPasswords = append(newPasswords, pass)
-			continue
-		}
+	if u.passwords, u.Passwords, err = newPasswordList(u.Passwords); err != nil {
+		return err
+	}
 
-		fileContent, err := os.ReadFile(strings.TrimPrefix(pass, filePrefix))
-		if err != nil {
-			return fmt.Errorf("reading password file: %w", err)
+	for _, conf := range u.starrConfigs() {
+		if conf.passwords, conf.Passwords, err = newPasswordList(conf.Passwords); err != nil {
+			return fmt.Errorf("%s: %w", conf.URL, err)
 		}
+	}
 
-		filePasswords := strings.Split(string(fileContent), "\n")
-		if len(filePasswords) > 0 && filePasswords[len(filePasswords)-1] == "" {
-			// Remove the last "password" if it's blank (newline at end of file).
-			filePasswords = filePasswords[:len(filePasswords)-1]
+	for _, folder := range u.Folders {
+		if folder.passwords, folder.Passwords, err = newPasswordList(folder.Passwords); err != nil {
+			return fmt.Errorf("folder %s: %w", folder.Path, err)
 		}
-
-		newPasswords = append(newPasswords, filePasswords...)
 	}
 
-	u.Passwords = newPasswords
-
-	return nil
+	return u.validatePasswordSidecars()
 }
 
 // only run this once.
@@ -312,6 +312,17 @@ func (u *Unpackerr) validateApp(conf *StarrConfig, app starr.App) error {
 		conf.Paths = []string{defaultSavePath}
 	}
 
+	if conf.ExtractPath != "" {
+		conf.ExtractPath = expandHomedir(conf.ExtractPath)
+
+		if !conf.Atomic {
+			u.Printf("[%s] WARNING: extract_path is only used with atomic = true, ignoring: %s", app, conf.ExtractPath)
+			conf.ExtractPath = ""
+		} else {
+			u.checkStagingFileSystem(conf, app)
+		}
+	}
+
 	if conf.Protocols == "" {
 		conf.Protocols = defaultProtocol
 	}
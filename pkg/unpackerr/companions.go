@@ -0,0 +1,159 @@
+package unpackerr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golift.io/xtractr"
+)
+
+// Companion file modes for watched folders.
+const (
+	CompanionNone     = "none"
+	CompanionHardlink = "hardlink"
+	CompanionCopy     = "copy"
+)
+
+// ErrInvalidCompanion is returned when a folder has an unknown companion_files mode.
+var ErrInvalidCompanion = errors.New("invalid companion_files mode, must be one of: none, hardlink, copy")
+
+// validateCompanions normalizes the companion files mode and glob filters for a watched folder.
+func (c *FolderConfig) validateCompanions() error {
+	switch c.CompanionFiles = strings.ToLower(strings.TrimSpace(c.CompanionFiles)); c.CompanionFiles {
+	case "":
+		c.CompanionFiles = CompanionNone
+	case CompanionNone, CompanionHardlink, CompanionCopy:
+	default:
+		return fmt.Errorf("%w: %s: %s", ErrInvalidCompanion, c.Path, c.CompanionFiles)
+	}
+
+	for _, glob := range c.CompanionGlobs {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("folder %s: companion glob '%s': %w", c.Path, glob, err)
+		}
+	}
+
+	return nil
+}
+
+// isCompanion returns true if a file should be mirrored into the extraction output.
+// Archives (and their extra volumes) are never companions, they get extracted.
+func (c *FolderConfig) isCompanion(name string) bool {
+	if xtractr.IsArchiveFile(name) || rarRegexp.MatchString(name) || multi7zRegexp.MatchString(name) ||
+		strings.Contains(name, suffix) {
+		return false
+	}
+
+	if len(c.CompanionGlobs) == 0 {
+		return true
+	}
+
+	for _, glob := range c.CompanionGlobs {
+		if match, _ := filepath.Match(glob, name); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// companionFilesCallback returns an xtractr callback that hardlinks or copies the non-archive files
+// from a watched folder into the extraction output after a successful extraction. The mirrored files
+// are added to the response's new files, so they are cleaned up with the extracted files.
+// This runs in the extraction go routine before the response reaches the main routine.
+func (u *Unpackerr) companionFilesCallback(cfg *FolderConfig) func(*xtractr.Response) {
+	return func(resp *xtractr.Response) {
+		if !resp.Done || resp.Error != nil || resp.Output == "" {
+			return
+		}
+
+		if stat, err := os.Stat(resp.X.Path); err != nil || !stat.IsDir() {
+			return // A lone archive file has no companions.
+		}
+
+		files, err := u.mirrorCompanions(cfg, resp.X.Path, resp.Output)
+		if err != nil {
+			u.Errorf("[Folder] Mirroring companion files: %s: %v", resp.X.Name, err)
+		}
+
+		if len(files) > 0 {
+			u.Printf("[Folder] Mirrored %d companion files (%s): %s -> %s",
+				len(files), cfg.CompanionFiles, resp.X.Path, resp.Output)
+			resp.NewFiles = append(resp.NewFiles, files...)
+		}
+	}
+}
+
+// mirrorCompanions walks the source folder and hardlinks or copies every companion file into the
+// same relative location in the output folder. Files that already exist in the output are skipped.
+func (u *Unpackerr) mirrorCompanions(cfg *FolderConfig, source, output string) ([]string, error) {
+	files := []string{}
+
+	err := filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !entry.Type().IsRegular() || !cfg.isCompanion(entry.Name()) {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return fmt.Errorf("finding relative path: %w", err)
+		}
+
+		newFile := filepath.Join(output, rel)
+		if _, err := os.Stat(newFile); err == nil {
+			return nil // Extracted files win.
+		}
+
+		if err := os.MkdirAll(filepath.Dir(newFile), u.dirMode()); err != nil {
+			return fmt.Errorf("making folder: %w", err)
+		}
+
+		if err := linkOrCopy(cfg.CompanionFiles, path, newFile); err != nil {
+			return err
+		}
+
+		files = append(files, newFile)
+
+		return nil
+	})
+	if err != nil {
+		return files, fmt.Errorf("walking folder: %w", err)
+	}
+
+	return files, nil
+}
+
+// linkOrCopy hardlinks a file, or copies it. Hardlinks fall back to a copy across file systems.
+func linkOrCopy(mode, oldpath, newpath string) error {
+	if mode == CompanionHardlink && os.Link(oldpath, newpath) == nil {
+		return nil
+	}
+
+	stat, err := os.Stat(oldpath)
+	if err != nil {
+		return fmt.Errorf("os.Stat: %w", err)
+	}
+
+	oldFile, err := os.Open(oldpath)
+	if err != nil {
+		return fmt.Errorf("os.Open: %w", err)
+	}
+	defer oldFile.Close()
+
+	newFile, err := os.OpenFile(newpath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, stat.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+	defer newFile.Close()
+
+	if _, err = io.Copy(newFile, oldFile); err != nil {
+		return fmt.Errorf("copying %s: %w", oldpath, err)
+	}
+
+	return nil
+}
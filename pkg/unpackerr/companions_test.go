@@ -0,0 +1,68 @@
+package unpackerr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMirrorCompanions(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	output := t.TempDir()
+	files := map[string]string{
+		"release.nfo":           "info",
+		"Subs/eng.srt":          "subtitles",
+		"release.rar":           "archive",
+		"release.r00":           "volume",
+		"release.r01":           "volume",
+		"sample.txt":            "not matched",
+		"already-extracted.nfo": "source",
+	}
+
+	for name, data := range files {
+		path := filepath.Join(source, name)
+		if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+			t.Fatalf("making fixture folder: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(data), defaultFileMode); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(output, "already-extracted.nfo"), []byte("output"), defaultFileMode); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+
+	cfg := &FolderConfig{Path: source, CompanionFiles: "Hardlink", CompanionGlobs: []string{"*.nfo", "*.srt"}}
+	if err := cfg.validateCompanions(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	unpackerr := &Unpackerr{Config: &Config{}}
+
+	mirrored, err := unpackerr.mirrorCompanions(cfg, source, output)
+	if err != nil {
+		t.Fatalf("unexpected mirror error: %v", err)
+	}
+
+	if len(mirrored) != 2 {
+		t.Fatalf("expected 2 mirrored files, got %d: %v", len(mirrored), mirrored)
+	}
+
+	for _, name := range []string{"release.nfo", filepath.Join("Subs", "eng.srt")} {
+		if !containsString(mirrored, filepath.Join(output, name)) {
+			t.Fatalf("expected %s to be mirrored, got: %v", name, mirrored)
+		}
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(output, "already-extracted.nfo")); string(data) != "output" {
+		t.Fatalf("existing output file was overwritten: %s", data)
+	}
+
+	if err := (&FolderConfig{CompanionFiles: "symlink"}).validateCompanions(); err == nil {
+		t.Fatal("expected an error for an invalid companion mode")
+	}
+}
@@ -0,0 +1,529 @@
+package unpackerr
+
+/* Email (SMTP) notifications, with optional digests. */
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golift.io/cnfg"
+	"golift.io/starr"
+)
+
+// Email TLS modes.
+const (
+	EmailStartTLS = "starttls"
+	EmailTLS      = "tls"
+	EmailNoTLS    = "none"
+)
+
+// Default SMTP ports for each TLS mode.
+const (
+	smtpPort         = 25
+	smtpStartTLSPort = 587
+	smtpTLSPort      = 465
+)
+
+// Errors produced by this file.
+var (
+	ErrEmailNoHost     = errors.New("email without a host configured; fix it")
+	ErrEmailNoTo       = errors.New("email without a to address configured; fix it")
+	ErrInvalidEmailTLS = errors.New("invalid email tls mode, must be one of: starttls, tls, none")
+)
+
+// EmailConfig defines the settings to send email notifications.
+type EmailConfig struct {
+	Name       string          `json:"name"             toml:"name"               xml:"name"                         yaml:"name"`
+	Host       string          `json:"host"             toml:"host"               xml:"host"                         yaml:"host"`
+	Port       uint            `json:"port"             toml:"port"               xml:"port"                         yaml:"port"`
+	TLS        string          `json:"tls"              toml:"tls"                xml:"tls"                          yaml:"tls"`
+	IgnoreSSL  bool            `json:"ignoreSsl"        toml:"ignore_ssl"         xml:"ignore_ssl,omitempty"         yaml:"ignoreSsl"`
+	Username   string          `json:"username"         toml:"username"           xml:"username,omitempty"           yaml:"username"`
+	Password   string          `json:"password"         toml:"password"           xml:"password,omitempty"           yaml:"password"`
+	From       string          `json:"from"             toml:"from"               xml:"from"                         yaml:"from"`
+	To         StringSlice     `json:"to"               toml:"to"                 xml:"to"                           yaml:"to"`
+	Subject    string          `json:"subject"          toml:"subject"            xml:"subject,omitempty"            yaml:"subject"`
+	TmplPath   string          `json:"templatePath"     toml:"template_path"      xml:"template_path,omitempty"      yaml:"templatePath"`
+	TextPath   string          `json:"textTemplatePath" toml:"text_template_path" xml:"text_template_path,omitempty" yaml:"textTemplatePath"`
+	Digest     cnfg.Duration   `json:"digest"           toml:"digest"             xml:"digest"                       yaml:"digest"`
+	Timeout    cnfg.Duration   `json:"timeout"          toml:"timeout"            xml:"timeout"                      yaml:"timeout"`
+	Silent     bool            `json:"silent"           toml:"silent"             xml:"silent"                       yaml:"silent"`
+	Events     ExtractStatuses `json:"events"           toml:"events"             xml:"events"                       yaml:"events"`
+	Exclude    StringSlice     `json:"exclude"          toml:"exclude"            xml:"exclude"                      yaml:"exclude"`
+	queue      chan *WebhookPayload
+	sends      uint
+	fails      uint
+	dropped    uint
+	sync.Mutex `json:"-" toml:"-" xml:"-" yaml:"-"`
+}
+
+// DefaultEmailSubject is the subject template for a single event email.
+const DefaultEmailSubject = `Unpackerr: {{.Event.Desc}}{{with index .IDs "title"}}: {{.}}{{end}}`
+
+// EmailTemplateText is the built-in plain text email body.
+const EmailTemplateText = `{{.Event.Desc}}{{with index .IDs "title"}}: {{.}}{{end}}
+
+App: {{.App}}
+Path: {{.Path}}
+{{- if .Data}}
+{{- if .Data.Elapsed.Duration}}
+Elapsed: {{.Data.Elapsed}}{{end}}
+{{- if .Data.Archives}}
+Archives: {{len .Data.Archives}}{{end}}
+{{- if .Data.Files}}
+Files: {{len .Data.Files}}{{end}}
+{{- if .Data.Bytes}}
+Size: {{humanbytes .Data.Bytes}}{{end}}
+{{- if .Data.Error}}
+Error: {{.Data.Error}}{{end}}
+{{- end}}
+{{- if .Report}}
+
+{{.Report.Summary}}
+{{- end}}
+Time: {{timestamp .Time}}
+`
+
+// EmailTemplateHTML is the built-in HTML email body.
+const EmailTemplateHTML = `<h3>{{.Event.Desc}}{{with index .IDs "title"}}: {{.}}{{end}}</h3>
+<table>
+  <tr><td><b>App</b></td><td>{{.App}}</td></tr>
+  <tr><td><b>Path</b></td><td><code>{{.Path}}</code></td></tr>
+{{- if .Data}}
+{{- if .Data.Elapsed.Duration}}
+  <tr><td><b>Elapsed</b></td><td>{{.Data.Elapsed}}</td></tr>{{end}}
+{{- if .Data.Archives}}
+  <tr><td><b>Archives</b></td><td>{{len .Data.Archives}}</td></tr>{{end}}
+{{- if .Data.Files}}
+  <tr><td><b>Files</b></td><td>{{len .Data.Files}}</td></tr>{{end}}
+{{- if .Data.Bytes}}
+  <tr><td><b>Size</b></td><td>{{humanbytes .Data.Bytes}}</td></tr>{{end}}
+{{- end}}
+{{- with .Report}}
+  <tr><td><b>Extracted</b></td><td>{{.Extracted}}</td></tr>
+  <tr><td><b>Failed</b></td><td>{{.Failed}}</td></tr>
+  <tr><td><b>Size</b></td><td>{{humanbytes .Bytes}}</td></tr>
+  <tr><td><b>Average</b></td><td>{{.Average}}</td></tr>
+{{- range .Apps}}
+  <tr><td><b>{{.App}}</b></td><td>{{.Extracted}} extracted, {{.Failed}} failed</td></tr>{{end}}
+{{- end}}
+  <tr><td><b>Time</b></td><td>{{timestamp .Time}}</td></tr>
+</table>
+{{- if and .Report .Report.Slowest}}
+<p><b>Slowest</b>:</p>
+<ul>{{range .Report.Slowest}}<li>{{.Name}} ({{.App}}): {{.Elapsed}}</li>{{end}}</ul>{{end}}
+{{- if and .Data .Data.Error}}
+<p style="color:#cc0000"><b>Error</b>: {{.Data.Error}}</p>{{end}}
+`
+
+func (u *Unpackerr) validateEmail() error {
+	for _, email := range u.Email {
+		if email.Host == "" {
+			return ErrEmailNoHost
+		}
+
+		if len(email.To) == 0 {
+			return fmt.Errorf("%w: %s", ErrEmailNoTo, email.Host)
+		}
+
+		switch email.TLS = strings.ToLower(strings.TrimSpace(email.TLS)); email.TLS {
+		case "", EmailStartTLS:
+			email.TLS = EmailStartTLS
+			email.Port = pickPort(email.Port, smtpStartTLSPort)
+		case EmailTLS:
+			email.Port = pickPort(email.Port, smtpTLSPort)
+		case EmailNoTLS:
+			email.Port = pickPort(email.Port, smtpPort)
+		default:
+			return fmt.Errorf("%w: %s: %s", ErrInvalidEmailTLS, email.Host, email.TLS)
+		}
+
+		if email.Name == "" {
+			email.Name = email.Host
+		}
+
+		if email.From == "" {
+			email.From = email.Username
+		}
+
+		if email.Subject == "" {
+			email.Subject = DefaultEmailSubject
+		}
+
+		if email.Timeout.Duration == 0 {
+			email.Timeout.Duration = u.Timeout.Duration
+		}
+
+		if len(email.Events) == 0 {
+			email.Events = []ExtractStatus{WAITING}
+		}
+
+		// Parse the templates now, so mistakes show up on startup.
+		if _, _, _, err := email.templates(); err != nil {
+			return fmt.Errorf("email %s: %w", email.Name, err)
+		}
+
+		if email.queue == nil {
+			email.queue = make(chan *WebhookPayload, defaultHookQueue)
+		}
+	}
+
+	return nil
+}
+
+func pickPort(port, defaultPort uint) uint {
+	if port == 0 {
+		return defaultPort
+	}
+
+	return port
+}
+
+func (u *Unpackerr) logEmail() {
+	var prefix string
+
+	if len(u.Email) == 1 {
+		prefix = " => Email Config: 1 server"
+	} else {
+		u.Printf(" => Email Configs: %d servers", len(u.Email))
+		prefix = " =>    Email" //nolint:wsl_v5
+	}
+
+	for _, email := range u.Email {
+		digest := "off"
+		if email.Digest.Duration > 0 {
+			digest = email.Digest.String()
+		}
+
+		u.Printf("%s: %s, host: %s:%d, tls: %s, from: %s, to: %q, digest: %s, events: %q",
+			prefix, email.Name, email.Host, email.Port, email.TLS, email.From, email.To, digest, logEvents(email.Events))
+	}
+}
+
+// Excluded returns true if an app is in the Exclude slice.
+func (e *EmailConfig) Excluded(app starr.App) bool {
+	return e.Exclude.HasApp(app)
+}
+
+// HasEvent returns true if a status event is in the Events slice.
+func (e *EmailConfig) HasEvent(status ExtractStatus) bool {
+	return e.Events.Has(status)
+}
+
+// queueEmail gives a payload to an email worker without blocking; it's dropped if the queue is full.
+func (u *Unpackerr) queueEmail(email *EmailConfig, payload *WebhookPayload) {
+	if email.queue == nil {
+		return
+	}
+
+	select {
+	case email.queue <- payload:
+	default:
+		email.Lock()
+		email.dropped++
+		dropped := email.dropped
+		email.Unlock()
+
+		u.Errorf("[Email] Queue full (%d), dropped payload (%s = %s): %s, %d dropped total",
+			cap(email.queue), payload.Path, payload.Event, email.Name, dropped)
+	}
+}
+
+// emailWorker sends emails for one email config. With a digest interval, events are collected
+// and sent together in one email when the interval passes.
+func (u *Unpackerr) emailWorker(email *EmailConfig) {
+	var (
+		digest  <-chan time.Time
+		pending []*WebhookPayload
+	)
+
+	if email.Digest.Duration > 0 {
+		ticker := time.NewTicker(email.Digest.Duration)
+		defer ticker.Stop()
+
+		digest = ticker.C
+	}
+
+	for {
+		select {
+		case <-digest:
+			if len(pending) > 0 {
+				u.sendEmailWithLog(email, pending...)
+				pending = nil
+			}
+		case payload, ok := <-email.queue:
+			if !ok {
+				return
+			} else if digest != nil && payload.Event != REPORT {
+				pending = append(pending, payload)
+			} else {
+				u.sendEmailWithLog(email, payload)
+			}
+		}
+	}
+}
+
+func (u *Unpackerr) sendEmailWithLog(email *EmailConfig, payloads ...*WebhookPayload) {
+	msg, err := email.Message(time.Now(), payloads...)
+	if err != nil {
+		u.Errorf("Email (%s): %d events: %v", email.Name, len(payloads), err)
+		return
+	}
+
+	if err := email.Send(msg); err != nil {
+		u.Errorf("Email (%s): %d events: %v", email.Name, len(payloads), err)
+	} else if !email.Silent {
+		u.Printf("[Email] Sent %d events to %s: %s", len(payloads), strings.Join(email.To, ", "), email.Name)
+	}
+}
+
+// templates returns the subject, plain text and HTML templates for an email.
+//
+//nolint:wrapcheck
+func (e *EmailConfig) templates() (*template.Template, *template.Template, *htmltemplate.Template, error) {
+	funcs := map[string]any{
+		"humanbytes": humanbytes,
+		"timestamp":  func(t time.Time) string { return t.Format(time.RFC1123) },
+		"name":       func() string { return e.Name },
+	}
+
+	subject, err := template.New("subject").Funcs(funcs).Parse(e.Subject)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("subject template: %w", err)
+	}
+
+	textBody, htmlBody := EmailTemplateText, EmailTemplateHTML
+
+	if e.TextPath != "" {
+		data, err := os.ReadFile(e.TextPath)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("text template file: %w", err)
+		}
+
+		textBody = string(data)
+	}
+
+	if e.TmplPath != "" {
+		data, err := os.ReadFile(e.TmplPath)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("template file: %w", err)
+		}
+
+		htmlBody = string(data)
+	}
+
+	text, err := template.New("text").Funcs(funcs).Parse(textBody)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("text template: %w", err)
+	}
+
+	html, err := htmltemplate.New("html").Funcs(funcs).Parse(htmlBody)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("html template: %w", err)
+	}
+
+	return subject, text, html, nil
+}
+
+// Message renders an email with a plain text and an HTML part. More than one payload makes a digest.
+func (e *EmailConfig) Message(now time.Time, payloads ...*WebhookPayload) ([]byte, error) {
+	subjectTmpl, textTmpl, htmlTmpl, err := e.templates()
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, text, html bytes.Buffer
+
+	if len(payloads) == 1 {
+		if err := subjectTmpl.Execute(&subject, payloads[0]); err != nil {
+			return nil, fmt.Errorf("subject template: %w", err)
+		}
+	} else {
+		fmt.Fprintf(&subject, "Unpackerr: %d events", len(payloads))
+	}
+
+	for idx, payload := range payloads {
+		if idx > 0 {
+			text.WriteString("\n----------\n\n")
+			html.WriteString("<hr>\n")
+		}
+
+		if err := textTmpl.Execute(&text, payload); err != nil {
+			return nil, fmt.Errorf("text template: %w", err)
+		}
+
+		if err := htmlTmpl.Execute(&html, payload); err != nil {
+			return nil, fmt.Errorf("html template: %w", err)
+		}
+	}
+
+	return e.mime(now, strings.Join(strings.Fields(subject.String()), " "), text.Bytes(),
+		[]byte("<html><body>\n"+html.String()+"</body></html>\n"))
+}
+
+// mime builds a multipart/alternative message from the plain text and HTML parts.
+func (e *EmailConfig) mime(now time.Time, subject string, text, html []byte) ([]byte, error) {
+	var (
+		msg  bytes.Buffer
+		body bytes.Buffer
+	)
+
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		ctype string
+		data  []byte
+	}{{"text/plain; charset=utf-8", text}, {"text/html; charset=utf-8", html}} {
+		writer, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.ctype},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("creating email part: %w", err)
+		}
+
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write(part.data); err != nil {
+			return nil, fmt.Errorf("writing email part: %w", err)
+		}
+
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("writing email part: %w", err)
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("closing email: %w", err)
+	}
+
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// Send delivers an email message to the SMTP server.
+func (e *EmailConfig) Send(msg []byte) error {
+	e.Lock()
+	e.sends++
+	e.Unlock()
+
+	if err := e.send(msg); err != nil {
+		e.Lock()
+		e.fails++
+		e.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+func (e *EmailConfig) send(msg []byte) error {
+	addr := net.JoinHostPort(e.Host, strconv.FormatUint(uint64(e.Port), 10))
+	dialer := &net.Dialer{Timeout: e.Timeout.Duration}
+	tlsConfig := &tls.Config{ServerName: e.Host, InsecureSkipVerify: e.IgnoreSSL} //nolint:gosec
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if e.TLS == EmailTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("connecting to smtp server: %w", err)
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(e.Timeout.Duration))
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer client.Close()
+
+	if e.TLS == EmailStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	return e.deliver(client, msg)
+}
+
+func (e *EmailConfig) deliver(client *smtp.Client, msg []byte) error {
+	if err := client.Mail(e.From); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+
+	for _, rcpt := range e.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp rcpt to %s: %w", rcpt, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+
+	if _, err := writer.Write(msg); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+
+	if err := client.Quit(); err != nil {
+		return fmt.Errorf("smtp quit: %w", err)
+	}
+
+	return nil
+}
+
+// EmailCounts returns the total count of emails sent and failed.
+func (u *Unpackerr) EmailCounts() (uint, uint) {
+	var sends, fails uint
+
+	for _, email := range u.Email {
+		email.Lock()
+		sends += email.sends
+		fails += email.fails
+		email.Unlock()
+	}
+
+	return sends, fails
+}
@@ -0,0 +1,184 @@
+package unpackerr
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"golift.io/cnfg"
+)
+
+// fakeSMTP accepts one SMTP session on a local port and returns the message it received.
+func fakeSMTP(t *testing.T) (uint, <-chan string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		_ = text.PrintfLine("220 localhost ESMTP")
+
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				_ = text.PrintfLine("250 OK")
+			case "DATA":
+				_ = text.PrintfLine("354 Go ahead")
+
+				data, _ := io.ReadAll(bufio.NewReader(text.DotReader()))
+				messages <- string(data)
+
+				_ = text.PrintfLine("250 OK")
+			case "QUIT":
+				_ = text.PrintfLine("221 Bye")
+				return
+			default:
+				_ = text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	return uint(listener.Addr().(*net.TCPAddr).Port), messages //nolint:forcetypeassert,gosec
+}
+
+func testEmail(t *testing.T) (*Unpackerr, <-chan string) {
+	t.Helper()
+
+	port, messages := fakeSMTP(t)
+	unpackerr := &Unpackerr{Config: &Config{
+		Timeout: cnfg.Duration{Duration: time.Second},
+		Email: []*EmailConfig{{
+			Host: "127.0.0.1",
+			Port: port,
+			TLS:  EmailNoTLS,
+			From: "unpackerr@localhost",
+			To:   StringSlice{"one@localhost", "two@localhost"},
+		}},
+	}}
+
+	if err := unpackerr.validateEmail(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return unpackerr, messages
+}
+
+func TestEmail(t *testing.T) {
+	t.Parallel()
+
+	unpackerr, messages := testEmail(t)
+	email := unpackerr.Email[0]
+	payload := samplePayload()
+	payload.Event = EXTRACTED
+
+	msg, err := email.Message(time.Now(), payload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := email.Send(msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	received := <-messages
+	for _, expect := range []string{
+		"Subject: Unpackerr: " + EXTRACTED.Desc() + ": Some Cool Title Name Here",
+		"To: one@localhost, two@localhost",
+		"multipart/alternative",
+		"text/plain",
+		"text/html",
+		"Path: /this/is/a/path",
+		"<h3>" + EXTRACTED.Desc() + ": Some Cool Title Name Here</h3>",
+	} {
+		if !strings.Contains(received, expect) {
+			t.Fatalf("expected the email to contain '%s', got:\n%s", expect, received)
+		}
+	}
+
+	if sends, fails := unpackerr.EmailCounts(); sends != 1 || fails != 0 {
+		t.Fatalf("expected 1 email sent, got %d sent and %d failed", sends, fails)
+	}
+}
+
+func TestEmailDigest(t *testing.T) {
+	t.Parallel()
+
+	unpackerr, messages := testEmail(t)
+	email := unpackerr.Email[0]
+
+	var payloads []*WebhookPayload
+
+	for _, event := range []ExtractStatus{QUEUED, EXTRACTING, EXTRACTED} {
+		payload := samplePayload()
+		payload.Event = event
+		payloads = append(payloads, payload)
+	}
+
+	msg, err := email.Message(time.Now(), payloads...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := email.Send(msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	received := <-messages
+	if !strings.Contains(received, "Subject: Unpackerr: 3 events") {
+		t.Fatalf("expected a digest subject, got:\n%s", received)
+	}
+
+	for _, event := range payloads {
+		if !strings.Contains(received, event.Event.Desc()) {
+			t.Fatalf("expected the digest to contain '%s', got:\n%s", event.Event.Desc(), received)
+		}
+	}
+}
+
+func TestEmailValidate(t *testing.T) {
+	t.Parallel()
+
+	unpackerr := &Unpackerr{Config: &Config{Email: []*EmailConfig{
+		{Host: "smtp.example.com", To: StringSlice{"me@example.com"}, Username: "user@example.com"},
+		{Host: "smtp.example.com", To: StringSlice{"me@example.com"}, TLS: "TLS"},
+	}}}
+
+	if err := unpackerr.validateEmail(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if email := unpackerr.Email[0]; email.Port != smtpStartTLSPort || email.From != "user@example.com" {
+		t.Fatalf("expected port %d and from set to the username, got: %d, %s", smtpStartTLSPort, email.Port, email.From)
+	}
+
+	if email := unpackerr.Email[1]; email.Port != smtpTLSPort || email.TLS != EmailTLS {
+		t.Fatalf("expected port %d with implicit tls, got: %d, %s", smtpTLSPort, email.Port, email.TLS)
+	}
+
+	unpackerr.Email = []*EmailConfig{{Host: "smtp.example.com"}}
+	if err := unpackerr.validateEmail(); err == nil {
+		t.Fatal("expected an error without a to address")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/bytefmt"
@@ -39,6 +40,13 @@ type FolderConfig struct {
 	DisableRecursion bool           `json:"disableRecursion" toml:"disable_recursion" xml:"disable_recursion" yaml:"disableRecursion"`
 	ExcludePaths     []string       `json:"exclude_paths"    toml:"exclude_paths"     xml:"exclude_path"      yaml:"exclude_paths"`
 	Path             string         `json:"path"             toml:"path"              xml:"path"              yaml:"path"`
+	MinSize          ByteSize       `json:"min_size"         toml:"min_size"          xml:"min_size"          yaml:"min_size"`
+	MaxSize          ByteSize       `json:"max_size"         toml:"max_size"          xml:"max_size"          yaml:"max_size"`
+	CompanionFiles   string         `json:"companion_files"  toml:"companion_files"   xml:"companion_files"   yaml:"companion_files"`
+	CompanionGlobs   []string       `json:"companion_globs"  toml:"companion_globs"   xml:"companion_glob"    yaml:"companion_globs"`
+	Report           bool           `json:"report"           toml:"report"            xml:"report"            yaml:"report"`
+	Passwords        StringSlice    `json:"passwords"        toml:"passwords"         xml:"password"          yaml:"passwords"`
+	passwords        *passwordList
 }
 
 // Folders holds all known (created) folders in all watch paths.
@@ -51,6 +59,7 @@ type Folders struct {
 	Updates  chan *xtractr.Response
 	FSNotify *fsnotify.Watcher
 	Watcher  *watcher.Watcher
+	watching atomic.Bool // watchFSNotify is running.
 }
 
 // Logs interface for folders.
@@ -62,12 +71,15 @@ type Logs interface {
 
 // Folder is a "new" watched folder.
 type Folder struct {
+	tracked  time.Time // The first event, which starts the item's trace.
 	updated  time.Time
 	status   ExtractStatus
 	config   *FolderConfig
 	files    []string
 	retries  uint
 	archives xtractr.ArchiveList
+	gated    bool   // The pre_extract gates passed.
+	output   string // Output from the pre_extract gates.
 }
 
 type eventData struct {
@@ -83,6 +95,10 @@ func (u *Unpackerr) validateFolders() error {
 			// If delete after wasn't set, then set it to 10 minutes.
 			u.Folders[idx].DeleteAfter = &cnfg.Duration{Duration: defaultFolderDelete}
 		}
+
+		if err := u.Folders[idx].validateCompanions(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -96,9 +112,9 @@ func (u *Unpackerr) logFolders() {
 		}
 
 		u.Printf(" => Folder Config: 1 path: %s%s; delete_after:%v delete_orig:%v delete_files:%v "+
-			"log_file:%v move_back:%v isos:%v event_buffer:%d",
-			folder.Path, epath, folder.DeleteAfter, folder.DeleteOrig, folder.DeleteFiles,
-			!folder.DisableLog, folder.MoveBack, folder.ExtractISOs, u.Folder.Buffer)
+			"log_file:%v move_back:%v isos:%v min_size:%v max_size:%v companions:%s event_buffer:%d",
+			folder.Path, epath, folder.DeleteAfter, folder.DeleteOrig, folder.DeleteFiles, !folder.DisableLog,
+			folder.MoveBack, folder.ExtractISOs, folder.MinSize, folder.MaxSize, folder.CompanionFiles, u.Folder.Buffer)
 	} else {
 		u.Printf(" => Folder Config: %d paths, event_buffer:%d ", count, u.Folder.Buffer)
 
@@ -107,9 +123,10 @@ func (u *Unpackerr) logFolders() {
 				epath = " extract to: " + folder.ExtractPath
 			}
 
-			u.Printf(" =>    Path: %s%s; delete_after:%v delete_orig:%v delete_files:%v log_file:%v move_back:%v isos:%v",
-				folder.Path, epath, folder.DeleteAfter, folder.DeleteOrig, folder.DeleteFiles,
-				!folder.DisableLog, folder.MoveBack, folder.ExtractISOs)
+			u.Printf(" =>    Path: %s%s; delete_after:%v delete_orig:%v delete_files:%v log_file:%v move_back:%v isos:%v "+
+				"min_size:%v max_size:%v companions:%s", folder.Path, epath, folder.DeleteAfter, folder.DeleteOrig,
+				folder.DeleteFiles, !folder.DisableLog, folder.MoveBack, folder.ExtractISOs, folder.MinSize,
+				folder.MaxSize, folder.CompanionFiles)
 		}
 	}
 }
@@ -129,7 +146,7 @@ func (u *Unpackerr) PollFolders() {
 
 	u.Folders, flist = checkFolders(u.Folders, u.Logger)
 
-	u.folders, err = u.Folder.newWatcher(u.Folders, u.Logger)
+	u.folders, err = u.Folder.newWatcher(u.Folders, u.Logger.subsystem(logFolders))
 	if err != nil {
 		u.Errorf("Watching Folders: %s", err)
 		return
@@ -310,45 +327,84 @@ func (f *Folders) Remove(folder string) {
 func (u *Unpackerr) extractTrackedItem(name string, folder *Folder, now time.Time) {
 	u.folders.Remove(name) // stop the fs watcher(s).
 	// update status.
+	since := folder.updated
 	u.folders.Folders[name].updated = now
 	u.folders.Folders[name].status = QUEUED
 
 	// Do not extract r00 file if rar file with same name exists.
 	if strings.HasSuffix(strings.ToLower(name), ".r00") &&
 		xtractr.CheckR00ForRarFile(getFileList(filepath.Dir(name)), filepath.Base(name)) {
-		u.Printf("[Folder] Removing tracked item without extraction: %v (rar file exists)", name)
+		u.itemLog(name, nil).Printf("[Folder] Removing tracked item without extraction: %v (rar file exists)", name)
 		u.folders.Folders[name].status = EXTRACTEDNOTHING
 
 		return
 	}
 
+	exclude := folderExcludeSuffixes(name, folder.config)
+	files := xtractr.FindCompressedFiles(xtractr.Filter{Path: name, ExcludeSuffix: exclude})
+
+	if reason := checkSize(files, folder.config.MinSize, folder.config.MaxSize); reason != "" {
+		u.itemLog(name, nil).Printf("[Folder] Extraction Skipped: %s, %s", name, reason)
+		u.folders.Folders[name].status = EXTRACTSKIPPED
+		// Track the item without a webhook, then send one for the skip.
+		u.updateQueueStatus(&newStatus{Name: name, Status: QUEUED}, now, false).Skipped = reason
+		u.updateQueueStatus(&newStatus{Name: name, Status: EXTRACTSKIPPED}, now, true)
+
+		return
+	}
+
+	gate := &Extract{Path: name, App: FolderString, Status: QUEUED, Updated: now, IDs: map[string]any{"title": name}}
+	if !folder.gated && u.preExtractGates(name, gate) {
+		return // The gates call back into the main go routine when they finish.
+	}
+
+	folder.gated = false
 	// create a queue counter in the main history; add to u.Map and send webhook for a new folder.
-	item := u.updateQueueStatus(&newStatus{Name: name, Status: QUEUED}, u.folders.Folders[name].updated, true)
-	u.updateHistory(FolderString + ": " + name)
+	item := u.updateQueueStatus(&newStatus{Name: name, Status: QUEUED}, u.folders.Folders[name].updated, false)
+	item.HookOutput, folder.output = folder.output, ""
+	item.Report = folder.config.Report
 
-	exclude := folderExcludeSuffixes(name, folder.config)
+	if item.trace == nil {
+		u.traceItem(name, item, folder.tracked)
+	}
+
+	item.trace.queued(since, now)
+	u.runAllHooks(item)
+	u.updateHistory(FolderString + ": " + name)
 
 	// extract it.
-	queueSize, err := u.Extract(&xtractr.Xtract{
-		Password:         u.getPasswordFromPath(name),
-		Passwords:        u.Passwords,
+	item.probe = u.passwordCandidates(name, folder.config.passwords, FolderString)
+	xFile := &xtractr.Xtract{
+		Passwords:        item.probe.Passwords(),
 		Name:             name,
 		Filter:           xtractr.Filter{Path: name, ExcludeSuffix: exclude},
 		TempFolder:       !folder.config.MoveBack,
 		ExtractTo:        folder.config.ExtractPath,
 		DeleteOrig:       false,
 		CBChannel:        u.folders.Updates,
-		CBFunction:       nil,
 		Progress:         u.progressUpdateCallback(item),
 		LogFile:          !folder.config.DisableLog,
 		DisableRecursion: folder.config.DisableRecursion,
-	})
+	}
+
+	// The post_extract gates run first, so nothing is mirrored into the output of a failed extraction.
+	// A failed gate deletes the output folder, or the files moved back into the watched folder.
+	xFile.CBFunction = chainCallbacks(u.postExtractGates(item), u.gateFailedCallback(item))
+
+	if folder.config.CompanionFiles != CompanionNone && !folder.config.MoveBack {
+		// Mirror the non-archive files into the extraction output.
+		xFile.CBFunction = chainCallbacks(xFile.CBFunction, u.companionFilesCallback(folder.config))
+	}
+
+	xFile.CBFunction = chainCallbacks(xFile.CBFunction, passwordCallback(item.probe))
+
+	queueSize, err := u.Extract(xFile)
 	if err != nil {
 		u.Errorf("[ERROR] %v", err)
 		return
 	}
 
-	u.Printf("[Folder] Queued: %s, queue size: %d", name, queueSize)
+	u.itemLog(name, item).Printf("[Folder] Queued: %s, queue size: %d", name, queueSize)
 }
 
 // folderExcludeSuffixes returns archive suffixes to ignore when scanning for items to extract.
@@ -395,7 +451,12 @@ func getFileList(path string) []os.FileInfo {
 func (u *Unpackerr) folderXtractrCallback(resp *xtractr.Response) {
 	folder, ok := u.folders.Folders[resp.X.Name]
 
-	switch item := u.Map[resp.X.Name]; {
+	item := u.Map[resp.X.Name]
+	if item != nil && resp.Done {
+		item.gateFinished()
+	}
+
+	switch {
 	case !ok, item == nil:
 		// It doesn't exist? weird. delete it and bail out.
 		delete(u.folders.Folders, resp.X.Name)
@@ -405,19 +466,25 @@ func (u *Unpackerr) folderXtractrCallback(resp *xtractr.Response) {
 	case !resp.Done:
 		item.XProg.Archives = resp.Archives.Count() + resp.Extras.Count()
 		folder.status = EXTRACTING
-		u.Printf("[Folder] Extraction Started: %s, retries: %d, items in queue: %d", resp.X.Name, folder.retries, resp.Queued)
+		u.itemLog(resp.X.Name, item).status(folder.status).Printf(
+			"[Folder] Extraction Started: %s, retries: %d, items in queue: %d",
+			resp.X.Name, folder.retries, resp.Queued)
 	case errors.Is(resp.Error, xtractr.ErrNoCompressedFiles):
 		folder.status = EXTRACTEDNOTHING
-		u.Printf("[Folder] %s: %s: %v", folder.status.Desc(), resp.X.Name, resp.Error)
+		u.itemLog(resp.X.Name, item).status(folder.status).Printf("[Folder] %s: %s: %v",
+			folder.status.Desc(), resp.X.Name, resp.Error)
 	case resp.Error != nil:
 		folder.archives = resp.Archives
 		folder.status = EXTRACTFAILED
-		u.Errorf("[Folder] %s: %s: %v", folder.status.Desc(), resp.X.Name, resp.Error)
+		u.itemLog(resp.X.Name, item).status(folder.status).elapsed(resp.Elapsed).Errorf("[Folder] %s: %s: %v",
+			folder.status.Desc(), resp.X.Name, resp.Error)
 		u.updateMetrics(resp, FolderString, folder.config.Path)
 	default: // this runs in a go routine
 		u.updateMetrics(resp, FolderString, folder.config.Path)
-		u.Printf("[Folder] Extraction Finished: %s => elapsed: %v, archives: %d, "+
-			"extra archives: %d, files extracted: %d, written: %sB",
+		u.logPassword(resp.X.Name, item)
+		u.itemLog(resp.X.Name, item).status(EXTRACTED).elapsed(resp.Elapsed).Printf(
+			"[Folder] Extraction Finished: %s => elapsed: %v, archives: %d, "+
+				"extra archives: %d, files extracted: %d, written: %sB",
 			resp.X.Name, resp.Elapsed.Round(time.Second), resp.Archives.Count(),
 			resp.Extras.Count(), len(resp.NewFiles), bytefmt.ByteSize(resp.Size))
 
@@ -427,12 +494,25 @@ func (u *Unpackerr) folderXtractrCallback(resp *xtractr.Response) {
 	}
 
 	folder.updated = resp.Started.Add(resp.Elapsed)
+
+	if resp.Done {
+		item.trace.extracted(resp, folder.updated)
+	} else {
+		item.trace.extracting(resp)
+	}
+
 	u.updateQueueStatus(&newStatus{Name: resp.X.Name, Resp: resp, Status: folder.status}, folder.updated, true)
+
+	if folder.status == EXTRACTED || folder.status == EXTRACTFAILED {
+		u.writeReport(resp.X.Name, item, resp)
+	}
 }
 
 // watchFSNotify reads file system events from a channel and processes them.
 // This runs in its own go routine, and eventually sends the event back into the main routine.
 func (f *Folders) watchFSNotify() {
+	f.watching.Store(true)
+	defer f.watching.Store(false)
 	defer log.Println("Folder watcher routine exited. No longer watching any folders.")
 
 	for {
@@ -553,6 +633,7 @@ func (f *Folders) saveEvent(event *eventData, dirPath string, now time.Time) {
 	f.Printf("[Folder] Tracking New Item: %v (event: %s)", dirPath, event.op)
 
 	f.Folders[dirPath] = &Folder{
+		tracked: now,
 		updated: now,
 		status:  WAITING,
 		config:  event.cnfg,
@@ -567,20 +648,24 @@ func (u *Unpackerr) checkFolderStats(now time.Time) {
 		case WAITING == folder.status && elapsed >= u.StartDelay.Duration:
 			// The folder hasn't been written to in a while, extract it.
 			u.extractTrackedItem(name, folder, now)
-		case EXTRACTEDNOTHING == folder.status:
+		case EXTRACTEDNOTHING == folder.status, EXTRACTSKIPPED == folder.status:
 			// Wait until this item hasn't been touched for a while, so it doesn't re-queue.
 			if now.Sub(folder.updated) > u.StartDelay.Duration {
-				// Ignore "no compressed files" errors for folders.
+				// Ignore "no compressed files" errors and skipped items for folders.
+				u.finishTrace(u.Map[name], now)
+				u.saveHistory(name, u.Map[name], now)
 				delete(u.Map, name)
 				delete(u.folders.Folders, name)
 			}
 		case EXTRACTFAILED == folder.status && elapsed >= u.RetryDelay.Duration &&
 			(u.MaxRetries == 0 || folder.retries < u.MaxRetries):
 			u.Retries++
+			u.retryMetrics(FolderString, folder.config.Path)
 			folder.retries++
 			folder.updated = now
 			folder.status = WAITING
-			u.Printf("[Folder] Re-starting Failed Extraction: %s (%d/%d, failed %v ago)",
+			u.itemLog(name, u.Map[name]).elapsed(elapsed).Printf(
+				"[Folder] Re-starting Failed Extraction: %s (%d/%d, failed %v ago)",
 				folder.config.Path, folder.retries, u.MaxRetries, elapsed.Round(time.Second))
 		case EXTRACTFAILED == folder.status && folder.retries < u.MaxRetries:
 			// This empty block is to avoid deleting an item that needs more retries.
@@ -588,7 +673,8 @@ func (u *Unpackerr) checkFolderStats(now time.Time) {
 			// Retries exhausted — clean up to prevent the item from staying in the map forever.
 			u.updateQueueStatus(&newStatus{Name: name, Status: DELETED, Resp: nil}, now, true)
 			delete(u.folders.Folders, name)
-			u.Printf("[Folder] Retries exhausted (%d/%d), giving up: %s", folder.retries, u.MaxRetries, name)
+			u.itemLog(name, u.Map[name]).Printf("[Folder] Retries exhausted (%d/%d), giving up: %s",
+				folder.retries, u.MaxRetries, name)
 		case folder.status > EXTRACTING && folder.config.DeleteAfter.Duration <= 0:
 			// if DeleteAfter is 0 we don't delete anything. we are done.
 			u.updateQueueStatus(&newStatus{Name: name, Status: DELETED, Resp: nil}, now, false)
@@ -604,21 +690,46 @@ func (u *Unpackerr) deleteAfterReached(name string, now time.Time, folder *Folde
 	var webhook bool
 	// Folder reached delete delay (after extraction), nuke it.
 	if folder.config.DeleteFiles && !folder.config.MoveBack {
-		u.delChan <- &fileDeleteReq{Paths: []string{strings.TrimRight(name, `/\`) + suffix}}
+		paths := []string{strings.TrimRight(name, `/\`) + suffix}
+		if folder.config.CompanionFiles != CompanionNone {
+			// Mirrored companion files may be outside the output folder (in extract_path), so delete them too.
+			paths = append(paths, folder.files...)
+		}
+
+		u.delChan <- &fileDeleteReq{
+			Paths: paths,
+			App:   FolderString,
+			URL:   folder.config.Path,
+			span:  u.Map[name].span(),
+		}
 		webhook = true
 	} else if folder.config.DeleteFiles && len(folder.files) > 0 {
-		u.delChan <- &fileDeleteReq{Paths: folder.files}
+		u.delChan <- &fileDeleteReq{Paths: folder.files, App: FolderString, URL: folder.config.Path, span: u.Map[name].span()}
 		webhook = true
 	}
 
 	if folder.config.DeleteOrig && !folder.config.MoveBack {
-		u.delChan <- &fileDeleteReq{Paths: []string{name}}
+		u.delChan <- &fileDeleteReq{
+			Paths: []string{name},
+			App:   FolderString,
+			URL:   folder.config.Path,
+			span:  u.Map[name].span(),
+		}
 		webhook = true
 	} else if folder.config.DeleteOrig && len(folder.archives) > 0 {
-		u.delChan <- &fileDeleteReq{Paths: folder.archives.List()}
+		u.delChan <- &fileDeleteReq{
+			Paths: folder.archives.List(),
+			App:   FolderString,
+			URL:   folder.config.Path,
+			span:  u.Map[name].span(),
+		}
 		webhook = true
 	}
 
+	if webhook {
+		u.itemLog(name, u.Map[name]).status(DELETED).in(logFolders).Debugf("[Folder] Deleting files for: %s", name)
+	}
+
 	u.updateQueueStatus(&newStatus{Name: name, Status: DELETED, Resp: nil}, now, webhook)
 	// Folder reached delete delay (after extraction), nuke it.
 	delete(u.folders.Folders, name)
@@ -658,6 +769,7 @@ func (u *Unpackerr) updateQueueStatus(data *newStatus, now time.Time, sendHook b
 		u.Map[data.Name].Resp = data.Resp
 	}
 
+	u.statusMetrics(data.Name, u.Map[data.Name], u.Map[data.Name].Status, data.Status, now)
 	u.Map[data.Name].Status = data.Status
 	u.Map[data.Name].Updated = now
 
@@ -1,8 +1,11 @@
 package unpackerr
 
 import (
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -200,3 +203,33 @@ func newTestFolders(t *testing.T, cfg *FolderConfig) *Folders {
 
 	return folders
 }
+
+func TestDeleteAfterReachedFiles(t *testing.T) {
+	t.Parallel()
+
+	for companions, expect := range map[string][]string{
+		// Without companion files, only the output folder is deleted, like it always has been.
+		CompanionNone: {"/watch/item_unpackerred"},
+		CompanionCopy: {"/watch/item_unpackerred", "/extract/item/movie.mkv", "/extract/item/movie.nfo"},
+	} {
+		discard := log.New(io.Discard, "", 0)
+		unpackerr := &Unpackerr{
+			Config:  &Config{},
+			Logger:  &Logger{Info: discard, Error: discard, Debug: discard},
+			History: &History{Map: map[string]*Extract{"/watch/item/": {App: FolderString, Status: EXTRACTED}}},
+			delChan: make(chan *fileDeleteReq, 1),
+			folders: &Folders{Folders: map[string]*Folder{}},
+		}
+		folder := &Folder{
+			status: EXTRACTED,
+			files:  []string{"/extract/item/movie.mkv", "/extract/item/movie.nfo"},
+			config: &FolderConfig{DeleteFiles: true, CompanionFiles: companions},
+		}
+
+		unpackerr.deleteAfterReached("/watch/item/", time.Now(), folder)
+
+		if req := <-unpackerr.delChan; !slices.Equal(req.Paths, expect) {
+			t.Fatalf("companion_files=%s: expected %q to be deleted, got: %q", companions, expect, req.Paths)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package unpackerr
+
+/* Gate hooks are command hooks that run synchronously and may veto an extraction.
+   A pre_extract gate runs before an item is queued for extraction. When it fails the
+   item is deferred until the next check, or skipped. A post_extract gate runs after the
+   files are extracted, before they are moved into place and before the item is marked extracted.
+   When it fails the extraction fails, and the extracted files are deleted. */
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golift.io/xtractr"
+)
+
+// These are the allowed values for a command hook's gate.
+const (
+	GatePreExtract  = "pre_extract"
+	GatePostExtract = "post_extract"
+)
+
+// ErrInvalidGate is returned when a command hook has an unknown gate.
+var ErrInvalidGate = errors.New("invalid cmdhook gate")
+
+// gateResult is sent back into the main go routine when the pre_extract gates finish.
+type gateResult struct {
+	Name   string
+	Output string
+	Skip   bool // A failed gate wants the item skipped instead of deferred.
+	Error  error
+}
+
+// gateOutput collects the output of post_extract gates in the extraction go routine.
+// The main go routine reads it after the finished extraction's callback arrives.
+type gateOutput struct {
+	output string
+	failed string // The name of the gate that failed the extraction.
+}
+
+// validateGate makes sure a command hook's gate is valid. Gates run for every item
+// their filters allow, so events do not apply to them; validateCmdhook warns when they're set.
+func (w *WebhookConfig) validateGate() error {
+	switch w.Gate = strings.ToLower(strings.TrimSpace(w.Gate)); w.Gate {
+	case "":
+		return nil
+	case GatePreExtract, GatePostExtract:
+		w.Events = ExtractStatuses{WAITING}
+		return nil
+	default:
+		return fmt.Errorf("%w: %s: '%s', must be %s or %s", ErrInvalidGate, w.Name, w.Gate, GatePreExtract, GatePostExtract)
+	}
+}
+
+// gateHooks returns the command hooks for a gate that want a payload.
+func (u *Unpackerr) gateHooks(gate string, payload *WebhookPayload) []*WebhookConfig {
+	var hooks []*WebhookConfig
+
+	for _, hook := range u.Cmdhook {
+		if hook.Gate == gate && hook.Wants(payload) {
+			hooks = append(hooks, hook)
+		}
+	}
+
+	return hooks
+}
+
+// runGates runs gate hooks in order, and stops at the first one that fails.
+// The output from every gate that ran is returned, prefixed with the hook name.
+func (u *Unpackerr) runGates(hooks []*WebhookConfig, payload *WebhookPayload) (string, *WebhookConfig, error) {
+	var output []string
+
+	for _, hook := range hooks {
+		hookPayload := *payload // runCmdhook puts the config into the payload.
+		span := payload.span.child("cmdhook", spanKindInternal, time.Now(),
+			slog.String("hook", hook.Name), slog.String("gate", hook.Gate))
+		out, err := u.runCmdhook(hook, &hookPayload)
+		span.finish(time.Now(), err)
+
+		if out != nil && strings.TrimSpace(out.String()) != "" {
+			output = append(output, hook.Name+": "+strings.TrimSpace(out.String()))
+		}
+
+		hook.Lock()
+		hook.posts++
+
+		if err != nil {
+			hook.fails++
+			hook.Unlock()
+			u.Errorf("[Cmdhook] %s Gate %s failed for %s: %v", hook.Gate, hook.Name, payload.Path, err)
+
+			return strings.Join(output, "\n"), hook, err
+		}
+
+		hook.Unlock()
+		u.debugf(logHooks, "[Cmdhook] %s Gate %s passed for %s", hook.Gate, hook.Name, payload.Path)
+	}
+
+	return strings.Join(output, "\n"), nil, nil
+}
+
+// preExtractGates starts the pre_extract gates for an item in a go routine, and returns true
+// if any gates will run. The result is sent back into the main go routine by the gates channel.
+func (u *Unpackerr) preExtractGates(name string, item *Extract) bool {
+	payload := newPayload(item)
+
+	hooks := u.gateHooks(GatePreExtract, payload)
+	if len(hooks) == 0 {
+		return false
+	}
+
+	go func() {
+		output, hook, err := u.runGates(hooks, payload)
+		u.gates <- &gateResult{Name: name, Output: output, Skip: hook != nil && hook.GateSkip, Error: err}
+	}()
+
+	return true
+}
+
+// postExtractCallback returns an xtractr callback that runs the post_extract gates for a payload.
+// This runs in the extraction go routine. A failed gate fails the extraction.
+func (u *Unpackerr) postExtractCallback(payload *WebhookPayload, result *gateOutput) func(*xtractr.Response) {
+	return func(resp *xtractr.Response) {
+		if !resp.Done || resp.Error != nil {
+			return
+		}
+
+		payload.Event = EXTRACTED
+		payload.Time = resp.Started.Add(resp.Elapsed)
+		payload.Data = newXtractPayload(resp)
+
+		hooks := u.gateHooks(GatePostExtract, payload)
+		if len(hooks) == 0 {
+			return
+		}
+
+		output, hook, err := u.runGates(hooks, payload)
+		result.output = output
+
+		if err != nil {
+			result.failed = hook.Name
+			resp.Error = fmt.Errorf("%s gate %s: %w", GatePostExtract, hook.Name, err)
+		}
+	}
+}
+
+// postExtractGates returns an xtractr callback for the post_extract gates, or nil if there are none.
+// The item's gate output is reset here, and filled in by the callback.
+func (u *Unpackerr) postExtractGates(item *Extract) func(*xtractr.Response) {
+	item.gate = nil
+
+	for _, hook := range u.Cmdhook {
+		if hook.Gate == GatePostExtract {
+			item.gate = &gateOutput{}
+			return u.postExtractCallback(newPayload(item), item.gate)
+		}
+	}
+
+	return nil
+}
+
+// gateFailedCallback returns an xtractr callback that deletes the extracted files after a post_extract
+// gate failed the extraction, so nothing picks them up. Files moved back into the source folder are
+// deleted one by one; an output folder of its own is deleted whole. Chain it after postExtractGates.
+func (u *Unpackerr) gateFailedCallback(item *Extract) func(*xtractr.Response) {
+	gate, app := item.gate, item.App
+	if gate == nil {
+		return nil // No post_extract gates.
+	}
+
+	return func(resp *xtractr.Response) {
+		switch {
+		case !resp.Done || gate.failed == "":
+			return
+		case !resp.X.TempFolder:
+			u.Printf("[%s] Deleting %d files extracted before failed %s gate %s: %s",
+				app, len(resp.NewFiles), GatePostExtract, gate.failed, resp.X.Path)
+			u.Xtractr.DeleteFiles(resp.NewFiles...)
+		case resp.Output != "" && filepath.Clean(resp.Output) != filepath.Clean(resp.X.Path):
+			u.Printf("[%s] Deleting output of failed %s gate %s: %s", app, GatePostExtract, gate.failed, resp.Output)
+			u.Xtractr.DeleteFiles(resp.Output)
+		}
+	}
+}
+
+// gateFinished copies the post_extract gate output into an item after its extraction finished.
+func (item *Extract) gateFinished() {
+	if item.gate == nil || item.gate.output == "" {
+		return
+	}
+
+	if item.HookOutput != "" {
+		item.HookOutput += "\n"
+	}
+
+	item.HookOutput += item.gate.output
+	item.gate = nil
+}
+
+// handleGateResult runs in the main go routine when the pre_extract gates for an item finish.
+// Items that passed are queued for extraction, and failed items are deferred or skipped.
+func (u *Unpackerr) handleGateResult(gate *gateResult, now time.Time) {
+	name, item := gate.Name, u.Map[gate.Name]
+	if item == nil || item.App == FolderString {
+		if folder := u.folders.Folders[name]; folder != nil && folder.status == QUEUED {
+			u.folderGateResult(gate, folder, now)
+		}
+
+		return
+	}
+
+	if item.Status != QUEUED {
+		return // The item changed while the gates ran.
+	}
+
+	item.HookOutput = gate.Output
+
+	switch {
+	case gate.Error == nil:
+		item.gated = true
+		item.Status = WAITING
+		u.extractCompletedDownload(name, now, item)
+	case gate.Skip:
+		item.Skipped = fmt.Sprintf("%s gate: %v", GatePreExtract, gate.Error)
+		u.itemLog(name, item).Printf("[%s] Extraction Skipped: %s, %s", item.App, name, item.Skipped)
+		u.updateQueueStatus(&newStatus{Name: name, Status: EXTRACTSKIPPED}, now, true)
+	default:
+		// Start over; the item is checked again after the start delay, so a failing gate does not run every poll.
+		item.Status = WAITING
+		item.Updated = now
+		u.itemLog(name, item).Printf("[%s] Extraction Deferred: %s, %s gate: %v", item.App, name, GatePreExtract, gate.Error)
+	}
+}
+
+// folderGateResult queues, defers or skips a watched folder item when its pre_extract gates finish.
+func (u *Unpackerr) folderGateResult(gate *gateResult, folder *Folder, now time.Time) {
+	switch {
+	case gate.Error == nil:
+		folder.gated = true
+		folder.output = gate.Output
+		u.extractTrackedItem(gate.Name, folder, now)
+	case gate.Skip:
+		reason := fmt.Sprintf("%s gate: %v", GatePreExtract, gate.Error)
+		u.itemLog(gate.Name, nil).Printf("[Folder] Extraction Skipped: %s, %s", gate.Name, reason)
+		folder.status = EXTRACTSKIPPED
+		item := u.updateQueueStatus(&newStatus{Name: gate.Name, Status: QUEUED}, now, false)
+		item.Skipped, item.HookOutput = reason, gate.Output
+		u.updateQueueStatus(&newStatus{Name: gate.Name, Status: EXTRACTSKIPPED}, now, true)
+	default:
+		// Start over; the folder is checked again after the start delay.
+		folder.status = WAITING
+		folder.updated = now
+		u.itemLog(gate.Name, nil).Printf("[Folder] Extraction Deferred: %s, %s gate: %v",
+			gate.Name, GatePreExtract, gate.Error)
+	}
+}
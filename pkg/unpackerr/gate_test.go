@@ -0,0 +1,261 @@
+package unpackerr
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golift.io/cnfg"
+	"golift.io/xtractr"
+)
+
+func gateUnpackerr(t *testing.T, hooks ...*WebhookConfig) *Unpackerr {
+	t.Helper()
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config:  &Config{Timeout: cnfg.Duration{Duration: 10 * time.Second}, Cmdhook: hooks},
+		Logger:  &Logger{Info: discard, Error: discard, Debug: discard},
+		History: &History{Map: map[string]*Extract{}},
+		gates:   make(chan *gateResult, 1),
+	}
+
+	if err := unpackerr.validateCmdhook(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return unpackerr
+}
+
+func TestPreExtractGates(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == windows {
+		t.Skip("uses /bin/sh")
+	}
+
+	vpn := &WebhookConfig{Name: "vpn", Command: `echo "vpn down for $UN_PATH"; exit 1`, Shell: true, Gate: "Pre_Extract"}
+	unpackerr := gateUnpackerr(t, vpn)
+
+	if vpn.Gate != GatePreExtract || vpn.queue != nil {
+		t.Fatalf("expected a normalized gate without a queue, got: %s", vpn.Gate)
+	}
+
+	item := &Extract{Path: "/downloads/Movie", App: "Radarr", Status: QUEUED, IDs: map[string]any{}}
+	unpackerr.Map["Movie"] = item
+
+	if !unpackerr.preExtractGates("Movie", item) {
+		t.Fatal("expected the gate to run")
+	}
+
+	unpackerr.handleGateResult(<-unpackerr.gates, time.Now())
+
+	if item.Status != WAITING || item.HookOutput != "vpn: vpn down for /downloads/Movie" {
+		t.Fatalf("expected the item to be deferred with the gate output, got: %s: %q", item.Status, item.HookOutput)
+	}
+
+	if item.Updated.IsZero() {
+		t.Fatal("expected a deferred item to wait for the start delay again")
+	}
+
+	vpn.GateSkip = true
+	item.Status = QUEUED
+
+	unpackerr.preExtractGates("Movie", item)
+	unpackerr.handleGateResult(<-unpackerr.gates, time.Now())
+
+	if item.Status != EXTRACTSKIPPED || !strings.HasPrefix(item.Skipped, GatePreExtract+" gate:") {
+		t.Fatalf("expected the item to be skipped, got: %s: %s", item.Status, item.Skipped)
+	}
+
+	if newPayload(item).Data.HookOutput != item.HookOutput {
+		t.Fatal("expected the gate output in the payload")
+	}
+
+	if err := (&WebhookConfig{Name: "x", Gate: "during"}).validateGate(); err == nil {
+		t.Fatal("expected an error with an invalid gate")
+	}
+}
+
+func TestGateEventsWarning(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	hook := &WebhookConfig{Name: "vpn", Command: "true", Gate: GatePreExtract, Events: ExtractStatuses{EXTRACTED}}
+	unpackerr := &Unpackerr{
+		Config: &Config{Cmdhook: []*WebhookConfig{hook}},
+		Logger: &Logger{Info: log.New(&buf, "", 0)},
+	}
+
+	if err := unpackerr.validateCmdhook(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "WARNING: vpn: events do not apply to gates") {
+		t.Fatalf("expected a warning for events on a gate, got: %s", buf.String())
+	}
+
+	if len(hook.Events) != 1 || hook.Events[0] != WAITING {
+		t.Fatalf("expected the gate events to be replaced, got: %v", hook.Events)
+	}
+}
+
+func TestPostExtractGates(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == windows {
+		t.Skip("uses /bin/sh")
+	}
+
+	scan := &WebhookConfig{Command: `echo "scanned $UN_DATA_BYTES bytes"; [ "$SCAN" = clean ]`, Shell: true,
+		Gate: GatePostExtract, Env: map[string]string{"SCAN": "infected"}}
+	unpackerr := gateUnpackerr(t, scan)
+	item := &Extract{Path: "/downloads/Show", App: "Sonarr", Status: QUEUED, IDs: map[string]any{}}
+
+	callback := unpackerr.postExtractGates(item)
+	if callback == nil {
+		t.Fatal("expected a post_extract callback")
+	}
+
+	// The start callback does nothing.
+	callback(&xtractr.Response{X: &xtractr.Xtract{Name: "Show"}})
+
+	resp := &xtractr.Response{Done: true, Size: 1234, X: &xtractr.Xtract{Name: "Show"}}
+	if callback(resp); resp.Error == nil {
+		t.Fatal("expected a failed gate to fail the extraction")
+	}
+
+	if item.gateFinished(); item.HookOutput != "echo: scanned 1234 bytes" {
+		t.Fatalf("unexpected gate output: %q", item.HookOutput)
+	}
+
+	scan.Env["SCAN"] = "clean"
+	resp = &xtractr.Response{Done: true, X: &xtractr.Xtract{Name: "Show"}}
+
+	if unpackerr.postExtractGates(item)(resp); resp.Error != nil {
+		t.Fatalf("expected a passing gate to leave the extraction alone: %v", resp.Error)
+	}
+
+	if posts, fails := scan.Counts(); posts != 2 || fails != 1 {
+		t.Fatalf("expected the gate runs to be counted, got: %d runs, %d failures", posts, fails)
+	}
+}
+
+func TestPostExtractGateBeforeMove(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == windows {
+		t.Skip("uses /bin/sh")
+	}
+
+	scan := &WebhookConfig{Name: "scan", Command: `[ -f "$STAGED" ]; [ "$SCAN" = clean ]`, Shell: true,
+		Gate: GatePostExtract, Env: map[string]string{"SCAN": "infected"}}
+	unpackerr := gateUnpackerr(t, scan)
+	unpackerr.DirMode = "755"
+	unpackerr.Xtractr = xtractr.NewQueue(&xtractr.Config{Parallel: 1, DirMode: defaultDirMode})
+	t.Cleanup(func() { unpackerr.Xtractr.Stop() })
+
+	download, staging := t.TempDir(), filepath.Join(t.TempDir(), "staging")
+	scan.Env["STAGED"] = filepath.Join(staging, "episode.mkv") // The gate sees the files before they move.
+	item := &Extract{Path: download, App: "Sonarr", Atomic: true, IDs: map[string]any{}, probe: &passwordProbe{}}
+
+	extract := func() *xtractr.Response {
+		return extractFolder(t, staging, scan.Env["STAGED"], unpackerr.extractCallbacks(item), download)
+	}
+
+	if resp := extract(); resp.Error == nil {
+		t.Fatal("expected a failed gate to fail the extraction")
+	}
+
+	if _, err := os.Stat(staging); !os.IsNotExist(err) {
+		t.Fatalf("expected the staging folder to be deleted after a failed gate: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(download, "episode.mkv")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing moved into the download after a failed gate: %v", err)
+	}
+
+	scan.Env["SCAN"] = "clean"
+	if resp := extract(); resp.Error != nil || resp.Output != download {
+		t.Fatalf("expected a passing gate to move the files into place: %s: %v", resp.Output, resp.Error)
+	}
+
+	// Watched folders delete their own output folder when a gate fails.
+	scan.Env["SCAN"] = "infected"
+	folder := &Extract{Path: download, App: FolderString, IDs: map[string]any{}}
+	callback := chainCallbacks(unpackerr.postExtractGates(folder), unpackerr.gateFailedCallback(folder))
+
+	resp := extractFolder(t, staging, scan.Env["STAGED"], callback, download)
+	if _, err := os.Stat(staging); resp.Error == nil || !os.IsNotExist(err) {
+		t.Fatalf("expected the output folder to be deleted after a failed gate: %v: %v", resp.Error, err)
+	}
+}
+
+// extractFolder writes a file into an output folder, and runs a callback like a finished extraction.
+func extractFolder(
+	t *testing.T, output, file string, callback func(*xtractr.Response), source string,
+) *xtractr.Response {
+	t.Helper()
+
+	if err := os.MkdirAll(output, defaultDirMode); err != nil {
+		t.Fatalf("making output folder: %v", err)
+	}
+
+	if err := os.WriteFile(file, []byte("video"), defaultFileMode); err != nil {
+		t.Fatalf("writing output file: %v", err)
+	}
+
+	resp := &xtractr.Response{Done: true, Output: output, X: &xtractr.Xtract{
+		Name: "Folder", TempFolder: true, Filter: xtractr.Filter{Path: source},
+	}}
+	callback(resp)
+
+	return resp
+}
+
+func TestPostExtractGateWithoutAtomic(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == windows {
+		t.Skip("uses /bin/sh")
+	}
+
+	scan := &WebhookConfig{Name: "scan", Command: "exit 1", Shell: true, Gate: GatePostExtract}
+	unpackerr := gateUnpackerr(t, scan)
+	unpackerr.Xtractr = xtractr.NewQueue(&xtractr.Config{Parallel: 1, DirMode: defaultDirMode})
+	t.Cleanup(func() { unpackerr.Xtractr.Stop() })
+
+	// Without atomic, the extracted files are moved back into the download folder before the gate runs.
+	download := t.TempDir()
+	archive, episode := filepath.Join(download, "episode.rar"), filepath.Join(download, "episode.mkv")
+
+	for _, file := range []string{archive, episode} {
+		if err := os.WriteFile(file, []byte("data"), defaultFileMode); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+
+	item := &Extract{Path: download, App: "Radarr", IDs: map[string]any{}, probe: &passwordProbe{}}
+	resp := &xtractr.Response{Done: true, Output: download + suffix, NewFiles: []string{episode},
+		X: &xtractr.Xtract{Name: "Movie", Filter: xtractr.Filter{Path: download}}}
+	unpackerr.extractCallbacks(item)(resp)
+
+	if resp.Error == nil {
+		t.Fatal("expected a failed gate to fail the extraction")
+	}
+
+	if _, err := os.Stat(episode); !os.IsNotExist(err) {
+		t.Fatalf("expected the extracted file to be deleted from the download after a failed gate: %v", err)
+	}
+
+	if _, err := os.Stat(archive); err != nil {
+		t.Fatalf("expected the archive to be kept: %v", err)
+	}
+}
@@ -25,6 +25,22 @@ type Extract struct {
 	Updated     time.Time
 	DeleteDelay time.Duration
 	DeleteOrig  bool
+	MinSize     ByteSize
+	MaxSize     ByteSize
+	ExtractPath string // Staging folder for atomic extractions.
+	Atomic      bool
+	Report      bool   // Write an extraction report beside the extracted files.
+	Skipped     string // Reason the extraction was skipped.
+	Password    string // Masked password that opened the archives.
+	PassSource  string // Where the password came from.
+	HookOutput  string // Output from the pre_extract and post_extract gate hooks.
+	passwords   *passwordList
+	probe       *passwordProbe
+	gate        *gateOutput
+	gated       bool // The pre_extract gates passed.
+	log         *ItemLog
+	trace       *itemTrace
+	summary     *StatsReport // Only on report events.
 	Status      ExtractStatus
 	IDs         map[string]any
 	Resp        *xtractr.Response
@@ -42,6 +58,13 @@ type StarrConfig struct {
 	Syncthing   bool          `json:"syncthing"    toml:"syncthing"    xml:"syncthing"    yaml:"syncthing"`
 	ValidSSL    bool          `json:"valid_ssl"    toml:"valid_ssl"    xml:"valid_ssl"    yaml:"valid_ssl"`
 	Timeout     cnfg.Duration `json:"timeout"      toml:"timeout"      xml:"timeout"      yaml:"timeout"`
+	MinSize     ByteSize      `json:"min_size"     toml:"min_size"     xml:"min_size"     yaml:"min_size"`
+	MaxSize     ByteSize      `json:"max_size"     toml:"max_size"     xml:"max_size"     yaml:"max_size"`
+	ExtractPath string        `json:"extract_path" toml:"extract_path" xml:"extract_path" yaml:"extract_path"`
+	Atomic      bool          `json:"atomic"       toml:"atomic"       xml:"atomic"       yaml:"atomic"`
+	Report      bool          `json:"report"       toml:"report"       xml:"report"       yaml:"report"`
+	Passwords   StringSlice   `json:"passwords"    toml:"passwords"    xml:"password"     yaml:"passwords"`
+	passwords   *passwordList
 }
 
 // checkQueueChanges checks each item for state changes from the app queues.
@@ -53,32 +76,38 @@ func (u *Unpackerr) checkQueueChanges(now time.Time) {
 		case !u.haveQitem(name, data.App):
 			// This fires when an items becomes missing (imported/deleted) from the application queue.
 			switch elapsed := now.Sub(data.Updated); {
-			case data.Status == WAITING:
-				// A waiting item just fell out of the queue. We never extracted it. Remove it and move on.
+			case data.Status == WAITING, data.Status == EXTRACTSKIPPED:
+				// A waiting or skipped item just fell out of the queue. We never extracted it. Remove it and move on.
+				u.finishTrace(data, now)
+				u.saveHistory(name, data, now)
 				delete(u.Map, name)
-				u.Printf("[%v] Imported: %v (not extracted, removing from history)", data.App, name)
+				u.itemLog(name, data).Printf("[%v] Imported: %v (not extracted, removing from history)", data.App, name)
 			case data.Status > IMPORTED:
-				u.Debugf("Already imported? %s", name)
+				u.itemLog(name, data).in(logPoller).Debugf("Already imported? %s", name)
 			case data.Status == IMPORTED:
-				u.Debugf("%v: Awaiting Delete Delay (%v remains): %v",
+				u.itemLog(name, data).in(logPoller).Debugf("%v: Awaiting Delete Delay (%v remains): %v",
 					data.App, data.DeleteDelay-elapsed.Round(time.Second), name)
 			default:
 				u.updateQueueStatus(&newStatus{Name: name, Status: IMPORTED, Resp: data.Resp}, now, true)
-				u.Printf("[%v] Imported: %v (delete in %v)", data.App, name, data.DeleteDelay)
+				u.itemLog(name, data).Printf("[%v] Imported: %v (delete in %v)", data.App, name, data.DeleteDelay)
 			}
 		case data.Status == IMPORTED:
 			// The item fell out of the app queue and came back. Reset it.
-			u.Printf("%s: Extraction Not Imported: %s - De-queued and returned.", data.App, name)
+			u.itemLog(name, data).Printf("%s: Extraction Not Imported: %s - De-queued and returned.", data.App, name)
+			u.statusMetrics(name, data, IMPORTED, EXTRACTED, now)
 			data.Status = EXTRACTED
+		case data.Status == EXTRACTSKIPPED:
+			// Skipped items stay skipped until they leave the app queue.
 		case data.Status > IMPORTED:
 			// The item fell out of the app queue and came back. Reset it.
-			u.Printf("%s: Extraction Restarting: %s - Deleted Item De-queued and returned.", data.App, name)
+			u.itemLog(name, data).Printf("%s: Extraction Restarting: %s - Deleted Item De-queued and returned.", data.App, name)
+			u.statusMetrics(name, data, data.Status, WAITING, now)
 			data.Status = WAITING
 			data.Updated = now
 		}
 
-		u.Printf("[%s] Status: %s (%v, elapsed: %v) %s", data.App, name, data.Status.Desc(),
-			now.Sub(data.Updated).Round(time.Second), data.XProg)
+		u.itemLog(name, data).elapsed(now.Sub(data.Updated)).Printf("[%s] Status: %s (%v, elapsed: %v) %s",
+			data.App, name, data.Status.Desc(), now.Sub(data.Updated).Round(time.Second), data.XProg)
 	}
 }
 
@@ -96,17 +125,19 @@ func (u *Unpackerr) extractCompletedDownloads(now time.Time) {
 // This is called by extractCompletedDownloads() via the main routine in start.go.
 func (u *Unpackerr) extractCompletedDownload(name string, now time.Time, item *Extract) {
 	if d := u.StartDelay.Duration - now.Sub(item.Updated); d > time.Second { // wiggle room.
-		u.Printf("[%s] Waiting for Start Delay: %v (%v remains)", item.App, name, d.Round(time.Second))
+		u.itemLog(name, item).Printf("[%s] Waiting for Start Delay: %v (%v remains)", item.App, name, d.Round(time.Second))
 		return
 	}
 
 	files := xtractr.FindCompressedFiles(xtractr.Filter{Path: item.Path})
 	if len(files) == 0 {
 		if _, err := os.Stat(item.Path); err != nil {
-			u.Printf("[%s] Completed item still waiting: %s, no extractable files found at: %s (stat err: %v)",
+			u.itemLog(name, item).Printf(
+				"[%s] Completed item still waiting: %s, no extractable files found at: %s (stat err: %v)",
 				item.App, name, item.Path, err)
 		} else {
-			u.Printf("[%s] Completed item still waiting: %s, no extractable files found at: %s (%s Activity Queue status: %v)",
+			u.itemLog(name, item).Printf(
+				"[%s] Completed item still waiting: %s, no extractable files found at: %s (%s Activity Queue status: %v)",
 				item.App, name, item.Path, item.App, item.IDs["reason"])
 		}
 
@@ -115,44 +146,93 @@ func (u *Unpackerr) extractCompletedDownload(name string, now time.Time, item *E
 
 	if item.Syncthing {
 		if tmpFile := u.hasSyncThingFile(item.Path); tmpFile != "" {
-			u.Printf("[%s] Completed item still syncing: %s, found Syncthing .tmp file: %s", item.App, name, tmpFile)
+			u.itemLog(name, item).Printf("[%s] Completed item still syncing: %s, found Syncthing .tmp file: %s",
+				item.App, name, tmpFile)
 			return
 		}
 	}
 
+	// Check the size of the archives that will be extracted, not every archive in the path.
+	filter := extractFilter(item)
+	if item.Skipped = checkSize(xtractr.FindCompressedFiles(filter), item.MinSize, item.MaxSize); item.Skipped != "" {
+		u.itemLog(name, item).Printf("[%s] Extraction Skipped: %s, %s", item.App, name, item.Skipped)
+		u.updateQueueStatus(&newStatus{Name: name, Status: EXTRACTSKIPPED}, now, true)
+
+		return
+	}
+
 	// This updates the item in the map.
 	item.Status = QUEUED
-	item.Updated = now
-	// This queues the extraction. Which may start right away.
-	archiveTypes := []string{".rar", ".r00", ".zip", ".7z", ".7z.001", ".gz", ".tgz", ".tar", ".tar.gz", ".bz2", ".tbz2"}
-	if item.SplitFlac {
-		archiveTypes = append(archiveTypes, ".cue")
+
+	if !item.gated {
+		item.HookOutput = "" // From a previous attempt.
+
+		if u.preExtractGates(name, item) {
+			return // The gates call back into the main go routine when they finish.
+		}
 	}
 
-	queueSize, _ := u.Extract(&xtractr.Xtract{
-		Password:  u.getPasswordFromPath(item.Path),
-		Passwords: u.Passwords,
-		Name:      name,
-		Filter: xtractr.Filter{
-			Path:          item.Path,
-			ExcludeSuffix: xtractr.AllExcept(archiveTypes...),
-		},
+	item.gated = false
+	u.statusMetrics(name, item, WAITING, QUEUED, now)
+	item.trace.queued(item.Updated, now)
+	item.Updated = now
+	// This queues the extraction. Which may start right away.
+	item.probe = u.passwordCandidates(item.Path, item.passwords, string(item.App))
+	xFile := &xtractr.Xtract{
+		Passwords:  item.probe.Passwords(),
+		Name:       name,
+		Filter:     filter,
 		TempFolder: false,
 		DeleteOrig: false,
 		CBChannel:  u.updates,
 		Progress:   u.progressUpdateCallback(item),
-	})
+	}
+
+	if item.Atomic {
+		// Extract into a staging folder, and move the files into the download path when finished.
+		xFile.TempFolder = true
+		xFile.ExtractTo = item.ExtractPath
+	}
+
+	xFile.CBFunction = u.extractCallbacks(item)
+	queueSize, _ := u.Extract(xFile)
+
+	u.logQueuedDownload(name, queueSize, item, files)
+}
+
+// extractCallbacks returns the xtractr callbacks for a starr item, in order. The post_extract gates run
+// first, so a failed gate fails the item before it's extracted: an atomic move then deletes the staging
+// folder, and nothing is moved into the download path. Without atomic, the files are already in the
+// download path, so they are deleted. The password probe runs last; it only logs.
+func (u *Unpackerr) extractCallbacks(item *Extract) func(*xtractr.Response) {
+	callback := u.postExtractGates(item)
+
+	if item.Atomic {
+		callback = chainCallbacks(callback, u.atomicMoveCallback(item.Path))
+	} else {
+		callback = chainCallbacks(callback, u.gateFailedCallback(item))
+	}
+
+	return chainCallbacks(callback, passwordCallback(item.probe))
+}
+
+// extractFilter returns the filter used to find the archives in a starr download.
+func extractFilter(item *Extract) xtractr.Filter {
+	archiveTypes := []string{".rar", ".r00", ".zip", ".7z", ".7z.001", ".gz", ".tgz", ".tar", ".tar.gz", ".bz2", ".tbz2"}
+	if item.SplitFlac {
+		archiveTypes = append(archiveTypes, ".cue")
+	}
 
-	u.logQueuedDownload(queueSize, item, files)
+	return xtractr.Filter{Path: item.Path, ExcludeSuffix: xtractr.AllExcept(archiveTypes...)}
 }
 
-func (u *Unpackerr) logQueuedDownload(queueSize int, item *Extract, files xtractr.ArchiveList) {
+func (u *Unpackerr) logQueuedDownload(name string, queueSize int, item *Extract, files xtractr.ArchiveList) {
 	count := fmt.Sprint("1 archive: ", files.Random()[0])
 	if fileCount := files.Count(); fileCount > 1 {
 		count = fmt.Sprintf("%v archives in %d folders", fileCount, len(files))
 	}
 
-	u.Printf("[%s] Extraction Queued: %s, retries: %d, %s, delete orig: %v, queue size: %d",
+	u.itemLog(name, item).Printf("[%s] Extraction Queued: %s, retries: %d, %s, delete orig: %v, queue size: %d",
 		item.App, item.Path, item.Retries, count, item.DeleteOrig, queueSize)
 	u.updateHistory(string(item.App) + ": " + item.Path)
 }
@@ -164,7 +244,7 @@ func (u *Unpackerr) getPasswordFromPath(path string) string {
 		return ""
 	}
 
-	u.Debugf("Found password in Path: %s", path[start+2:end])
+	u.debugf(logXtractr, "Found password in Path: %s", path[start+2:end])
 
 	return path[start+2 : end]
 }
@@ -180,44 +260,54 @@ func (u *Unpackerr) checkExtractDone(now time.Time) {
 		case item.Status == DELETED && elapsed >= item.DeleteDelay:
 			// Remove the item from history some time after it's deleted.
 			u.Finished++
+			u.finishTrace(item, now)
+			u.saveHistory(name, item, now)
 			delete(u.Map, name)
-			u.Printf("[%s] Finished, Removed History: %v", item.App, name)
+			u.itemLog(name, item).Printf("[%s] Finished, Removed History: %v", item.App, name)
 		case item.App == FolderString:
 			continue // folders are handled in folder.go.
 		case item.Status == EXTRACTFAILED && elapsed >= u.RetryDelay.Duration &&
 			(u.MaxRetries == 0 || item.Retries < u.MaxRetries):
 			u.Retries++
+			u.retryMetrics(item.App, item.URL)
+			u.statusMetrics(name, item, EXTRACTFAILED, WAITING, now)
 			item.Retries++
 			item.Status = WAITING
 			item.Updated = now
-			u.Printf("[%s] Extract failed %v ago, triggering restart (%d/%d): %v",
+			u.itemLog(name, item).elapsed(elapsed).Printf("[%s] Extract failed %v ago, triggering restart (%d/%d): %v",
 				item.App, elapsed.Round(time.Second), item.Retries, u.MaxRetries, name)
 		case item.Status == EXTRACTFAILED && u.MaxRetries > 0 && item.Retries >= u.MaxRetries:
 			// Retries exhausted — clean up to prevent the item from staying in the map forever.
 			u.updateQueueStatus(&newStatus{Name: name, Status: DELETED, Resp: item.Resp}, now, true)
-			u.Printf("[%s] Retries exhausted (%d/%d), giving up: %v",
+			u.itemLog(name, item).Printf("[%s] Retries exhausted (%d/%d), giving up: %v",
 				item.App, item.Retries, u.MaxRetries, name)
 		case (item.Status == EXTRACTED || item.Status == EXTRACTING || item.Status == QUEUED) &&
 			elapsed >= staleItemTimeout:
 			// Safety net: items stuck at intermediate states for too long are cleaned up
 			// to prevent unbounded map growth (e.g. Starr app never imports the item).
 			u.updateQueueStatus(&newStatus{Name: name, Status: DELETED, Resp: item.Resp}, now, true)
-			u.Printf("[%s] Stale item removed after %v at status %s: %v",
+			u.itemLog(name, item).elapsed(elapsed).Printf("[%s] Stale item removed after %v at status %s: %v",
 				item.App, elapsed.Round(time.Second), item.Status.Desc(), name)
 		case item.Status == IMPORTED && elapsed >= item.DeleteDelay:
 			var webhook bool
 
 			if item.DeleteOrig {
-				u.delChan <- &fileDeleteReq{Paths: []string{item.Path}}
+				u.delChan <- &fileDeleteReq{Paths: []string{item.Path}, App: item.App, URL: item.URL, span: item.span()}
 				webhook = true //nolint:wsl_v5
+				u.itemLog(name, item).status(DELETED).Debugf("[%s] Deleting original download: %s", item.App, item.Path)
 			} else if item.Resp != nil && len(item.Resp.NewFiles) > 0 && item.DeleteDelay >= 0 {
 				// Delete extracted files and purge empty parents up to and including the download path.
 				u.delChan <- &fileDeleteReq{
 					Paths:            item.Resp.NewFiles,
 					PurgeEmptyParent: true,
 					PurgeEmptyRoot:   item.Path,
+					App:              item.App,
+					URL:              item.URL,
+					span:             item.span(),
 				}
 				webhook = true //nolint:wsl_v5
+				u.itemLog(name, item).status(DELETED).Debugf("[%s] Deleting %d extracted files: %s",
+					item.App, len(item.Resp.NewFiles), item.Path)
 			}
 
 			u.updateQueueStatus(&newStatus{Name: name, Status: DELETED, Resp: item.Resp}, now, webhook)
@@ -230,25 +320,34 @@ func (u *Unpackerr) checkExtractDone(now time.Time) {
 func (u *Unpackerr) handleXtractrCallback(resp *xtractr.Response) {
 	item := u.Map[resp.X.Name]
 	if resp.Done && item != nil {
+		item.gateFinished()
+		item.trace.extracted(resp, resp.Started.Add(resp.Elapsed))
 		u.updateMetrics(resp, item.App, item.URL)
 	} else if item != nil {
 		item.XProg.Archives = resp.Archives.Count() + resp.Extras.Count()
+		item.trace.extracting(resp)
 	}
 
 	switch now := resp.Started.Add(resp.Elapsed); {
 	case !resp.Done:
-		u.Printf("Extraction Started: %s, items in queue: %d", resp.X.Name, resp.Queued)
+		u.itemLog(resp.X.Name, item).status(EXTRACTING).Printf("Extraction Started: %s, items in queue: %d",
+			resp.X.Name, resp.Queued)
 		u.updateQueueStatus(&newStatus{Name: resp.X.Name, Status: EXTRACTING, Resp: resp}, now, true)
 	case resp.Error != nil:
-		u.Errorf("Extraction Failed: %s: %v", resp.X.Name, resp.Error)
+		u.itemLog(resp.X.Name, item).status(EXTRACTFAILED).elapsed(resp.Elapsed).Errorf("Extraction Failed: %s: %v",
+			resp.X.Name, resp.Error)
 		u.updateQueueStatus(&newStatus{Name: resp.X.Name, Status: EXTRACTFAILED, Resp: resp}, now, true)
+		u.writeReport(resp.X.Name, item, resp)
 	default:
 		files := fileList(resp.X.Path)
-		u.Printf("Extraction Finished: %s => elapsed: %v, archives: %d, extra archives: %d, "+
-			"files extracted: %d, wrote: %sB", resp.X.Name, resp.Elapsed.Round(time.Second),
+		u.itemLog(resp.X.Name, item).status(EXTRACTED).elapsed(resp.Elapsed).Printf(
+			"Extraction Finished: %s => elapsed: %v, archives: %d, extra archives: %d, "+
+				"files extracted: %d, wrote: %sB", resp.X.Name, resp.Elapsed.Round(time.Second),
 			resp.Archives.Count(), resp.Extras.Count(), len(resp.NewFiles), bytefmt.ByteSize(resp.Size))
-		u.Debugf("Extraction Finished: %d files in path: %s", len(files), files)
+		u.itemLog(resp.X.Name, item).in(logXtractr).Debugf("Extraction Finished: %d files in path: %s", len(files), files)
+		u.logPassword(resp.X.Name, item)
 		u.updateQueueStatus(&newStatus{Name: resp.X.Name, Status: EXTRACTED, Resp: resp}, now, true)
+		u.writeReport(resp.X.Name, item, resp)
 
 		if item != nil && item.App == starr.Lidarr && item.SplitFlac && resp.Size > 0 {
 			go u.importSplitFlacTracks(item, u.lidarrServerByURL(item.URL))
@@ -274,7 +373,7 @@ func (u *Unpackerr) getDownloadPath(outputPath string, app starr.App, title stri
 	}
 
 	// Print the errors for each user-provided path.
-	u.Debugf("%s: Errors encountered looking for %s path: %q", app, title, errs)
+	u.debugf(logPoller, "%s: Errors encountered looking for %s path: %q", app, title, errs)
 
 	// The title often differs from the actual folder name (e.g. torrent names include genre tags).
 	// Try the folder name from outputPath against configured paths — the folder name is the real
@@ -287,18 +386,18 @@ func (u *Unpackerr) getDownloadPath(outputPath string, app starr.App, title stri
 				candidate := filepath.Join(path, outputFolder)
 
 				if _, err := os.Stat(candidate); err == nil {
-					u.Debugf("%s: Resolved via outputPath folder name: %s -> %s", app, outputPath, candidate)
+					u.debugf(logPoller, "%s: Resolved via outputPath folder name: %s -> %s", app, outputPath, candidate)
 					return candidate
 				}
 			}
 		}
 
-		u.Debugf("%s: Configured paths do not exist; trying 'outputPath': %s", app, outputPath)
+		u.debugf(logPoller, "%s: Configured paths do not exist; trying 'outputPath': %s", app, outputPath)
 
 		return outputPath
 	}
 
-	u.Debugf("%s: Configured paths do not exist and 'outputPath' is empty for: %s", app, title)
+	u.debugf(logPoller, "%s: Configured paths do not exist and 'outputPath' is empty for: %s", app, title)
 
 	return filepath.Join(paths[0], title) // useless, but return something. :(
 }
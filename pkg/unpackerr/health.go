@@ -0,0 +1,379 @@
+package unpackerr
+
+/* The health endpoint reports Starr connectivity, the folder watcher, disk space and hook failures.
+   The overall status sets the response code, so container health checks can use it directly. */
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"golift.io/starr"
+	"golift.io/version"
+)
+
+// Health statuses, from best to worst. Pending instances have not been polled yet.
+const (
+	HealthOK        = "ok"
+	HealthPending   = "pending"
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
+const (
+	healthTimeout    = 5 * time.Second // How long to wait for the main go routine.
+	healthMinFree    = 1 << 30         // Disks with less free space than this are degraded.
+	healthBufferWarn = 0.5             // Folder event buffer fill ratio that is degraded.
+	healthBufferFull = 0.9             // Folder event buffer fill ratio that is unhealthy.
+	healthHookRate   = 0.5             // Hook failure rate that is degraded.
+	healthHookMin    = 4               // Hooks must run this many times before the failure rate counts.
+)
+
+// ErrUnhealthy is returned by the health check flag when Unpackerr is not healthy.
+var ErrUnhealthy = errors.New("health check failed")
+
+// Health is the response from the health endpoint.
+type Health struct {
+	Status  string         `json:"status"`
+	Version string         `json:"version"`
+	Uptime  string         `json:"uptime"`
+	Main    string         `json:"main"` // The main go routine answered.
+	Starr   []*StarrHealth `json:"starr"`
+	Folders *FoldersHealth `json:"folders,omitempty"`
+	Disks   []*DiskHealth  `json:"disks"`
+	Hooks   *HooksHealth   `json:"hooks"`
+	Checked time.Time      `json:"checked"`
+	reply   chan *FoldersHealth
+}
+
+// StarrHealth is the queue fetch state for one Starr instance.
+type StarrHealth struct {
+	Status      string    `json:"status"`
+	App         starr.App `json:"app"`
+	URL         string    `json:"url"`
+	LastSuccess time.Time `json:"lastSuccess"`
+	LastError   string    `json:"lastError,omitempty"`
+	ErrorTime   time.Time `json:"lastErrorTime"`
+	Queued      int       `json:"queued"`
+}
+
+// FoldersHealth is the state of the folder watcher.
+type FoldersHealth struct {
+	Status     string  `json:"status"`
+	Watching   bool    `json:"watching"`
+	Folders    int     `json:"folders"`
+	Buffer     int     `json:"buffer"`
+	BufferUsed int     `json:"bufferUsed"`
+	BufferFill float64 `json:"bufferFill"`
+}
+
+// DiskHealth is the free space on a download or watch folder path.
+type DiskHealth struct {
+	Status string `json:"status"`
+	Path   string `json:"path"`
+	Free   uint64 `json:"free"`
+	Total  uint64 `json:"total"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HooksHealth is the failure rate for webhooks and command hooks since startup.
+type HooksHealth struct {
+	Status   string  `json:"status"`
+	Runs     uint    `json:"runs"`
+	Fails    uint    `json:"fails"`
+	FailRate float64 `json:"failRate"`
+}
+
+// starrHealth keeps the queue fetch state for each Starr instance.
+// The app polling go routines write it, and the web server reads it.
+type starrHealth struct {
+	instances map[string]*StarrHealth
+	sync.Mutex
+}
+
+// worse returns the worse of two statuses.
+func worse(status, other string) string {
+	order := []string{HealthOK, HealthPending, HealthDegraded, HealthUnhealthy}
+	if slices.Index(order, other) > slices.Index(order, status) {
+		return other
+	}
+
+	return status
+}
+
+// saveFetch records the result of a queue fetch for a Starr instance.
+func (s *starrHealth) saveFetch(app starr.App, url string, size int, err error) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.instances == nil {
+		s.instances = make(map[string]*StarrHealth)
+	}
+
+	instance := s.instances[string(app)+url]
+	if instance == nil {
+		instance = &StarrHealth{App: app, URL: url}
+		s.instances[string(app)+url] = instance
+	}
+
+	if err != nil {
+		instance.Status = HealthDegraded
+		instance.LastError = err.Error()
+		instance.ErrorTime = time.Now()
+
+		return
+	}
+
+	instance.Status = HealthOK
+	instance.LastSuccess = time.Now()
+	instance.Queued = size
+}
+
+// get returns a copy of the state for a Starr instance.
+func (s *starrHealth) get(app starr.App, url string) *StarrHealth {
+	s.Lock()
+	defer s.Unlock()
+
+	if instance := s.instances[string(app)+url]; instance != nil {
+		health := *instance
+		return &health
+	}
+
+	return &StarrHealth{Status: HealthPending, App: app, URL: url}
+}
+
+// starrHealth returns the state for every configured Starr instance. Failing instances are only
+// degraded, even all of them: restarting Unpackerr does not fix a Starr app, and loses its queue state.
+func (u *Unpackerr) starrHealth(health *Health) {
+	instances := map[starr.App][]*StarrConfig{}
+	for _, app := range u.Lidarr {
+		instances[starr.Lidarr] = append(instances[starr.Lidarr], &app.StarrConfig)
+	}
+
+	for _, app := range u.Radarr {
+		instances[starr.Radarr] = append(instances[starr.Radarr], &app.StarrConfig)
+	}
+
+	for _, app := range u.Readarr {
+		instances[starr.Readarr] = append(instances[starr.Readarr], &app.StarrConfig)
+	}
+
+	for _, app := range u.Sonarr {
+		instances[starr.Sonarr] = append(instances[starr.Sonarr], &app.StarrConfig)
+	}
+
+	for _, app := range u.Whisparr {
+		instances[starr.Whisparr] = append(instances[starr.Whisparr], &app.StarrConfig)
+	}
+
+	health.Starr = []*StarrHealth{}
+
+	for _, app := range []starr.App{starr.Lidarr, starr.Radarr, starr.Readarr, starr.Sonarr, starr.Whisparr} {
+		for _, config := range instances[app] {
+			instance := u.starrs.get(app, config.URL)
+			health.Starr = append(health.Starr, instance)
+			health.Status = worse(health.Status, instance.Status)
+		}
+	}
+}
+
+// foldersHealth returns the state of the folder watcher. This runs in the main go routine.
+func (u *Unpackerr) foldersHealth() *FoldersHealth {
+	if u.folders == nil || len(u.Folders) == 0 {
+		return nil
+	}
+
+	health := &FoldersHealth{
+		Status:     HealthOK,
+		Watching:   u.folders.watching.Load(),
+		Folders:    len(u.folders.Folders),
+		Buffer:     cap(u.folders.Events),
+		BufferUsed: len(u.folders.Events),
+	}
+
+	if health.Buffer > 0 {
+		health.BufferFill = float64(health.BufferUsed) / float64(health.Buffer)
+	}
+
+	switch {
+	case !health.Watching, health.BufferFill >= healthBufferFull:
+		health.Status = HealthUnhealthy
+	case health.BufferFill >= healthBufferWarn:
+		health.Status = HealthDegraded
+	}
+
+	return health
+}
+
+// diskHealth returns the free space on every download and watch folder path.
+// Paths that do not exist are reported, but do not change the status.
+func (u *Unpackerr) diskHealth(health *Health) {
+	paths := []string{}
+	for _, config := range u.starrConfigs() {
+		paths = append(paths, config.Paths...)
+	}
+
+	for _, folder := range u.Folders {
+		paths = append(paths, folder.Path)
+	}
+
+	slices.Sort(paths)
+	health.Disks = []*DiskHealth{}
+
+	for _, diskPath := range slices.Compact(paths) {
+		disk := &DiskHealth{Status: HealthOK, Path: diskPath}
+		health.Disks = append(health.Disks, disk)
+
+		free, total, err := diskSpace(diskPath)
+		switch disk.Free, disk.Total = free, total; {
+		case errors.Is(err, os.ErrNotExist):
+			disk.Status, disk.Error = HealthPending, err.Error()
+		case err != nil:
+			disk.Status, disk.Error = HealthDegraded, err.Error()
+		case free == 0:
+			disk.Status = HealthUnhealthy
+		case free < healthMinFree:
+			disk.Status = HealthDegraded
+		}
+
+		if disk.Status != HealthPending {
+			health.Status = worse(health.Status, disk.Status)
+		}
+	}
+}
+
+// hooksHealth returns the failure rate for webhooks and command hooks.
+func (u *Unpackerr) hooksHealth(health *Health) {
+	hookRuns, hookFails := u.WebhookCounts()
+	cmdRuns, cmdFails := u.CmdhookCounts()
+	health.Hooks = &HooksHealth{Status: HealthOK, Runs: hookRuns + cmdRuns, Fails: hookFails + cmdFails}
+
+	if health.Hooks.Runs > 0 {
+		health.Hooks.FailRate = float64(health.Hooks.Fails) / float64(health.Hooks.Runs)
+	}
+
+	if health.Hooks.Runs >= healthHookMin && health.Hooks.FailRate > healthHookRate {
+		health.Hooks.Status = HealthDegraded
+		health.Status = worse(health.Status, HealthDegraded)
+	}
+}
+
+// health compiles the health report. The folder watcher state comes from the main go routine.
+// If the main go routine does not answer, Unpackerr is unhealthy.
+func (u *Unpackerr) health() *Health {
+	health := &Health{
+		Status:  HealthOK,
+		Version: version.Version,
+		Uptime:  time.Since(version.Started).Round(time.Second).String(),
+		Main:    HealthOK,
+		Checked: time.Now(),
+		reply:   make(chan *FoldersHealth, 1),
+	}
+
+	u.starrHealth(health)
+	u.diskHealth(health)
+	u.hooksHealth(health)
+
+	timer := time.NewTimer(healthTimeout)
+	defer timer.Stop()
+
+	select {
+	case u.healthCh <- health.reply:
+		health.Folders = <-health.reply
+	case <-timer.C:
+		health.Main = HealthUnhealthy
+	}
+
+	if health.Main != HealthOK {
+		health.Status = HealthUnhealthy
+	} else if health.Folders != nil {
+		health.Status = worse(health.Status, health.Folders.Status)
+	}
+
+	if health.Status == HealthPending {
+		health.Status = HealthOK // Starting up is healthy.
+	}
+
+	return health
+}
+
+// handleHealth returns the health report. The response code is 503 when Unpackerr is unhealthy.
+func (u *Unpackerr) handleHealth(resp http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	health := u.health()
+
+	resp.Header().Set("Content-Type", "application/json")
+
+	if health.Status == HealthUnhealthy {
+		resp.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	encoder := json.NewEncoder(resp)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(health)
+}
+
+// healthURL returns the local url for the health endpoint.
+func (w *WebServer) healthURL() string {
+	host, port, err := net.SplitHostPort(w.ListenAddr)
+	if err != nil {
+		host, port = "", w.ListenAddr
+	}
+
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+
+	scheme := "http"
+	if w.SSLCrtFile != "" && w.SSLKeyFile != "" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, port) + path.Join("/", w.URLBase, "/api/v1/health")
+}
+
+// healthCheck queries the health endpoint of a running Unpackerr, for the --healthcheck flag.
+// Returns an error when Unpackerr is unhealthy or does not answer.
+func (u *Unpackerr) healthCheck() error {
+	if !u.Webserver.Enabled() {
+		return fmt.Errorf("%w: the webserver is disabled; set a listen_addr to use health checks", ErrUnhealthy)
+	}
+
+	client := &http.Client{
+		Timeout: healthTimeout + u.Timeout.Duration,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true, //nolint:gosec // This checks ourselves.
+		}},
+	}
+
+	resp, err := client.Get(u.Webserver.healthURL()) //nolint:noctx
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnhealthy, err)
+	}
+	defer resp.Body.Close()
+
+	var health Health
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return fmt.Errorf("%w: %s: decoding response: %w", ErrUnhealthy, resp.Status, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s: %s", ErrUnhealthy, resp.Status, health.Status)
+	}
+
+	fmt.Println("Unpackerr is", health.Status) //nolint:forbidigo
+
+	return nil
+}
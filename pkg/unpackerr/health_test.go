@@ -0,0 +1,168 @@
+package unpackerr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"golift.io/starr"
+)
+
+func TestWorse(t *testing.T) {
+	t.Parallel()
+
+	if status := worse(HealthOK, HealthPending); status != HealthPending {
+		t.Fatalf("expected pending, got: %s", status)
+	}
+
+	if status := worse(HealthUnhealthy, HealthDegraded); status != HealthUnhealthy {
+		t.Fatalf("expected unhealthy, got: %s", status)
+	}
+}
+
+func TestHealthEndpoint(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unpackerr := &Unpackerr{
+		Config: &Config{Radarr: []*RadarrConfig{
+			{StarrConfig: StarrConfig{Config: starr.Config{URL: "http://radarr1"}, Paths: StringSlice{dir}}},
+			{StarrConfig: StarrConfig{Config: starr.Config{URL: "http://radarr2"}, Paths: StringSlice{dir}}},
+		}},
+		starrs:   &starrHealth{},
+		healthCh: make(chan chan *FoldersHealth),
+	}
+
+	go func() {
+		for reply := range unpackerr.healthCh {
+			reply <- unpackerr.foldersHealth()
+		}
+	}()
+	defer close(unpackerr.healthCh)
+
+	get := func() (*Health, int) {
+		resp := httptest.NewRecorder()
+		unpackerr.handleHealth(resp, httptest.NewRequest(http.MethodGet, "/api/v1/health", nil), nil)
+
+		var health Health
+		if err := json.Unmarshal(resp.Body.Bytes(), &health); err != nil {
+			t.Fatalf("decoding health: %v: %s", err, resp.Body.String())
+		}
+
+		return &health, resp.Code
+	}
+
+	if health, code := get(); code != http.StatusOK || len(health.Starr) != 2 ||
+		health.Starr[0].Status != HealthPending || len(health.Disks) != 1 || health.Disks[0].Total == 0 {
+		t.Fatalf("expected a healthy startup: %d: %+v", code, health)
+	}
+
+	unpackerr.starrs.saveFetch(starr.Radarr, "http://radarr1", 3, nil)
+	unpackerr.starrs.saveFetch(starr.Radarr, "http://radarr2", 0, errors.New("connection refused"))
+
+	if health, code := get(); code != http.StatusOK || health.Status != HealthDegraded ||
+		health.Starr[0].Queued != 3 || health.Starr[1].LastError != "connection refused" {
+		t.Fatalf("expected one failing instance to be degraded: %d: %+v", code, health)
+	}
+
+	unpackerr.starrs.saveFetch(starr.Radarr, "http://radarr1", 0, errors.New("timeout"))
+
+	if health, code := get(); code != http.StatusOK || health.Status != HealthDegraded {
+		t.Fatalf("expected every failing instance to be degraded, not unhealthy: %d: %+v", code, health)
+	}
+}
+
+func TestFoldersHealth(t *testing.T) {
+	t.Parallel()
+
+	unpackerr := &Unpackerr{
+		Config:  &Config{Folders: []*FolderConfig{{Path: "/downloads"}}},
+		folders: &Folders{Events: make(chan *eventData, 4)},
+	}
+
+	if health := unpackerr.foldersHealth(); health.Status != HealthUnhealthy {
+		t.Fatalf("expected a stopped watcher to be unhealthy: %+v", health)
+	}
+
+	unpackerr.folders.watching.Store(true)
+	unpackerr.folders.Events <- &eventData{}
+	unpackerr.folders.Events <- &eventData{}
+
+	if health := unpackerr.foldersHealth(); health.Status != HealthDegraded || health.BufferFill != 0.5 {
+		t.Fatalf("expected a half full buffer to be degraded: %+v", health)
+	}
+}
+
+func TestHealthWithoutMetrics(t *testing.T) {
+	t.Parallel()
+
+	unpackerr := &Unpackerr{Config: &Config{Webserver: &WebServer{ListenAddr: "127.0.0.1:5656", URLBase: "/"}}}
+	if !unpackerr.Webserver.Enabled() {
+		t.Fatal("expected the web server to run with a listen address and metrics off")
+	}
+
+	unpackerr.Webserver.router = httprouter.New()
+	unpackerr.webRoutes()
+
+	if handle, _, _ := unpackerr.Webserver.router.Lookup(http.MethodGet, "/api/v1/health"); handle == nil {
+		t.Fatal("expected the health endpoint without metrics")
+	}
+
+	if handle, _, _ := unpackerr.Webserver.router.Lookup(http.MethodGet, "/metrics"); handle != nil {
+		t.Fatal("expected no metrics endpoint with metrics off")
+	}
+}
+
+func TestAPIAuth(t *testing.T) {
+	t.Parallel()
+
+	unpackerr := &Unpackerr{Config: &Config{Webserver: &WebServer{}}}
+	handle := unpackerr.apiAuth(func(resp http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+		resp.WriteHeader(http.StatusNoContent)
+	})
+
+	request := func(remoteAddr, key string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/loglevel?debug=true", nil)
+		req.RemoteAddr = remoteAddr
+
+		if key != "" {
+			req.Header.Set(apiKeyHeader, key)
+		}
+
+		resp := httptest.NewRecorder()
+		handle(resp, req, nil)
+
+		return resp.Code
+	}
+
+	if code := request("10.1.2.3:4567", ""); code != http.StatusForbidden {
+		t.Fatalf("expected remote requests to be refused without an api key, got: %d", code)
+	}
+
+	for _, addr := range []string{"127.0.0.1:4567", "[::1]:4567", "[::ffff:127.0.0.1]:4567"} {
+		if code := request(addr, ""); code != http.StatusNoContent {
+			t.Fatalf("expected local requests from %s to be allowed without an api key, got: %d", addr, code)
+		}
+	}
+
+	// Requests through a proxy on the same host are not local.
+	unpackerr.Webserver.allow = MakeIPs([]string{"127.0.0.1"})
+	if code := request("127.0.0.1:4567", ""); code != http.StatusForbidden {
+		t.Fatalf("expected requests from an upstream to be refused without an api key, got: %d", code)
+	}
+
+	unpackerr.Webserver.APIKey = "secret"
+
+	for addr, key := range map[string]string{"10.1.2.3:4567": "wrong", "127.0.0.1:4567": ""} {
+		if code := request(addr, key); code != http.StatusUnauthorized {
+			t.Fatalf("expected %s with key %q to be unauthorized, got: %d", addr, key, code)
+		}
+	}
+
+	if code := request("10.1.2.3:4567", "secret"); code != http.StatusNoContent {
+		t.Fatalf("expected the api key to be accepted, got: %d", code)
+	}
+}
@@ -1,9 +1,24 @@
 package unpackerr
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Unpackerr/unpackerr/pkg/ui"
+	"github.com/julienschmidt/httprouter"
+	"golift.io/cnfg"
+	"golift.io/starr"
+	"golift.io/xtractr"
 )
 
 // Safety constants.
@@ -12,12 +27,74 @@ const (
 	histNone = "hist_none"
 )
 
+// History defaults and API page sizes.
+const (
+	defaultHistoryMax       = 1000
+	defaultHistoryRetention = 30 * 24 * time.Hour
+	historyPageSize         = 100
+	historyMaxPage          = 1000
+	historyDirMode          = 0o755
+)
+
+// ErrHistoryParam is returned by the history endpoint for bad query parameters.
+var ErrHistoryParam = errors.New("invalid history parameter")
+
 // History holds the history of extracted items.
 type History struct {
 	Items    []string
 	Finished uint
 	Retries  uint
 	Map      map[string]*Extract
+	store    *historyStore
+}
+
+// HistoryRecord is a finished item in the extraction history.
+type HistoryRecord struct {
+	Name     string         `json:"name"`
+	App      starr.App      `json:"app"`
+	URL      string         `json:"url,omitempty"`
+	Path     string         `json:"path"`
+	Title    string         `json:"title,omitempty"`
+	IDs      map[string]any `json:"ids,omitempty"`
+	Status   string         `json:"status"` // The outcome: extracted, extractfailed, extractednothing or extractskipped.
+	Error    string         `json:"error,omitempty"`
+	Skipped  string         `json:"skipped,omitempty"`
+	Retries  uint           `json:"retries"`
+	Archives int            `json:"archives"`
+	Files    int            `json:"files"`
+	Bytes    uint64         `json:"bytes"`
+	Elapsed  cnfg.Duration  `json:"elapsed"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+}
+
+// HistoryPage is the response from the history endpoint.
+type HistoryPage struct {
+	Total   int              `json:"total"`
+	Offset  int              `json:"offset"`
+	Limit   int              `json:"limit"`
+	Records []*HistoryRecord `json:"records"`
+}
+
+// historyFilter selects records from the history.
+type historyFilter struct {
+	app    string
+	status string
+	since  time.Time
+	offset int
+	limit  int
+}
+
+// historyStore keeps finished items, oldest first, and appends each one to the history file.
+// The main go routine writes it, and the web server reads it.
+type historyStore struct {
+	records   []*HistoryRecord
+	path      string
+	mode      os.FileMode
+	max       int
+	retention time.Duration
+	lines     int // Records in the history file. It is rewritten when this reaches twice the max.
+	sync.RWMutex
 }
 
 // This is called every time an item is queued.
@@ -46,3 +123,296 @@ func (u *Unpackerr) updateHistory(item string) {
 		}
 	}
 }
+
+// loadHistory creates the history store and reads the history file. This runs once on startup.
+// A bad history file is logged and replaced; it does not stop the app.
+func (u *Unpackerr) loadHistory() {
+	if u.HistoryMax == 0 {
+		return
+	}
+
+	fileMode, _ := strconv.ParseUint(u.FileMode, bits8, base32)
+	u.History.store = &historyStore{
+		path:      expandHomedir(u.HistoryFile),
+		mode:      os.FileMode(fileMode),
+		max:       int(u.HistoryMax),
+		retention: u.HistoryRetention.Duration,
+	}
+
+	if u.History.store.path == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(u.History.store.path), historyDirMode); err != nil {
+		u.Errorf("Creating History Folder: %v", err)
+	}
+
+	skipped, err := u.History.store.read(time.Now())
+	if err != nil {
+		u.Errorf("Reading History File: %v", err)
+	}
+
+	if skipped > 0 {
+		u.Errorf("Skipped %d unreadable records in history file: %s", skipped, u.History.store.path)
+	}
+
+	u.Printf("=> History: %d records loaded from %s", len(u.History.store.records), u.History.store.path)
+}
+
+// outcome returns the final status of an item, for the history. Waiting items were never extracted.
+func (item *Extract) outcome() ExtractStatus {
+	switch {
+	case item.Status == EXTRACTSKIPPED || item.Skipped != "":
+		return EXTRACTSKIPPED
+	case item.Status == EXTRACTEDNOTHING, item.Resp != nil && errors.Is(item.Resp.Error, xtractr.ErrNoCompressedFiles):
+		return EXTRACTEDNOTHING
+	case item.Resp == nil:
+		return WAITING
+	case item.Resp.Error != nil:
+		return EXTRACTFAILED
+	default:
+		return EXTRACTED
+	}
+}
+
+// newHistoryRecord returns the history record for a finished item.
+func newHistoryRecord(name string, item *Extract, now time.Time) *HistoryRecord {
+	record := &HistoryRecord{
+		Name:     name,
+		App:      item.App,
+		URL:      item.URL,
+		Path:     item.Path,
+		IDs:      item.IDs,
+		Status:   item.outcome().String(),
+		Skipped:  item.Skipped,
+		Retries:  item.Retries,
+		Finished: now,
+	}
+
+	if title, ok := item.IDs["title"].(string); ok {
+		record.Title = title
+	}
+
+	if item.Resp == nil {
+		return record
+	}
+
+	record.Archives = item.Resp.Archives.Count() + item.Resp.Extras.Count()
+	record.Files = len(item.Resp.NewFiles)
+	record.Bytes = item.Resp.Size
+	record.Elapsed = cnfg.Duration{Duration: item.Resp.Elapsed}
+	record.Started = item.Resp.Started
+
+	if item.Resp.Error != nil && record.Status == EXTRACTFAILED.String() {
+		record.Error = item.Resp.Error.Error()
+	}
+
+	return record
+}
+
+// saveHistory adds an item to the history when it leaves the queue. This runs in the main go routine.
+func (u *Unpackerr) saveHistory(name string, item *Extract, now time.Time) {
+	if u.History == nil || u.History.store == nil || item == nil || item.outcome() == WAITING {
+		return
+	}
+
+	if err := u.History.store.add(newHistoryRecord(name, item, now), now); err != nil {
+		u.itemLog(name, item).Errorf("[%s] Saving History: %v", item.App, err)
+	}
+}
+
+// read loads the history file, prunes it, and writes it back out.
+// Returns the number of lines that could not be decoded.
+func (s *historyStore) read(now time.Time) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("opening history file: %w", err)
+	}
+	defer file.Close()
+
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1<<20) //nolint:mnd // 1MB records.
+
+	for scanner.Scan() {
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			skipped++
+			continue
+		}
+
+		s.records = append(s.records, &record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return skipped, fmt.Errorf("reading history file: %w", err)
+	}
+
+	s.prune(now)
+
+	return skipped, s.compact()
+}
+
+// add appends a record to the history and the history file.
+func (s *historyStore) add(record *HistoryRecord, now time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.records = append(s.records, record)
+	s.prune(now)
+
+	if s.path == "" {
+		return nil
+	}
+
+	if s.lines++; s.lines >= s.max*2 {
+		return s.compact()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding history record: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, s.mode)
+	if err != nil {
+		return fmt.Errorf("opening history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err = file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing history file: %w", err)
+	}
+
+	return nil
+}
+
+// prune removes records older than the retention, and the oldest records past the max. Lock first.
+func (s *historyStore) prune(now time.Time) {
+	if s.retention > 0 {
+		s.records = slices.DeleteFunc(s.records, func(record *HistoryRecord) bool {
+			return now.Sub(record.Finished) > s.retention
+		})
+	}
+
+	if len(s.records) > s.max {
+		s.records = slices.Delete(s.records, 0, len(s.records)-s.max)
+	}
+}
+
+// compact rewrites the history file with only the kept records. Lock first.
+func (s *historyStore) compact() error {
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating history file: %w", err)
+	}
+	defer os.Remove(temp.Name()) // Fails after the rename.
+
+	encoder := json.NewEncoder(temp)
+	for _, record := range s.records {
+		if err := encoder.Encode(record); err != nil {
+			temp.Close()
+			return fmt.Errorf("writing history file: %w", err)
+		}
+	}
+
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("writing history file: %w", err)
+	}
+
+	if err := os.Chmod(temp.Name(), s.mode); err != nil {
+		return fmt.Errorf("setting history file mode: %w", err)
+	}
+
+	if err := os.Rename(temp.Name(), s.path); err != nil {
+		return fmt.Errorf("replacing history file: %w", err)
+	}
+
+	s.lines = len(s.records)
+
+	return nil
+}
+
+// query returns a page of records that match a filter, newest first.
+func (s *historyStore) query(filter *historyFilter) *HistoryPage {
+	s.RLock()
+	defer s.RUnlock()
+
+	page := &HistoryPage{Offset: filter.offset, Limit: filter.limit, Records: []*HistoryRecord{}}
+
+	for _, record := range slices.Backward(s.records) {
+		switch {
+		case filter.app != "" && !strings.EqualFold(string(record.App), filter.app),
+			filter.status != "" && !strings.EqualFold(record.Status, filter.status),
+			record.Finished.Before(filter.since):
+			continue
+		}
+
+		if page.Total++; page.Total > filter.offset && len(page.Records) < filter.limit {
+			page.Records = append(page.Records, record)
+		}
+	}
+
+	return page
+}
+
+// parseHistoryFilter reads the history endpoint's query parameters.
+// The since parameter is an RFC3339 time or a duration like 24h.
+func parseHistoryFilter(req *http.Request, now time.Time) (*historyFilter, error) {
+	query := req.URL.Query()
+	filter := &historyFilter{app: query.Get("app"), status: query.Get("status"), limit: historyPageSize}
+
+	if since := query.Get("since"); since != "" {
+		if ago, err := time.ParseDuration(since); err == nil {
+			filter.since = now.Add(-ago)
+		} else if filter.since, err = time.Parse(time.RFC3339, since); err != nil {
+			return nil, fmt.Errorf("%w: since must be RFC3339 or a duration: %w", ErrHistoryParam, err)
+		}
+	}
+
+	for param, value := range map[string]*int{"limit": &filter.limit, "offset": &filter.offset} {
+		if query.Get(param) == "" {
+			continue
+		}
+
+		number, err := strconv.Atoi(query.Get(param))
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("%w: %s: %s", ErrHistoryParam, param, query.Get(param))
+		}
+
+		*value = number
+	}
+
+	filter.limit = min(filter.limit, historyMaxPage)
+
+	return filter, nil
+}
+
+// handleHistory returns a page of the extraction history.
+func (u *Unpackerr) handleHistory(resp http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	resp.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(resp)
+	encoder.SetIndent("", "  ")
+
+	if u.History.store == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		_ = encoder.Encode(map[string]string{"error": "history is disabled"})
+
+		return
+	}
+
+	filter, err := parseHistoryFilter(req, time.Now())
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		_ = encoder.Encode(map[string]string{"error": err.Error()})
+
+		return
+	}
+
+	_ = encoder.Encode(u.History.store.query(filter))
+}
@@ -0,0 +1,115 @@
+package unpackerr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golift.io/starr"
+	"golift.io/xtractr"
+)
+
+func TestHistoryOutcome(t *testing.T) {
+	t.Parallel()
+
+	for status, item := range map[ExtractStatus]*Extract{
+		WAITING:          {Status: WAITING},
+		EXTRACTED:        {Status: DELETED, Resp: &xtractr.Response{}},
+		EXTRACTFAILED:    {Status: DELETED, Resp: &xtractr.Response{Error: errors.New("wrong password")}},
+		EXTRACTEDNOTHING: {Status: DELETED, Resp: &xtractr.Response{Error: xtractr.ErrNoCompressedFiles}},
+		EXTRACTSKIPPED:   {Status: EXTRACTSKIPPED, Skipped: "too big"},
+	} {
+		if got := item.outcome(); got != status {
+			t.Fatalf("expected %s, got: %s", status, got)
+		}
+	}
+}
+
+func TestHistoryStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := &historyStore{path: path, mode: 0o600, max: 3, retention: time.Hour}
+	now := time.Now()
+
+	// The first record expires, and the max pushes out the second.
+	for idx, app := range []starr.App{starr.Radarr, starr.Sonarr, starr.Radarr, FolderString, starr.Lidarr} {
+		finished := now.Add(time.Duration(idx) * time.Minute)
+		if idx == 0 {
+			finished = now.Add(-2 * time.Hour)
+		}
+
+		record := &HistoryRecord{Name: string(app), App: app, Status: EXTRACTED.String(), Finished: finished}
+		if err := store.add(record, now); err != nil {
+			t.Fatalf("adding history: %v", err)
+		}
+	}
+
+	if len(store.records) != 3 || store.records[0].App != starr.Radarr {
+		t.Fatalf("expected 3 records starting with radarr, got: %d", len(store.records))
+	}
+
+	// Records survive a restart, and a bad line is skipped.
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	_, _ = file.WriteString("not json\n")
+	file.Close()
+
+	restarted := &historyStore{path: path, mode: 0o600, max: 3, retention: time.Hour}
+	if skipped, err := restarted.read(now); err != nil || skipped != 1 || len(restarted.records) != 3 {
+		t.Fatalf("expected 3 records and 1 skipped line, got: %d, %d, %v", len(restarted.records), skipped, err)
+	}
+
+	if page := restarted.query(&historyFilter{app: "radarr", limit: 10}); page.Total != 1 {
+		t.Fatalf("expected one radarr record, got: %d", page.Total)
+	}
+
+	page := restarted.query(&historyFilter{limit: 1, offset: 1, since: now})
+	if page.Total != 3 || len(page.Records) != 1 || page.Records[0].App != FolderString {
+		t.Fatalf("expected the second newest record on page 2, got: %+v", page)
+	}
+}
+
+func TestHistoryAPI(t *testing.T) {
+	t.Parallel()
+
+	unpackerr := &Unpackerr{History: &History{store: &historyStore{max: 10}}}
+	now := time.Now()
+	failed := &Extract{App: starr.Sonarr, Status: DELETED, IDs: map[string]any{"title": "Show"},
+		Resp: &xtractr.Response{Error: errors.New("crc error"), Size: 10}}
+
+	unpackerr.saveHistory("Show", failed, now.Add(-time.Hour))
+	unpackerr.saveHistory("Movie", &Extract{App: starr.Radarr, Status: DELETED, Resp: &xtractr.Response{}}, now)
+	unpackerr.saveHistory("Waiting", &Extract{App: starr.Radarr, Status: WAITING}, now)
+
+	get := func(query string) (*HistoryPage, int) {
+		resp := httptest.NewRecorder()
+		unpackerr.handleHistory(resp, httptest.NewRequest(http.MethodGet, "/api/v1/history"+query, nil), nil)
+
+		var page HistoryPage
+		_ = json.Unmarshal(resp.Body.Bytes(), &page)
+
+		return &page, resp.Code
+	}
+
+	if page, code := get(""); code != http.StatusOK || page.Total != 2 || page.Records[0].Name != "Movie" {
+		t.Fatalf("expected 2 records, newest first: %d: %+v", code, page)
+	}
+
+	page, _ := get("?status=extractfailed&app=Sonarr")
+	if page.Total != 1 || page.Records[0].Title != "Show" || page.Records[0].Error != "crc error" {
+		t.Fatalf("expected the failed record: %+v", page)
+	}
+
+	if page, _ := get("?since=30m"); page.Total != 1 {
+		t.Fatalf("expected 1 record in the last 30 minutes: %+v", page)
+	}
+
+	if _, code := get("?limit=-1"); code != http.StatusBadRequest {
+		t.Fatalf("expected a bad limit to fail, got: %d", code)
+	}
+}
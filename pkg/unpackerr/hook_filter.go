@@ -0,0 +1,140 @@
+package unpackerr
+
+/* Optional per-hook filters on the app instance, path, error and size of a payload. */
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidFilter is returned when a hook has a path filter that is not a valid glob.
+var ErrInvalidFilter = errors.New("invalid hook path filter")
+
+// validateFilters makes sure every path filter is a valid glob pattern.
+func (w *WebhookConfig) validateFilters() error {
+	for _, pattern := range append(append(StringSlice{}, w.FilterPaths...), w.ExcludePaths...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: %s %s: '%s': %w", ErrInvalidFilter, strings.ToLower(w.kind()), w.Name, pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// hasFilters returns true if any payload filter is configured. Only used for logs.
+func (w *WebhookConfig) hasFilters() bool {
+	return len(w.FilterURLs)+len(w.FilterApps)+len(w.FilterPaths)+len(w.ExcludePaths)+len(w.FilterErrors) > 0 ||
+		w.MinSize > 0 || w.MaxSize > 0
+}
+
+// logFilters formats the payload filters for the startup log.
+func (w *WebhookConfig) logFilters() string {
+	var filters []string
+
+	for name, list := range map[string]StringSlice{
+		"urls": w.FilterURLs, "apps": w.FilterApps, "paths": w.FilterPaths,
+		"exclude_paths": w.ExcludePaths, "errors": w.FilterErrors,
+	} {
+		if len(list) > 0 {
+			filters = append(filters, name+": "+strings.Join(list, "; "))
+		}
+	}
+
+	sort.Strings(filters)
+
+	if w.MinSize > 0 {
+		filters = append(filters, fmt.Sprint("min_size: ", w.MinSize))
+	}
+
+	if w.MaxSize > 0 {
+		filters = append(filters, fmt.Sprint("max_size: ", w.MaxSize))
+	}
+
+	return `"` + strings.Join(filters, ", ") + `"`
+}
+
+// Wants returns true if a hook should run for a payload. The event and app exclusions are checked first.
+// Every configured filter must match; a filter with a list matches when any value in the list matches.
+func (w *WebhookConfig) Wants(payload *WebhookPayload) bool {
+	if !w.HasEvent(payload.Event) || w.Excluded(payload.App) {
+		return false
+	}
+
+	var (
+		size    uint64
+		message string
+	)
+
+	if payload.Data != nil {
+		size, message = payload.Data.Bytes, payload.Data.Error
+	}
+
+	switch {
+	case len(w.FilterApps) > 0 && !w.FilterApps.HasApp(payload.App),
+		len(w.FilterURLs) > 0 && !matchURL(w.FilterURLs, payload.URL),
+		len(w.FilterPaths) > 0 && !matchPath(w.FilterPaths, payload.Path),
+		matchPath(w.ExcludePaths, payload.Path),
+		len(w.FilterErrors) > 0 && !matchError(w.FilterErrors, message),
+		w.MinSize > 0 && size < uint64(w.MinSize),
+		w.MaxSize > 0 && size > uint64(w.MaxSize):
+		return false
+	default:
+		return true
+	}
+}
+
+// matchURL returns true if the app instance URL is one of the URLs, or begins with one followed by
+// a port or a path. This way "http://radarr" matches "http://radarr:7878", but not "http://radarr4k:7878".
+func matchURL(urls StringSlice, instance string) bool {
+	instance = strings.ToLower(strings.TrimSuffix(instance, "/"))
+
+	for _, url := range urls {
+		url = strings.ToLower(strings.TrimSuffix(url, "/"))
+		if rest, ok := strings.CutPrefix(instance, url); url != "" && ok &&
+			(rest == "" || strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPath returns true if the path, or a folder the path is in, matches one of the glob patterns.
+// This way "/downloads/private" and "/downloads/private/*" both match everything in that folder.
+func matchPath(patterns StringSlice, path string) bool {
+	if path == "" {
+		return false
+	}
+
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(filepath.Clean(pattern), dir); matched {
+				return true
+			}
+		}
+
+		if parent := filepath.Dir(dir); parent == dir {
+			return false
+		}
+	}
+}
+
+// matchError returns true if the error message contains one of the strings. Case insensitive.
+func matchError(contains StringSlice, message string) bool {
+	if message == "" {
+		return false
+	}
+
+	message = strings.ToLower(message)
+
+	for _, str := range contains {
+		if strings.Contains(message, strings.ToLower(str)) {
+			return true
+		}
+	}
+
+	return false
+}
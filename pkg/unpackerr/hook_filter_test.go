@@ -0,0 +1,81 @@
+package unpackerr
+
+import (
+	"testing"
+
+	"golift.io/starr"
+)
+
+func TestHookFilters(t *testing.T) {
+	t.Parallel()
+
+	hook := &WebhookConfig{
+		Name:         "discord",
+		Events:       ExtractStatuses{EXTRACTFAILED},
+		FilterURLs:   StringSlice{"http://radarr4k:7878/"},
+		FilterPaths:  StringSlice{"/downloads/private"},
+		ExcludePaths: StringSlice{"/downloads/private/*.sample"},
+		FilterErrors: StringSlice{"crc"},
+		MinSize:      1024,
+	}
+
+	if err := hook.validateFilters(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	failed := func() *WebhookPayload {
+		return &WebhookPayload{
+			App:   starr.Radarr,
+			URL:   "http://Radarr4K:7878",
+			Path:  "/downloads/private/Movie.2024/Movie.rar",
+			Event: EXTRACTFAILED,
+			Data:  &XtractPayload{Error: "CRC failed in Movie.rar", Bytes: 2048},
+		}
+	}
+
+	if !hook.Wants(failed()) {
+		t.Fatal("expected the hook to want a matching payload")
+	}
+
+	for _, url := range []string{"http://radarr4k:7878", "http://radarr4k:7878/radarr", "HTTP://RADARR4K"} {
+		if !matchURL(StringSlice{url}, "http://radarr4k:7878/radarr/") {
+			t.Fatalf("expected %s to match the instance", url)
+		}
+	}
+
+	for _, url := range []string{"http://radarr", "http://radarr4k:78", "http://radarr4k:7878/rad"} {
+		if matchURL(StringSlice{url}, "http://radarr4k:7878/radarr") {
+			t.Fatalf("expected %s to not match the instance", url)
+		}
+	}
+
+	for name, change := range map[string]func(*WebhookPayload){
+		"event":        func(p *WebhookPayload) { p.Event = EXTRACTED },
+		"instance":     func(p *WebhookPayload) { p.URL = "http://radarr:7878" },
+		"path":         func(p *WebhookPayload) { p.Path = "/downloads/public/Movie.2024" },
+		"exclude_path": func(p *WebhookPayload) { p.Path = "/downloads/private/movie.sample" },
+		"error":        func(p *WebhookPayload) { p.Data.Error = "unexpected EOF" },
+		"size":         func(p *WebhookPayload) { p.Data.Bytes = 12 },
+		"no data":      func(p *WebhookPayload) { p.Data = nil },
+	} {
+		payload := failed()
+		if change(payload); hook.Wants(payload) {
+			t.Fatalf("%s: expected the hook to not want the payload", name)
+		}
+	}
+
+	apps := &WebhookConfig{
+		Events:     ExtractStatuses{WAITING},
+		FilterApps: StringSlice{"sonarr"},
+		Exclude:    StringSlice{"radarr"},
+		MaxSize:    100,
+	}
+
+	if apps.Wants(failed()) || !apps.Wants(&WebhookPayload{App: starr.Sonarr}) {
+		t.Fatal("expected only sonarr payloads below the max size to be wanted")
+	}
+
+	if err := (&WebhookConfig{FilterPaths: StringSlice{"/downloads/["}}).validateFilters(); err == nil {
+		t.Fatal("expected an error with an invalid glob")
+	}
+}
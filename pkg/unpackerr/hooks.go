@@ -0,0 +1,177 @@
+package unpackerr
+
+/* Every webhook and command hook has its own queue and worker, so a slow hook does not hold up the others. */
+
+import (
+	"time"
+)
+
+// defaultHookQueue is the default number of payloads each hook may have waiting.
+const defaultHookQueue = updateChanBuf
+
+// setupQueue creates the payload queue for a webhook or command hook.
+func (w *WebhookConfig) setupQueue() {
+	if w.QueueSize == 0 {
+		w.QueueSize = defaultHookQueue
+	}
+
+	if w.queue == nil {
+		w.queue = make(chan *WebhookPayload, w.QueueSize)
+	}
+}
+
+// kind returns Webhook or Cmdhook for logs.
+func (w *WebhookConfig) kind() string {
+	if w.Command != "" {
+		return "Cmdhook"
+	}
+
+	return "Webhook"
+}
+
+// queueHook gives a payload to a hook's worker. This never blocks the main go routine:
+// when the hook's queue is full the payload is dropped, logged and counted.
+func (u *Unpackerr) queueHook(hook *WebhookConfig, payload *WebhookPayload) {
+	if hook.queue == nil {
+		return // Hooks are not running; validation never ran.
+	}
+
+	// Each hook gets a copy; command hooks put their config into the payload.
+	hookPayload := *payload
+
+	select {
+	case hook.queue <- &hookPayload:
+	default:
+		hook.Lock()
+		hook.dropped++
+		dropped := hook.dropped
+		hook.Unlock()
+
+		u.Errorf("[%s] Queue full (%d), dropped payload (%s = %s): %s, %d dropped total",
+			hook.kind(), cap(hook.queue), payload.Path, payload.Event, hook.Name, dropped)
+	}
+}
+
+// startHookWorkers starts one go routine for each webhook, command hook and email, and one for MQTT.
+// Each worker runs its payloads one at a time, so they stay in order for that hook.
+func (u *Unpackerr) startHookWorkers() {
+	for _, hook := range u.Webhook {
+		go u.webhookWorker(hook)
+	}
+
+	for _, hook := range u.Cmdhook {
+		if hook.Gate == "" { // Gates run synchronously, they have no queue.
+			go u.cmdhookWorker(hook)
+		}
+	}
+
+	for _, email := range u.Email {
+		go u.emailWorker(email)
+	}
+
+	if u.MQTT.Enabled() {
+		go u.mqttWorker()
+	}
+}
+
+// webhookWorker sends payloads for one webhook. Payloads are collected and sent together when
+// the webhook has a digest interval, or when it's over its rate limit.
+func (u *Unpackerr) webhookWorker(hook *WebhookConfig) {
+	var (
+		outbox  <-chan time.Time
+		digest  <-chan time.Time
+		pending []*WebhookPayload
+		limiter = &rateLimiter{limit: hook.RateLimit}
+	)
+
+	if hook.outbox != nil {
+		ticker := time.NewTicker(outboxInterval)
+		defer ticker.Stop()
+
+		outbox = ticker.C
+
+		u.flushOutbox(hook) // Send payloads saved before a restart.
+	}
+
+	if interval := hook.digestInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		digest = ticker.C
+	}
+
+	for {
+		select {
+		case <-outbox:
+			if hook.outbox.Len() > 0 {
+				u.flushOutbox(hook)
+			}
+		case <-digest:
+			u.sendDigestWithLog(hook, pending)
+			pending = nil
+		case payload, ok := <-hook.queue:
+			if !ok {
+				return
+			}
+
+			switch {
+			case payload.Event == REPORT:
+				u.sendWebhookWithLog(hook, payload) // Reports are already a summary.
+			case hook.Digest.Duration > 0:
+				pending = append(pending, payload)
+			case !limiter.allow(time.Now()):
+				u.debugf(logHooks, "[Webhook] Rate limited (%s = %s): %s: added to the next digest",
+					payload.Path, payload.Event, hook.Name)
+				pending = append(pending, payload)
+			default:
+				u.sendWebhookWithLog(hook, payload)
+			}
+		}
+	}
+}
+
+func (u *Unpackerr) cmdhookWorker(hook *WebhookConfig) {
+	for payload := range hook.queue {
+		u.runCmdhookWithLog(hook, payload)
+	}
+}
+
+// hookQueueDepth returns the number of payloads waiting in every hook queue.
+func (u *Unpackerr) hookQueueDepth() int {
+	var depth int
+
+	for _, hook := range u.Webhook {
+		depth += len(hook.queue)
+	}
+
+	for _, hook := range u.Cmdhook {
+		depth += len(hook.queue)
+	}
+
+	for _, email := range u.Email {
+		depth += len(email.queue)
+	}
+
+	return depth
+}
+
+// HookDrops returns the total count of payloads dropped because a hook or email queue was full.
+func (u *Unpackerr) HookDrops() uint {
+	var dropped uint
+
+	for _, hooks := range [][]*WebhookConfig{u.Webhook, u.Cmdhook} {
+		for _, hook := range hooks {
+			hook.Lock()
+			dropped += hook.dropped
+			hook.Unlock()
+		}
+	}
+
+	for _, email := range u.Email {
+		email.Lock()
+		dropped += email.dropped
+		email.Unlock()
+	}
+
+	return dropped
+}
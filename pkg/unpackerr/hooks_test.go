@@ -0,0 +1,49 @@
+package unpackerr
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestQueueHookDropsWhenFull(t *testing.T) {
+	t.Parallel()
+
+	discard := log.New(io.Discard, "", 0)
+	webhook := &WebhookConfig{Name: "slow", QueueSize: 2}
+	cmdhook := &WebhookConfig{Name: "cmd", Command: "true"}
+	unpackerr := &Unpackerr{
+		Config: &Config{Webhook: []*WebhookConfig{webhook}, Cmdhook: []*WebhookConfig{cmdhook}},
+		Logger: &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+
+	webhook.setupQueue()
+	cmdhook.setupQueue()
+
+	if cap(cmdhook.queue) != defaultHookQueue {
+		t.Fatalf("expected the default queue size %d, got %d", defaultHookQueue, cap(cmdhook.queue))
+	}
+
+	// No workers are running, so the webhook queue fills up; this must not block.
+	payload := &WebhookPayload{Path: "/downloads/item"}
+	for _, path := range []string{"first", "second", "third"} {
+		payload.Path = path
+		unpackerr.queueHook(webhook, payload)
+	}
+
+	unpackerr.queueHook(cmdhook, payload)
+
+	if dropped := unpackerr.HookDrops(); dropped != 1 {
+		t.Fatalf("expected 1 dropped payload, got %d", dropped)
+	}
+
+	if depth := unpackerr.hookQueueDepth(); depth != 3 {
+		t.Fatalf("expected 3 queued payloads, got %d", depth)
+	}
+
+	for _, path := range []string{"first", "second"} {
+		if queued := <-webhook.queue; queued.Path != path || queued == payload {
+			t.Fatalf("expected a copy of payload %s in order, got: %s", path, queued.Path)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package unpackerr
+
+/* Every item keeps its own small log: queue, start, progress, passwords, errors and deletes.
+   The log is available from the web server, and may be written as a report beside the extracted files. */
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"golift.io/starr"
+	"golift.io/version"
+	"golift.io/xtractr"
+)
+
+const (
+	itemLogSize = 200 // Lines kept in each item's log.
+	reportFile  = "unpackerr.report.json"
+)
+
+// ItemLogEntry is one line in an item's log.
+type ItemLogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Status  string    `json:"status,omitempty"`
+	Message string    `json:"msg"`
+}
+
+// ItemLog is a bounded log buffer for one item. The oldest lines are dropped when it's full.
+// The main go routine writes it, and the web server reads it.
+type ItemLog struct {
+	entries []*ItemLogEntry
+	dropped uint
+	sync.Mutex
+}
+
+// ExtractReport is the extraction report for one item. The web server returns
+// this for an item's log, and it's written beside the extracted files.
+type ExtractReport struct {
+	Name    string          `json:"name"`
+	App     starr.App       `json:"app"`
+	URL     string          `json:"url,omitempty"`
+	Path    string          `json:"path"`
+	Status  ExtractStatus   `json:"status"`
+	Retries uint            `json:"retries"`
+	Updated time.Time       `json:"updated"`
+	Data    *XtractPayload  `json:"data,omitempty"`
+	Log     []*ItemLogEntry `json:"log"`
+	Dropped uint            `json:"dropped,omitempty"` // Log lines that did not fit.
+	Version string          `json:"version"`
+}
+
+// reportRequest asks the main go routine for an item's report.
+type reportRequest struct {
+	name  string
+	reply chan *ExtractReport
+}
+
+func (l *ItemLog) add(entry *ItemLogEntry) {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.entries) >= itemLogSize {
+		l.entries = append(l.entries[:0], l.entries[1:]...)
+		l.dropped++
+	}
+
+	l.entries = append(l.entries, entry)
+}
+
+// Entries returns a copy of the lines in the log, and the count of lines that were dropped.
+func (l *ItemLog) Entries() ([]*ItemLogEntry, uint) {
+	if l == nil {
+		return []*ItemLogEntry{}, 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	return append([]*ItemLogEntry{}, l.entries...), l.dropped
+}
+
+// record adds a line to an item's log. This only runs in the main go routine. The item may be nil.
+func (item *Extract) record(level slog.Level, status, msg string) {
+	if item == nil {
+		return
+	}
+
+	if item.log == nil {
+		item.log = &ItemLog{}
+	}
+
+	item.log.add(&ItemLogEntry{Time: time.Now(), Level: level.String(), Status: status, Message: msg})
+}
+
+// report creates an extraction report for an item.
+func (item *Extract) report(name string) *ExtractReport {
+	entries, dropped := item.log.Entries()
+
+	return &ExtractReport{
+		Name:    name,
+		App:     item.App,
+		URL:     item.URL,
+		Path:    item.Path,
+		Status:  item.Status,
+		Retries: item.Retries,
+		Updated: item.Updated,
+		Data:    newPayload(item).Data,
+		Log:     entries,
+		Dropped: dropped,
+		Version: version.Version,
+	}
+}
+
+// writeReport writes an item's extraction report beside the extracted files, if the item wants one.
+// This runs in the main go routine after the item's status is updated for a finished extraction.
+func (u *Unpackerr) writeReport(name string, item *Extract, resp *xtractr.Response) {
+	if item == nil || !item.Report {
+		return
+	}
+
+	dir := resp.Output
+	if !isDir(dir) {
+		dir = item.Path
+	}
+
+	if !isDir(dir) {
+		dir = filepath.Dir(dir) // A watched archive file.
+	}
+
+	data, err := json.MarshalIndent(item.report(name), "", "  ")
+	if err != nil {
+		u.itemLog(name, item).Errorf("[%s] Encoding Extraction Report: %v", item.App, err)
+		return
+	}
+
+	fileMode, _ := strconv.ParseUint(u.FileMode, bits8, base32)
+	path := filepath.Join(dir, reportFile)
+
+	if err := os.WriteFile(path, append(data, '\n'), os.FileMode(fileMode)); err != nil {
+		u.itemLog(name, item).Errorf("[%s] Writing Extraction Report: %v", item.App, err)
+		return
+	}
+
+	u.itemLog(name, item).in(logXtractr).Debugf("[%s] Wrote Extraction Report: %s", item.App, path)
+}
+
+func isDir(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
+
+// handleReportRequest replies with an item's report. This runs in the main go routine.
+func (u *Unpackerr) handleReportRequest(req *reportRequest) {
+	name := req.name
+	if u.Map[name] == nil {
+		name = "/" + name // Folder names are absolute paths.
+	}
+
+	if item := u.Map[name]; item != nil {
+		req.reply <- item.report(name)
+	} else {
+		req.reply <- nil
+	}
+}
+
+// handleItemLog returns an item's log and extraction report. The item name may contain slashes,
+// so the route is a catch-all: /api/v1/queue/<name>/log.
+func (u *Unpackerr) handleItemLog(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	name, found := strings.CutSuffix(strings.TrimPrefix(params.ByName("name"), "/"), "/log")
+	if !found || name == "" {
+		http.NotFound(resp, req)
+		return
+	}
+
+	request := &reportRequest{name: name, reply: make(chan *ExtractReport, 1)}
+
+	select {
+	case u.reports <- request:
+	case <-req.Context().Done():
+		return
+	}
+
+	var report *ExtractReport
+
+	select {
+	case report = <-request.reply:
+	case <-req.Context().Done():
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(resp)
+	encoder.SetIndent("", "  ")
+
+	if report == nil {
+		resp.WriteHeader(http.StatusNotFound)
+		_ = encoder.Encode(map[string]string{"error": "item not found: " + name})
+
+		return
+	}
+
+	_ = encoder.Encode(report)
+}
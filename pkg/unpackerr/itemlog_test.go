@@ -0,0 +1,122 @@
+package unpackerr
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"golift.io/starr"
+	"golift.io/xtractr"
+)
+
+// testReport decodes the parts of an extraction report the tests check. Statuses do not unmarshal.
+type testReport struct {
+	Name string          `json:"name"`
+	App  starr.App       `json:"app"`
+	Log  []*ItemLogEntry `json:"log"`
+}
+
+func TestItemLogBounded(t *testing.T) {
+	t.Parallel()
+
+	item := &Extract{}
+	for range itemLogSize + 5 {
+		item.record(slog.LevelInfo, "queued", "line")
+	}
+
+	if entries, dropped := item.log.Entries(); len(entries) != itemLogSize || dropped != 5 {
+		t.Fatalf("expected %d lines and 5 dropped, got: %d, %d", itemLogSize, len(entries), dropped)
+	}
+
+	if entries, _ := (*ItemLog)(nil).Entries(); entries == nil {
+		t.Fatal("expected an empty list from a nil log")
+	}
+
+	(*Extract)(nil).record(slog.LevelInfo, "", "nil items are ignored")
+}
+
+func TestItemLogAPI(t *testing.T) {
+	t.Parallel()
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config:  &Config{},
+		Logger:  &Logger{Info: discard, Error: discard, Debug: discard},
+		History: &History{Map: map[string]*Extract{}},
+		reports: make(chan *reportRequest),
+	}
+	folder := &Extract{App: FolderString, Path: "/downloads/folder/Movie", Status: EXTRACTING}
+	unpackerr.Map["/downloads/folder/Movie"] = folder
+
+	unpackerr.itemLog("/downloads/folder/Movie", folder).Printf("[Folder] Extraction Started")
+	unpackerr.itemLog("/downloads/folder/Movie", folder).status(EXTRACTFAILED).Errorf("[Folder] Extraction Failed")
+
+	go func() {
+		for req := range unpackerr.reports {
+			unpackerr.handleReportRequest(req)
+		}
+	}()
+	defer close(unpackerr.reports)
+
+	router := httprouter.New()
+	router.GET("/api/v1/queue/*name", unpackerr.handleItemLog)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/queue/downloads/folder/Movie/log", nil))
+
+	var report testReport
+	if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil || resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response: %d: %v: %s", resp.Code, err, resp.Body.String())
+	}
+
+	if report.Name != "/downloads/folder/Movie" || len(report.Log) != 2 ||
+		report.Log[1].Level != "ERROR" || report.Log[1].Status != EXTRACTFAILED.String() {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/queue/missing/log", nil))
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("expected a missing item to be not found, got: %d", resp.Code)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config: &Config{FileMode: "0644"},
+		Logger: &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+	dir := t.TempDir()
+	item := &Extract{App: starr.Sonarr, Path: filepath.Join(dir, "Show.S01"), Status: EXTRACTED, IDs: map[string]any{}}
+	resp := &xtractr.Response{Output: dir, X: &xtractr.Xtract{}}
+
+	unpackerr.writeReport("Show.S01", item, resp)
+
+	if _, err := os.Stat(filepath.Join(dir, reportFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected no report without the report setting: %v", err)
+	}
+
+	item.Report = true
+	unpackerr.writeReport("Show.S01", item, resp)
+
+	data, err := os.ReadFile(filepath.Join(dir, reportFile))
+	if err != nil {
+		t.Fatalf("expected a report file: %v", err)
+	}
+
+	var report testReport
+	if err := json.Unmarshal(data, &report); err != nil || report.Name != "Show.S01" || report.App != starr.Sonarr {
+		t.Fatalf("unexpected report: %v: %s", err, data)
+	}
+}
@@ -49,6 +49,7 @@ func (u *Unpackerr) logLidarr() {
 			u.Lidarr[0].URL, u.Lidarr[0].APIKey != "", u.Lidarr[0].Timeout.String(),
 			u.Lidarr[0].ValidSSL, u.Lidarr[0].Protocols, u.Lidarr[0].Syncthing,
 			u.Lidarr[0].DeleteOrig, u.Lidarr[0].DeleteDelay.String(), u.Lidarr[0].Paths,
+			u.Lidarr[0].MinSize, u.Lidarr[0].MaxSize, u.Lidarr[0].Atomic, u.Lidarr[0].ExtractPath,
 			u.Lidarr[0].SplitFlac)
 	} else {
 		u.Printf(" => Lidarr Config: %d servers", count)
@@ -57,6 +58,7 @@ func (u *Unpackerr) logLidarr() {
 			u.Printf(starrLogPfx+starrLogLine+", split_flac:%v",
 				f.URL, f.APIKey != "", f.Timeout.String(), f.ValidSSL, f.Protocols,
 				f.Syncthing, f.DeleteOrig, f.DeleteDelay.String(), f.Paths,
+				f.MinSize, f.MaxSize, f.Atomic, f.ExtractPath,
 				f.SplitFlac)
 		}
 	}
@@ -65,7 +67,7 @@ func (u *Unpackerr) logLidarr() {
 // getLidarrQueue saves the Lidarr Queue(s).
 func (u *Unpackerr) getLidarrQueue(server *LidarrConfig, start time.Time) {
 	if server.APIKey == "" {
-		u.Debugf("Lidarr (%s): skipped, no API key", server.URL)
+		u.debugf(logPoller, "Lidarr (%s): skipped, no API key", server.URL)
 		return
 	}
 
@@ -94,7 +96,8 @@ func (u *Unpackerr) checkLidarrQueue(now time.Time) {
 		for _, record := range server.Queue.Records {
 			switch x, ok := u.Map[record.Title]; {
 			case ok && x.Status == EXTRACTED && u.isComplete(record.Status, record.Protocol, server.Protocols):
-				u.Debugf("%s (%s): Item Waiting for Import (%s): %v", starr.Lidarr, server.URL, record.Protocol, record.Title)
+				u.debugf(logPoller, "%s (%s): Item Waiting for Import (%s): %v",
+					starr.Lidarr, server.URL, record.Protocol, record.Title)
 			case !ok && u.isComplete(record.Status, record.Protocol, server.Protocols):
 				u.Map[record.Title] = &Extract{
 					App:         starr.Lidarr,
@@ -103,6 +106,12 @@ func (u *Unpackerr) checkLidarrQueue(now time.Time) {
 					Status:      WAITING,
 					DeleteOrig:  server.DeleteOrig,
 					DeleteDelay: server.DeleteDelay.Duration,
+					MinSize:     server.MinSize,
+					MaxSize:     server.MaxSize,
+					ExtractPath: server.ExtractPath,
+					Atomic:      server.Atomic,
+					Report:      server.Report,
+					passwords:   server.passwords,
 					Syncthing:   server.Syncthing,
 					SplitFlac:   server.SplitFlac,
 					Path:        u.getDownloadPath(record.OutputPath, starr.Lidarr, record.Title, server.Paths),
@@ -116,10 +125,11 @@ func (u *Unpackerr) checkLidarrQueue(now time.Time) {
 					},
 				}
 				u.Map[record.Title].XProg = &ExtractProgress{Extract: u.Map[record.Title]}
+				u.traceItem(record.Title, u.Map[record.Title], now)
 
 				fallthrough
 			default:
-				u.Debugf("%s: (%s): %s (%s:%d%%): %v",
+				u.debugf(logPoller, "%s: (%s): %s (%s:%d%%): %v",
 					starr.Lidarr, server.URL, record.Status, record.Protocol,
 					percent(record.Sizeleft, record.Size), record.Title)
 			}
@@ -274,7 +284,7 @@ func (u *Unpackerr) importSplitFlacTracks(item *Extract, server *LidarrConfig) {
 		return
 	}
 
-	u.Debugf("[Lidarr] Sending manual import command: replaceExisting=%v, importMode=%q, files=%d",
+	u.debugf(logPoller, "[Lidarr] Sending manual import command: replaceExisting=%v, importMode=%q, files=%d",
 		cmd.ReplaceExistingFiles, cmd.ImportMode, len(cmd.Files))
 
 	if _, err = server.SendManualImportCommand(cmd); err != nil {
@@ -0,0 +1,220 @@
+package unpackerr
+
+/* Structured log formats. The text format writes with the standard library loggers like it always has.
+   The json and logfmt formats write log/slog records to the same outputs, including the rotated log file.
+   A log target (journald or syslog) gets every line too, in place of stdout. */
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Log formats.
+const (
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+// logHandler sends error records to the error output, and every other record to the info output.
+type logHandler struct {
+	info  slog.Handler
+	error slog.Handler
+}
+
+// itemLogger writes log lines about one item. Structured formats include the item's details as fields.
+type itemLogger struct {
+	*Logger
+	item      *Extract
+	state     string
+	subsystem string // Debug lines are written when debug logging is on for this subsystem.
+	attrs     []slog.Attr
+}
+
+// Enabled satisfies slog.Handler.
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.info.Enabled(ctx, level)
+}
+
+// Handle satisfies slog.Handler.
+func (h *logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelError {
+		return h.error.Handle(ctx, record) //nolint:wrapcheck
+	}
+
+	return h.info.Handle(ctx, record) //nolint:wrapcheck
+}
+
+// WithAttrs satisfies slog.Handler.
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logHandler{info: h.info.WithAttrs(attrs), error: h.error.WithAttrs(attrs)}
+}
+
+// WithGroup satisfies slog.Handler.
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{info: h.info.WithGroup(name), error: h.error.WithGroup(name)}
+}
+
+// validLogFormat returns a known log format; unknown formats are text.
+func validLogFormat(format string) string {
+	switch format = strings.ToLower(strings.TrimSpace(format)); format {
+	case LogFormatJSON, LogFormatLogfmt:
+		return format
+	default:
+		return LogFormatText
+	}
+}
+
+// setFormat creates the structured logger for the json and logfmt formats.
+// This must run after the outputs are set on the Info and Error loggers.
+func (l *Logger) setFormat(format string, debug bool) {
+	// Debug records are filtered by the runtime log levels before they get here.
+	opts := &slog.HandlerOptions{AddSource: debug, Level: slog.LevelDebug}
+
+	newHandler := func(writer io.Writer) slog.Handler {
+		if format == LogFormatJSON {
+			return slog.NewJSONHandler(writer, opts)
+		}
+
+		return slog.NewTextHandler(writer, opts)
+	}
+
+	switch format {
+	case LogFormatJSON, LogFormatLogfmt:
+		l.structured = slog.New(&logHandler{info: newHandler(l.Info.Writer()), error: newHandler(l.Error.Writer())})
+	default:
+		l.structured = nil
+	}
+}
+
+// output writes a log line to a text logger, or as a structured record when a structured format is set.
+// Printf, Errorf and Debugf call this directly so the line that called them is logged as the source.
+func (l *Logger) output(logger *log.Logger, level slog.Level, attrs []slog.Attr, msg string) {
+	l.target.send(level, msg, attrs)
+
+	if l.structured == nil {
+		if err := logger.Output(callDepth+1, msg); err != nil {
+			fmt.Println("Logger Error:", err) //nolint:forbidigo
+		}
+
+		return
+	}
+
+	if !l.structured.Enabled(context.Background(), level) {
+		return
+	}
+
+	var pcs [1]uintptr
+
+	runtime.Callers(callDepth+1, pcs[:]) // skip Callers, output and our caller.
+
+	record := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	if app := logApp(msg); app != "" && !hasAttr(attrs, "app") {
+		record.AddAttrs(slog.String("app", app))
+	}
+
+	record.AddAttrs(attrs...)
+
+	if err := l.structured.Handler().Handle(context.Background(), record); err != nil {
+		fmt.Println("Logger Error:", err) //nolint:forbidigo
+	}
+}
+
+// logApp returns the app name from a log line that begins with one, like "[Sonarr] Status: ...".
+func logApp(msg string) string {
+	if !strings.HasPrefix(msg, "[") {
+		return ""
+	}
+
+	app, _, found := strings.Cut(msg[1:], "]")
+	if !found || strings.ContainsAny(app, " /") {
+		return ""
+	}
+
+	return app
+}
+
+func hasAttr(attrs []slog.Attr, key string) bool {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// itemLog returns a logger for lines about one item. The item may be nil.
+// Lines written about an item are also kept in the item's own log buffer.
+func (l *Logger) itemLog(name string, item *Extract) *itemLogger {
+	logger := &itemLogger{Logger: l, item: item, attrs: []slog.Attr{slog.String("item", name)}}
+
+	if item != nil {
+		logger.attrs = append(logger.attrs, slog.String("app", string(item.App)))
+		logger.state = item.Status.String()
+
+		if item.URL != "" {
+			logger.attrs = append(logger.attrs, slog.String("url", item.URL))
+		}
+	}
+
+	return logger
+}
+
+// status sets the status field for an item's log lines, for lines written before the item's status changes.
+func (i *itemLogger) status(status ExtractStatus) *itemLogger {
+	i.state = status.String()
+	return i
+}
+
+// in sets the subsystem for an item's debug lines.
+func (i *itemLogger) in(subsystem string) *itemLogger {
+	i.subsystem = subsystem
+	return i
+}
+
+// elapsed adds a duration field to an item's log lines.
+func (i *itemLogger) elapsed(duration time.Duration) *itemLogger {
+	i.attrs = append(i.attrs, slog.Duration("duration", duration))
+	return i
+}
+
+// fields returns the structured log fields for an item's log lines.
+func (i *itemLogger) fields() []slog.Attr {
+	if i.state == "" {
+		return i.attrs
+	}
+
+	return append(slices.Clip(i.attrs), slog.String("status", i.state))
+}
+
+// Debugf writes Debug log lines about an item.
+func (i *itemLogger) Debugf(msg string, v ...any) {
+	msg = fmt.Sprintf(msg, v...)
+	if i.levels.enabled(i.subsystem) {
+		i.output(i.Debug, slog.LevelDebug, i.fields(), msg)
+	}
+
+	i.item.record(slog.LevelDebug, i.state, msg)
+}
+
+// Printf writes log lines about an item.
+func (i *itemLogger) Printf(msg string, v ...any) {
+	msg = fmt.Sprintf(msg, v...)
+	i.output(i.Info, slog.LevelInfo, i.fields(), msg)
+	i.item.record(slog.LevelInfo, i.state, msg)
+}
+
+// Errorf writes log errors about an item.
+func (i *itemLogger) Errorf(msg string, v ...any) {
+	msg = fmt.Sprintf(msg, v...)
+	i.output(i.Error, slog.LevelError, i.fields(), msg)
+	i.item.record(slog.LevelError, i.state, msg)
+}
@@ -0,0 +1,82 @@
+package unpackerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"golift.io/starr"
+)
+
+func formatLogger(format string, debug bool) (*Logger, *bytes.Buffer, *bytes.Buffer) {
+	var info, errs bytes.Buffer
+
+	logger := &Logger{
+		Info:   log.New(&info, "[INFO] ", 0),
+		Error:  log.New(&errs, "[ERROR] ", 0),
+		Debug:  log.New(&info, "[DEBUG] ", 0),
+		levels: newLogLevels(debug),
+	}
+	logger.setFormat(validLogFormat(format), debug)
+
+	return logger, &info, &errs
+}
+
+func TestLogFormatJSON(t *testing.T) {
+	t.Parallel()
+
+	logger, info, errs := formatLogger(" JSON", true)
+	item := &Extract{App: starr.Sonarr, URL: "http://sonarr:8989", Status: EXTRACTING}
+
+	logger.itemLog("Show.S01", item).status(EXTRACTED).elapsed(90 * time.Second).Printf("[Sonarr] Extraction Finished")
+	logger.Errorf("[Folder] Extraction Failed: %s", "/downloads/folder")
+
+	var line map[string]any
+	if err := json.Unmarshal(info.Bytes(), &line); err != nil {
+		t.Fatalf("invalid json log line: %v: %s", err, info.String())
+	}
+
+	for key, expect := range map[string]any{
+		"level": "INFO", "msg": "[Sonarr] Extraction Finished", "app": "Sonarr", "url": "http://sonarr:8989",
+		"item": "Show.S01", "status": "extracted", "duration": float64(90 * time.Second),
+	} {
+		if line[key] != expect {
+			t.Fatalf("expected %s to be %v, got: %v", key, expect, line[key])
+		}
+	}
+
+	if _, ok := line["time"]; !ok {
+		t.Fatal("expected a time stamp")
+	}
+
+	if source, _ := line["source"].(map[string]any); !strings.HasSuffix(source["file"].(string), "logformat_test.go") {
+		t.Fatalf("expected the caller as the source, got: %v", line["source"])
+	}
+
+	if !strings.Contains(errs.String(), `"level":"ERROR"`) || !strings.Contains(errs.String(), `"app":"Folder"`) {
+		t.Fatalf("expected the error on the error output, got: %s", errs.String())
+	}
+}
+
+func TestLogFormatLogfmt(t *testing.T) {
+	t.Parallel()
+
+	logger, info, _ := formatLogger("logfmt", false)
+	logger.Debugf("hidden without debug")
+	logger.itemLog("Movie", nil).Printf("[Radarr] Imported: %s", "Movie")
+
+	if out := info.String(); strings.Contains(out, "hidden") ||
+		!strings.Contains(out, `level=INFO msg="[Radarr] Imported: Movie" app=Radarr item=Movie`) {
+		t.Fatalf("unexpected logfmt line: %s", out)
+	}
+
+	logger, info, _ = formatLogger("", false)
+	logger.itemLog("Movie", &Extract{App: starr.Radarr}).Printf("[Radarr] Imported: %s", "Movie")
+
+	if out := info.String(); out != "[INFO] [Radarr] Imported: Movie\n" {
+		t.Fatalf("expected the text format to be unchanged, got: %s", out)
+	}
+}
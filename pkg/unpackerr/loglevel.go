@@ -0,0 +1,250 @@
+package unpackerr
+
+/* Runtime log levels. Debug logging may be turned on and off while the app runs, for everything
+   or for single subsystems, from the API or the tray menu. A timed debug mode reverts by itself. */
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Log subsystems. Debug logging may be turned on for each of these.
+const (
+	logPoller  = "poller"  // Starr app queues and the items in them.
+	logFolders = "folders" // Watched folders and their file system events.
+	logXtractr = "xtractr" // Extractions, from the xtractr library and from us.
+	logHooks   = "hooks"   // Webhooks, command hooks, email and MQTT.
+	logHTTP    = "http"    // Requests to our web server.
+)
+
+// Tray menu settings for debug logging.
+const (
+	debugTimed = 15 * time.Minute // How long the tray's timed debug mode lasts.
+	debugSub   = "debug_sub_"     // Prefix for the subsystem check boxes.
+)
+
+// ErrLogLevel is returned when a log level change has an invalid parameter.
+var ErrLogLevel = errors.New("invalid log level parameter")
+
+// LogLevels are the current runtime log levels, as returned by the log level API.
+type LogLevels struct {
+	Debug      bool       `json:"debug"`            // Debug logging for everything.
+	Subsystems []string   `json:"subsystems"`       // Debug logging for only these subsystems.
+	Until      time.Time  `json:"until,omitzero"`   // When a timed debug mode reverts.
+	Revert     *LogLevels `json:"revert,omitempty"` // The log levels restored when a timed debug mode ends.
+}
+
+// logLevels holds the runtime log levels, and is safe for concurrent use.
+type logLevels struct {
+	mu         sync.RWMutex
+	debug      bool
+	subsystems map[string]bool
+	until      time.Time
+	revert     *LogLevels    // Only set during a timed debug mode.
+	timer      *time.Timer   // Ends a timed debug mode.
+	generation uint          // Keeps a stopped timer from ending a newer timed debug mode.
+	changed    chan struct{} // The tray watches this to update its check boxes.
+}
+
+// subsystemLogger is passed to the folder watcher and the xtractr library.
+// It writes debug lines only when debug logging is on for its subsystem.
+type subsystemLogger struct {
+	*Logger
+	subsystem string
+}
+
+func logSubsystems() []string {
+	return []string{logPoller, logFolders, logXtractr, logHooks, logHTTP}
+}
+
+func newLogLevels(debug bool) *logLevels {
+	return &logLevels{debug: debug, subsystems: make(map[string]bool), changed: make(chan struct{}, 1)}
+}
+
+// enabled returns true if debug lines for a subsystem are written. An empty subsystem
+// is general debug logging. The levels may be nil; debug logging is off then.
+func (l *logLevels) enabled(subsystem string) bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.debug || l.subsystems[subsystem]
+}
+
+// get returns a copy of the current log levels.
+func (l *logLevels) get() *LogLevels {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return &LogLevels{
+		Debug:      l.debug,
+		Subsystems: slices.Sorted(maps.Keys(l.subsystems)),
+		Until:      l.until,
+		Revert:     l.revert,
+	}
+}
+
+// set changes the log levels. With a duration, the current levels are restored when it
+// runs out. Without one, the change is permanent, and any timed debug mode is canceled.
+func (l *logLevels) set(levels *LogLevels, duration time.Duration) {
+	l.mu.Lock()
+	defer l.notify()
+	defer l.mu.Unlock()
+
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+
+	l.generation++
+	l.until = time.Time{}
+
+	if duration <= 0 {
+		l.revert = nil
+	} else {
+		if l.revert == nil { // Extending a timed debug mode keeps the original levels to revert to.
+			l.revert = &LogLevels{Debug: l.debug, Subsystems: slices.Sorted(maps.Keys(l.subsystems))}
+		}
+
+		generation := l.generation
+		l.until = time.Now().Add(duration)
+		l.timer = time.AfterFunc(duration, func() { l.expire(generation) })
+	}
+
+	l.apply(levels)
+}
+
+// expire ends a timed debug mode.
+func (l *logLevels) expire(generation uint) {
+	l.mu.Lock()
+	defer l.notify()
+	defer l.mu.Unlock()
+
+	if generation != l.generation || l.revert == nil {
+		return
+	}
+
+	l.apply(l.revert)
+	l.revert, l.timer, l.until = nil, nil, time.Time{}
+}
+
+func (l *logLevels) apply(levels *LogLevels) {
+	l.debug = levels.Debug
+	l.subsystems = make(map[string]bool)
+
+	for _, subsystem := range levels.Subsystems {
+		l.subsystems[subsystem] = true
+	}
+}
+
+func (l *logLevels) notify() {
+	select {
+	case l.changed <- struct{}{}:
+	default:
+	}
+}
+
+// subsystem returns a logger that writes debug lines only when debug logging is on for the subsystem.
+func (l *Logger) subsystem(subsystem string) *subsystemLogger {
+	return &subsystemLogger{Logger: l, subsystem: subsystem}
+}
+
+// Debugf writes Debug log lines for a subsystem.
+func (s *subsystemLogger) Debugf(msg string, v ...any) {
+	if s.levels.enabled(s.subsystem) {
+		s.output(s.Debug, slog.LevelDebug, nil, fmt.Sprintf(msg, v...))
+	}
+}
+
+// debugf writes Debug log lines for a subsystem.
+func (l *Logger) debugf(subsystem, msg string, v ...any) {
+	if l.levels.enabled(subsystem) {
+		l.output(l.Debug, slog.LevelDebug, nil, fmt.Sprintf(msg, v...))
+	}
+}
+
+// setLogLevels changes the runtime log levels, and logs the change.
+func (u *Unpackerr) setLogLevels(levels *LogLevels, duration time.Duration, source string) {
+	u.levels.set(levels, duration)
+
+	if duration > 0 {
+		u.Printf("[%s] Debug Logging: %v, subsystems: %q, for %v", source, levels.Debug, levels.Subsystems, duration)
+	} else {
+		u.Printf("[%s] Debug Logging: %v, subsystems: %q", source, levels.Debug, levels.Subsystems)
+	}
+}
+
+// parseLogLevels returns the log levels and duration from a log level change request.
+// Parameters that are not provided keep their current value.
+func parseLogLevels(req *http.Request, current *LogLevels) (*LogLevels, time.Duration, error) {
+	var (
+		query    = req.URL.Query()
+		levels   = &LogLevels{Debug: current.Debug, Subsystems: current.Subsystems}
+		duration time.Duration
+		err      error
+	)
+
+	if debug := query.Get("debug"); debug != "" {
+		if levels.Debug, err = strconv.ParseBool(debug); err != nil {
+			return nil, 0, fmt.Errorf("%w: debug must be true or false: %w", ErrLogLevel, err)
+		}
+	}
+
+	if query.Has("subsystems") {
+		levels.Subsystems = []string{}
+
+		for subsystem := range strings.SplitSeq(query.Get("subsystems"), ",") {
+			if subsystem = strings.ToLower(strings.TrimSpace(subsystem)); subsystem == "" {
+				continue
+			} else if !slices.Contains(logSubsystems(), subsystem) {
+				return nil, 0, fmt.Errorf("%w: unknown subsystem: %s, use: %s",
+					ErrLogLevel, subsystem, strings.Join(logSubsystems(), ", "))
+			}
+
+			levels.Subsystems = append(levels.Subsystems, subsystem)
+		}
+	}
+
+	if param := query.Get("duration"); param != "" {
+		if duration, err = time.ParseDuration(param); err != nil || duration <= 0 {
+			return nil, 0, fmt.Errorf("%w: duration must be a positive duration like 15m: %s", ErrLogLevel, param)
+		}
+	}
+
+	return levels, duration, nil
+}
+
+// handleLogLevel returns the runtime log levels, and changes them on PUT and POST requests.
+func (u *Unpackerr) handleLogLevel(resp http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	resp.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(resp)
+	encoder.SetIndent("", "  ")
+
+	if req.Method != http.MethodGet {
+		levels, duration, err := parseLogLevels(req, u.levels.get())
+		if err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			_ = encoder.Encode(map[string]string{"error": err.Error()})
+
+			return
+		}
+
+		u.setLogLevels(levels, duration, "API")
+	}
+
+	_ = encoder.Encode(u.levels.get())
+}
@@ -0,0 +1,119 @@
+package unpackerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLevelsSubsystems(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	logger := &Logger{Debug: log.New(&buf, "[DEBUG] ", 0), levels: newLogLevels(false)}
+	logger.levels.set(&LogLevels{Subsystems: []string{logHooks}}, 0)
+
+	logger.Debugf("general")
+	logger.debugf(logPoller, "poller")
+	logger.debugf(logHooks, "hooks")
+	logger.subsystem(logFolders).Debugf("folders")
+	logger.itemLog("Item", nil).in(logHooks).Debugf("item")
+
+	if out := buf.String(); out != "[DEBUG] hooks\n[DEBUG] item\n" {
+		t.Fatalf("expected only the hooks lines, got: %s", out)
+	}
+
+	buf.Reset()
+	logger.levels.set(&LogLevels{Debug: true}, 0)
+	logger.subsystem(logFolders).Debugf("folders")
+
+	if out := buf.String(); out != "[DEBUG] folders\n" {
+		t.Fatalf("expected every subsystem with debug on, got: %s", out)
+	}
+
+	if (&Logger{}).levels.enabled(logHooks) {
+		t.Fatal("nil levels must not enable debug logging")
+	}
+}
+
+func TestLogLevelsTimed(t *testing.T) {
+	t.Parallel()
+
+	levels := newLogLevels(false)
+	levels.set(&LogLevels{Subsystems: []string{logXtractr}}, 0)
+	levels.set(&LogLevels{Debug: true}, time.Hour)
+	levels.set(&LogLevels{Debug: true, Subsystems: []string{logHTTP}}, 50*time.Millisecond) // extends the first.
+
+	if current := levels.get(); !current.Debug || current.Until.IsZero() ||
+		!slices.Equal(current.Revert.Subsystems, []string{logXtractr}) {
+		t.Fatalf("wrong timed debug levels: %+v", current)
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); levels.enabled(""); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed debug mode did not revert")
+		}
+	}
+
+	if current := levels.get(); current.Debug || !current.Until.IsZero() || current.Revert != nil ||
+		!slices.Equal(current.Subsystems, []string{logXtractr}) {
+		t.Fatalf("expected the original levels after reverting, got: %+v", current)
+	}
+
+	// A permanent change cancels a timed debug mode.
+	levels.set(&LogLevels{Debug: true}, 50*time.Millisecond)
+	levels.set(&LogLevels{Debug: true}, 0)
+	time.Sleep(100 * time.Millisecond)
+
+	if !levels.enabled("") {
+		t.Fatal("a canceled timed debug mode must not revert")
+	}
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	t.Parallel()
+
+	unpackerr := &Unpackerr{Config: &Config{}, Logger: &Logger{
+		Info: log.New(&bytes.Buffer{}, "", 0), Debug: log.New(&bytes.Buffer{}, "", 0), levels: newLogLevels(false),
+	}}
+
+	request := func(method, query string) (*LogLevels, int, string) {
+		resp := httptest.NewRecorder()
+		unpackerr.handleLogLevel(resp, httptest.NewRequest(method, "/api/v1/loglevel?"+query, nil), nil)
+
+		var levels LogLevels
+		_ = json.Unmarshal(resp.Body.Bytes(), &levels)
+
+		return &levels, resp.Code, resp.Body.String()
+	}
+
+	if levels, code, _ := request(http.MethodGet, "debug=true"); code != http.StatusOK || levels.Debug {
+		t.Fatalf("GET must not change the log levels: %d %+v", code, levels)
+	}
+
+	levels, code, _ := request(http.MethodPut, "subsystems=Hooks,+poller")
+	if code != http.StatusOK || levels.Debug || !slices.Equal(levels.Subsystems, []string{logHooks, logPoller}) {
+		t.Fatalf("wrong subsystems: %d %+v", code, levels)
+	}
+
+	levels, code, _ = request(http.MethodPost, "debug=true&duration=15m")
+	if code != http.StatusOK || !levels.Debug || levels.Until.IsZero() || len(levels.Subsystems) != 2 {
+		t.Fatalf("wrong timed debug mode: %d %+v", code, levels)
+	}
+
+	for _, query := range []string{"debug=maybe", "subsystems=poller,disks", "duration=-1m", "duration=soon"} {
+		if _, code, body := request(http.MethodPut, query); code != http.StatusBadRequest ||
+			!strings.Contains(body, ErrLogLevel.Error()) {
+			t.Fatalf("expected a bad request for %s, got: %d %s", query, code, body)
+		}
+	}
+
+	unpackerr.levels.set(&LogLevels{}, 0) // stop the timer.
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -21,11 +22,12 @@ import (
 // satisfy gomnd.
 const (
 	callDepth    = 2 // log the line that called us.
-	megabyte     = 1024 * 1024
+	kilobyte     = 1024
+	megabyte     = 1024 * kilobyte
 	logsDirMode  = 0o755
 	starrLogPfx  = " =>    Server: "
 	starrLogLine = "%s, apikey:%v, timeout:%v, verify_ssl:%v, protos:%s, " +
-		"syncthing:%v, delete_orig:%v, delete_delay:%v, paths:%q"
+		"syncthing:%v, delete_orig:%v, delete_delay:%v, paths:%q, min_size:%v, max_size:%v, atomic:%v, extract_path:%s"
 )
 
 // ExtractStatus is our enum for an extract's status.
@@ -43,11 +45,13 @@ const (
 	DELETEFAILED // unused
 	DELETED
 	EXTRACTEDNOTHING
+	EXTRACTSKIPPED
+	REPORT
 )
 
 // Desc makes ExtractStatus human readable.
 func (status ExtractStatus) Desc() string {
-	if status > EXTRACTEDNOTHING {
+	if status > REPORT {
 		return "Unknown"
 	}
 
@@ -63,6 +67,8 @@ func (status ExtractStatus) Desc() string {
 		"Delete Failed",
 		"Deleted",
 		"Nothing Extracted",
+		"Extraction Skipped",
+		"Statistics Report",
 	}[status]
 }
 
@@ -73,7 +79,7 @@ func (status ExtractStatus) MarshalText() ([]byte, error) {
 
 // String turns a status into a short string.
 func (status ExtractStatus) String() string {
-	if status > EXTRACTEDNOTHING {
+	if status > REPORT {
 		return "unknown"
 	}
 
@@ -89,31 +95,26 @@ func (status ExtractStatus) String() string {
 		"deletefailed",
 		"deleted",
 		"extractednothing",
+		"extractskipped",
+		"report",
 	}[status]
 }
 
 // Debugf writes Debug log lines... to stdout and/or a file.
 func (l *Logger) Debugf(msg string, v ...any) {
-	err := l.Debug.Output(callDepth, fmt.Sprintf(msg, v...))
-	if err != nil {
-		fmt.Println("Logger Error:", err) //nolint:forbidigo
+	if l.levels.enabled("") {
+		l.output(l.Debug, slog.LevelDebug, nil, fmt.Sprintf(msg, v...))
 	}
 }
 
 // Printf writes log lines... to stdout and/or a file.
 func (l *Logger) Printf(msg string, v ...any) {
-	err := l.Info.Output(callDepth, fmt.Sprintf(msg, v...))
-	if err != nil {
-		fmt.Println("Logger Error:", err) //nolint:forbidigo
-	}
+	l.output(l.Info, slog.LevelInfo, nil, fmt.Sprintf(msg, v...))
 }
 
 // Errorf writes log errors... to stdout and/or a file.
 func (l *Logger) Errorf(msg string, v ...any) {
-	err := l.Error.Output(callDepth, fmt.Sprintf(msg, v...))
-	if err != nil {
-		fmt.Println("Logger Error:", err) //nolint:forbidigo
-	}
+	l.output(l.Error, slog.LevelError, nil, fmt.Sprintf(msg, v...))
 }
 
 // logCurrentQueue prints the number of things happening.
@@ -125,9 +126,10 @@ func (u *Unpackerr) logCurrentQueue(now time.Time) {
 	u.Printf("[Unpackerr] Totals: %d retries, %d finished, %d|%d webhooks,"+
 		" %d|%d cmdhooks, stacks; event:%d, hook:%d, del:%d, up %s",
 		u.Retries, u.Finished, stats.HookOK, stats.HookFail, stats.CmdOK, stats.CmdFail,
-		len(u.folders.Events)+len(u.updates)+len(u.folders.Updates), len(u.hookChan), len(u.delChan),
+		len(u.folders.Events)+len(u.updates)+len(u.folders.Updates), u.hookQueueDepth(), len(u.delChan),
 		carbon.CreateFromStdTime(version.Started).DiffAbsInString(carbon.CreateFromStdTime(now)))
-	u.updateTray(stats, uint(len(u.folders.Events)+len(u.updates)+len(u.folders.Updates)+len(u.delChan)+len(u.hookChan)))
+	u.updateTray(stats,
+		uint(len(u.folders.Events)+len(u.updates)+len(u.folders.Updates)+len(u.delChan)+u.hookQueueDepth()))
 }
 
 // setupLogging splits log write into a file and/or stdout.
@@ -137,6 +139,7 @@ func (u *Unpackerr) setupLogging() {
 		u.Error.SetFlags(log.Lshortfile | log.Lmicroseconds | log.Ldate)
 	}
 
+	u.Logger.levels = newLogLevels(u.Config.Debug)
 	u.LogFile = getLogFilePath(u.LogFile, "unpackerr.log")
 	fileMode, _ := strconv.ParseUint(u.LogFileMode, bits8, base32)
 	rotate := &rotatorr.Config{
@@ -167,18 +170,34 @@ func (u *Unpackerr) setupLogging() {
 		stderr = os.Stderr
 	}
 
+	quiet := u.Quiet
+	if u.LogTarget != "" {
+		var err error
+
+		// The log target replaces stdout; the log file is still written.
+		if u.Logger.target, err = newLogTarget(u.LogTarget); err != nil {
+			_, _ = os.Stdout.WriteString("[Unpackerr] Log target unavailable, logging to stdout: " + err.Error() + "\n")
+		} else {
+			quiet = true
+		}
+	}
+
 	useLogFile := u.LogFile != "" && u.rotatorr != nil
 
 	switch { // only use MultiWriter if we have > 1 writer.
-	case !u.Quiet && useLogFile:
+	case !quiet && useLogFile:
 		u.updateLogOutput(io.MultiWriter(u.rotatorr, os.Stdout), io.MultiWriter(u.rotatorr, stderr))
-	case !u.Quiet && !useLogFile:
+	case !quiet && !useLogFile:
 		u.updateLogOutput(os.Stdout, stderr)
 	case !useLogFile:
 		u.updateLogOutput(io.Discard, io.Discard) // default is "nothing"
 	default:
 		u.updateLogOutput(u.rotatorr, u.rotatorr)
 	}
+
+	if u.Logger.target != nil {
+		log.SetOutput(io.MultiWriter(log.Writer(), u.Logger.target.writer(slog.LevelError)))
+	}
 }
 
 // getLogFilePath takes in a path and a base name. In case the path is a directory, they are joined.
@@ -195,19 +214,20 @@ func getLogFilePath(logFile, base string) string {
 }
 
 func (u *Unpackerr) updateLogOutput(writer io.Writer, errors io.Writer) {
-	if u.Webserver != nil && u.Webserver.LogFile != "" {
+	switch {
+	case u.Webserver != nil && (u.Webserver.LogFile != "" || u.Webserver.LogTarget != ""):
 		u.setupHTTPLogging()
-	} else {
+	case u.Logger.target != nil:
+		u.HTTP.SetOutput(io.MultiWriter(writer, u.Logger.target.writer(slog.LevelInfo)))
+	default:
 		u.HTTP.SetOutput(writer)
 	}
 
-	if u.Config.Debug {
-		u.Logger.Debug.SetOutput(writer)
-	}
-
-	log.SetOutput(errors) // catch out-of-scope garbage
+	u.Logger.Debug.SetOutput(writer) // Debug lines are filtered by the runtime log levels.
+	log.SetOutput(errors)            // catch out-of-scope garbage
 	u.Info.SetOutput(writer)
 	u.Error.SetOutput(errors)
+	u.Logger.setFormat(u.LogFormat, u.Config.Debug)
 	u.postLogRotate("", "")
 }
 
@@ -220,12 +240,22 @@ func (u *Unpackerr) setupHTTPLogging() {
 		DirMode:  logsDirMode,
 	}
 
+	stdout, quiet := io.Writer(os.Stdout), u.Quiet
+	if u.Webserver.LogTarget != "" {
+		// The HTTP log target replaces stdout, even when quiet; the HTTP log file is still written.
+		if target, err := newLogTarget(u.Webserver.LogTarget); err != nil {
+			_, _ = os.Stdout.WriteString("[Unpackerr] HTTP log target unavailable: " + err.Error() + "\n")
+		} else {
+			stdout, quiet = target.writer(slog.LevelInfo), false
+		}
+	}
+
 	switch { // only use MultiWriter if we have > 1 writer.
-	case !u.Quiet && u.Webserver.LogFile != "":
-		u.HTTP.SetOutput(io.MultiWriter(rotatorr.NewMust(rotate), os.Stdout))
-	case !u.Quiet && u.Webserver.LogFile == "":
-		u.HTTP.SetOutput(os.Stdout)
-	case u.Quiet && u.Webserver.LogFile == "":
+	case !quiet && u.Webserver.LogFile != "":
+		u.HTTP.SetOutput(io.MultiWriter(rotatorr.NewMust(rotate), stdout))
+	case !quiet && u.Webserver.LogFile == "":
+		u.HTTP.SetOutput(stdout)
+	case quiet && u.Webserver.LogFile == "":
 		u.HTTP.SetOutput(io.Discard)
 	default: // u.Config.Quiet && u.Webserver.LogFile != ""
 		u.HTTP.SetOutput(rotatorr.NewMust(rotate))
@@ -259,12 +289,12 @@ func (u *Unpackerr) logStartupInfo(msg string, externalFiles map[string]string)
 	u.logWhisparr()
 	u.logFolders()
 	u.Printf(" => Parallel: %d", u.Parallel)
-	u.Printf(" => Passwords: %d (rar/7z)", len(u.Passwords))
+	u.Printf(" => Passwords: %d (rar/7z), sidecars: %q", u.passwords.Count(), u.PasswordSidecars)
 	u.Printf(" => Interval / Progress: %s/%s", u.Interval.String(), u.Progress.String())
 	u.Printf(" => Start/Delete Delay: %s/%s", u.StartDelay.String(), u.DeleteDelay.String())
 	u.Printf(" => Retry Delay: %v, max: %d", u.RetryDelay, u.MaxRetries)
 	u.Printf(" => GUI / StdErr: %v / %v", ui.HasGUI(), u.ErrorStdErr)
-	u.Printf(" => Debug / Quiet: %v / %v", u.Config.Debug, u.Quiet)
+	u.Printf(" => Debug / Quiet / Format: %v / %v / %s", u.Config.Debug, u.Quiet, u.LogFormat)
 	u.Printf(" => Activity / Queues: %v / %s", u.Activity, u.LogQueues.String())
 
 	if runtime.GOOS != windows {
@@ -280,7 +310,15 @@ func (u *Unpackerr) logStartupInfo(msg string, externalFiles map[string]string)
 		u.Printf(" => Log File: %s (%s, mode: %s)", u.LogFile, msg, u.LogFileMode)
 	}
 
+	if u.Logger.target != nil {
+		u.Printf(" => Log Target: %s", u.Logger.target)
+	}
+
 	u.logWebhook()
 	u.logCmdhook()
+	u.logEmail()
+	u.logMQTT()
+	u.logTracing()
+	u.logReport()
 	u.logWebserver()
 }
@@ -0,0 +1,292 @@
+package unpackerr
+
+/* Native log outputs. Log lines may go to the systemd journal, or to a remote syslog server
+   in RFC5424 format over UDP, TCP or TLS. Both carry the log level; journald also has app and item fields.
+   Syslog has no structured data: an SD-ID needs an IANA private enterprise number, and Unpackerr has none. */
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log target settings.
+const (
+	LogTargetJournald = "journald"
+	journalSocket     = "/run/systemd/journal/socket"
+	journalNetwork    = "unixgram"
+	syslogIdentifier  = "unpackerr"
+	syslogFacility    = 3 << 3 // daemon, shifted for the priority value.
+	syslogTimeFormat  = "2006-01-02T15:04:05.000000Z07:00"
+	syslogPort        = "514"
+	syslogTLSPort     = "6514"
+	logTargetBuffer   = 1000
+	logTargetRetry    = 10 * time.Second
+	logTargetTimeout  = 5 * time.Second
+)
+
+// Syslog severities; journald uses the same priorities.
+const (
+	severityError   = 3
+	severityWarning = 4
+	severityInfo    = 6
+	severityDebug   = 7
+)
+
+// ErrLogTarget is returned when a log target is not journald or a udp, tcp or tls syslog URL.
+var ErrLogTarget = errors.New("invalid log target")
+
+// logTarget sends log lines to journald or a syslog server. Lines are queued and sent
+// from their own go routine, so a slow or missing server never blocks logging.
+type logTarget struct {
+	network string // unixgram, udp, tcp or tls.
+	address string // socket path or host:port.
+	host    string
+	pid     int
+	queue   chan []byte
+	conn    net.Conn
+	failed  time.Time // last failed connection; lines are dropped until it's time to reconnect.
+}
+
+// logTargetWriter writes lines from a standard library logger to a log target.
+type logTargetWriter struct {
+	target *logTarget
+	level  slog.Level
+}
+
+// newLogTarget parses a log target and starts the go routine that sends lines to it.
+// Debug lines are filtered by the runtime log levels before they get here.
+func newLogTarget(target string) (*logTarget, error) {
+	logger := &logTarget{pid: os.Getpid(), queue: make(chan []byte, logTargetBuffer)}
+
+	if logger.host, _ = os.Hostname(); logger.host == "" {
+		logger.host = "-"
+	}
+
+	if target = strings.TrimSpace(target); strings.EqualFold(target, LogTargetJournald) {
+		if runtime.GOOS != "linux" {
+			return nil, fmt.Errorf("%w: journald is only available on linux", ErrLogTarget)
+		}
+
+		logger.network, logger.address = journalNetwork, journalSocket
+	} else if err := logger.parseURL(target); err != nil {
+		return nil, err
+	}
+
+	go logger.run()
+
+	return logger, nil
+}
+
+// parseURL sets the network and address from a syslog URL like udp://host:514.
+func (t *logTarget) parseURL(target string) error {
+	uri, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrLogTarget, err)
+	}
+
+	port := syslogPort
+
+	switch uri.Scheme {
+	case "udp", "tcp":
+	case "tls":
+		port = syslogTLSPort
+	default:
+		return fmt.Errorf("%w: use journald, udp://, tcp:// or tls://: %s", ErrLogTarget, target)
+	}
+
+	if uri.Hostname() == "" {
+		return fmt.Errorf("%w: missing host: %s", ErrLogTarget, target)
+	}
+
+	if uri.Port() != "" {
+		port = uri.Port()
+	}
+
+	t.network, t.address = uri.Scheme, net.JoinHostPort(uri.Hostname(), port)
+
+	return nil
+}
+
+// String returns the log target for the startup log.
+func (t *logTarget) String() string {
+	if t.network == journalNetwork {
+		return LogTargetJournald
+	}
+
+	return t.network + "://" + t.address
+}
+
+// writer returns a writer that sends every line written to it with the provided level.
+func (t *logTarget) writer(level slog.Level) io.Writer {
+	return &logTargetWriter{target: t, level: level}
+}
+
+// Write satisfies io.Writer.
+func (w *logTargetWriter) Write(line []byte) (int, error) {
+	w.target.send(w.level, strings.TrimRight(string(line), "\n"), nil)
+	return len(line), nil
+}
+
+// send queues a log line. Lines are dropped when the queue is full. The target may be nil.
+func (t *logTarget) send(level slog.Level, msg string, attrs []slog.Attr) {
+	if t == nil {
+		return
+	}
+
+	var app, item string
+
+	for _, attr := range attrs {
+		switch attr.Key {
+		case "app":
+			app = attr.Value.String()
+		case "item":
+			item = attr.Value.String()
+		}
+	}
+
+	if app == "" {
+		app = logApp(msg)
+	}
+
+	var data []byte
+	if t.network == journalNetwork {
+		data = journal(level, msg, app, item)
+	} else {
+		data = t.syslog(level, msg, time.Now())
+	}
+
+	select {
+	case t.queue <- data:
+	default:
+	}
+}
+
+// run sends queued lines until the queue is closed.
+func (t *logTarget) run() {
+	for data := range t.queue {
+		if err := t.write(data); err != nil {
+			fmt.Println("Logger Error:", err) //nolint:forbidigo
+		}
+	}
+}
+
+// write sends one line, and reconnects once if the connection was lost.
+func (t *logTarget) write(data []byte) error {
+	var err error
+
+	for range 2 {
+		if t.conn == nil {
+			if time.Since(t.failed) < logTargetRetry {
+				return nil // Drop lines until it's time to try again.
+			}
+
+			if err = t.dial(); err != nil {
+				t.failed = time.Now()
+				return err
+			}
+		}
+
+		_ = t.conn.SetWriteDeadline(time.Now().Add(logTargetTimeout))
+		if _, err = t.conn.Write(data); err == nil {
+			return nil
+		}
+
+		t.conn.Close()
+		t.conn = nil
+	}
+
+	t.failed = time.Now()
+
+	return fmt.Errorf("writing to log target %s: %w", t, err)
+}
+
+func (t *logTarget) dial() error {
+	var (
+		dialer = &net.Dialer{Timeout: logTargetTimeout}
+		err    error
+	)
+
+	if t.network == "tls" {
+		t.conn, err = tls.DialWithDialer(dialer, "tcp", t.address, &tls.Config{MinVersion: tls.VersionTLS12})
+	} else {
+		t.conn, err = dialer.Dial(t.network, t.address)
+	}
+
+	if err != nil {
+		t.conn = nil
+		return fmt.Errorf("connecting to log target %s: %w", t, err)
+	}
+
+	return nil
+}
+
+// severity returns the syslog severity for a log level.
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return severityError
+	case level >= slog.LevelWarn:
+		return severityWarning
+	case level >= slog.LevelInfo:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+// syslog formats an RFC5424 message. Stream messages are framed with octet counting (RFC6587).
+func (t *logTarget) syslog(level slog.Level, msg string, now time.Time) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - - %s", syslogFacility+severity(level),
+		now.Format(syslogTimeFormat), t.host, syslogIdentifier, t.pid, msg)
+
+	if t.network == "udp" {
+		return buf.Bytes()
+	}
+
+	return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+}
+
+// journal formats a message for the journald native protocol.
+func journal(level slog.Level, msg, app, item string) []byte {
+	var buf bytes.Buffer
+
+	journalField(&buf, "MESSAGE", msg)
+	journalField(&buf, "PRIORITY", strconv.Itoa(severity(level)))
+	journalField(&buf, "SYSLOG_IDENTIFIER", syslogIdentifier)
+
+	if app != "" {
+		journalField(&buf, "UNPACKERR_APP", app)
+	}
+
+	if item != "" {
+		journalField(&buf, "UNPACKERR_ITEM", item)
+	}
+
+	return buf.Bytes()
+}
+
+// journalField writes one field. Values with a newline are written with their length, in binary.
+func journalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(key + "=" + value + "\n")
+		return
+	}
+
+	buf.WriteString(key + "\n")
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value + "\n")
+}
@@ -0,0 +1,150 @@
+package unpackerr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLogTarget(t *testing.T) {
+	t.Parallel()
+
+	for target, address := range map[string]string{
+		"udp://syslog":           "udp://syslog:514",
+		"tcp://syslog:1514":      "tcp://syslog:1514",
+		"tls://syslog.example":   "tls://syslog.example:6514",
+		"udp://[2001:db8::1]":    "udp://[2001:db8::1]:514",
+		" TCP://syslog:601 ":     "tcp://syslog:601",
+		"journald":               LogTargetJournald,
+		"https://syslog":         "",
+		"udp://":                 "",
+		"/var/log/unpackerr.log": "",
+	} {
+		logger, err := newLogTarget(target)
+		if address == "" || address == LogTargetJournald && runtime.GOOS != "linux" {
+			if !errors.Is(err, ErrLogTarget) {
+				t.Fatalf("expected an invalid log target error for %q, got: %v", target, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", target, err)
+		}
+
+		if logger.String() != address {
+			t.Fatalf("wrong target for %q: %s", target, logger)
+		}
+	}
+}
+
+func TestLogTargetSyslog(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer listener.Close()
+
+	logger, err := newLogTarget("tcp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	logger.send(slog.LevelError, "[Sonarr] Extraction Failed", []slog.Attr{slog.String("item", `Some "Item" [x]`)})
+	logger.send(slog.LevelInfo, "Started", nil)
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accepting: %v", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	read := func() string {
+		size, err := reader.ReadString(' ')
+		if err != nil {
+			t.Fatalf("reading frame size: %v", err)
+		}
+
+		length, _ := strconv.Atoi(strings.TrimSpace(size))
+		msg := make([]byte, length)
+
+		if _, err := io.ReadFull(reader, msg); err != nil {
+			t.Fatalf("reading frame: %v", err)
+		}
+
+		return string(msg)
+	}
+
+	msg := read()
+	if !strings.HasPrefix(msg, "<27>1 ") {
+		t.Fatalf("wrong priority or version: %s", msg)
+	}
+
+	if !strings.HasSuffix(msg, ` unpackerr `+strconv.Itoa(os.Getpid())+` - - [Sonarr] Extraction Failed`) {
+		t.Fatalf("wrong message: %s", msg)
+	}
+
+	if msg = read(); !strings.HasPrefix(msg, "<30>1 ") || !strings.HasSuffix(msg, " - - Started") {
+		t.Fatalf("wrong message: %s", msg)
+	}
+}
+
+func TestLogTargetJournald(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("journald is only available on linux")
+	}
+
+	dir, err := os.MkdirTemp("", "journal") // t.TempDir() may be too long for a socket path.
+	if err != nil {
+		t.Fatalf("making temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+
+	conn, err := net.ListenUnixgram(journalNetwork, &net.UnixAddr{Name: socket, Net: journalNetwork})
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer conn.Close()
+
+	logger := &logTarget{network: journalNetwork, address: socket, queue: make(chan []byte, 1)}
+	go logger.run()
+	defer close(logger.queue)
+
+	logger.writer(slog.LevelDebug).Write([]byte("[Radarr] Checking\nnext line\n")) //nolint:errcheck
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+
+	size, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+
+	var expect bytes.Buffer
+
+	expect.WriteString("MESSAGE\n\x1b\x00\x00\x00\x00\x00\x00\x00[Radarr] Checking\nnext line\n")
+	expect.WriteString("PRIORITY=7\nSYSLOG_IDENTIFIER=unpackerr\nUNPACKERR_APP=Radarr\n")
+
+	if !bytes.Equal(buf[:size], expect.Bytes()) {
+		t.Fatalf("wrong journal fields: %q", buf[:size])
+	}
+}
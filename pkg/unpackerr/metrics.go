@@ -1,6 +1,13 @@
 package unpackerr
 
 import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -18,11 +25,27 @@ type metrics struct {
 	AppRequests    *prometheus.GaugeVec
 	ArchivesRead   *prometheus.CounterVec
 	BytesWritten   *prometheus.CounterVec
+	BytesDeleted   *prometheus.CounterVec
 	ExtractTime    *prometheus.HistogramVec
+	Failures       *prometheus.CounterVec
 	FilesExtracted *prometheus.CounterVec
+	HookTime       *prometheus.HistogramVec
+	ImportTime     *prometheus.HistogramVec
+	QueueWait      *prometheus.HistogramVec
+	Retries        *prometheus.CounterVec
+	Transitions    *prometheus.CounterVec
 	Uptime         prometheus.CounterFunc
 }
 
+// Failure reasons. The reason label is bounded to these values.
+const (
+	reasonPassword = "password"
+	reasonCorrupt  = "corrupt"
+	reasonNoSpace  = "no-space"
+	reasonTimeout  = "timeout"
+	reasonOther    = "other"
+)
+
 // MetricsCollector is used to plug into a custom Prometheus metrics collector.
 type MetricsCollector struct {
 	*Unpackerr
@@ -56,17 +79,23 @@ func (c *MetricsCollector) Collect(metrics chan<- prometheus.Metric) {
 	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(stats.HookFail), "hook_fail")
 	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(stats.CmdOK), "cmd_ok")
 	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(stats.CmdFail), "cmd_fail")
+	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(stats.HookDrop), "hook_dropped")
+	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(stats.EmailOK), "email_ok")
+	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(stats.EmailFail), "email_fail")
 	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(c.Retries), "retries")
 	metrics <- newMetric(c.counter, prometheus.CounterValue, float64(c.Finished), "finished")
 	metrics <- newMetric(c.buffer, prometheus.GaugeValue, float64(len(c.folders.Events)), "folder_events")
 	metrics <- newMetric(c.buffer, prometheus.GaugeValue, float64(len(c.updates)), "xtractr_updates")
 	metrics <- newMetric(c.buffer, prometheus.GaugeValue, float64(len(c.folders.Updates)), "folder_updates")
 	metrics <- newMetric(c.buffer, prometheus.GaugeValue, float64(len(c.delChan)), "deletes")
-	metrics <- newMetric(c.buffer, prometheus.GaugeValue, float64(len(c.hookChan)), "hooks")
+	metrics <- newMetric(c.buffer, prometheus.GaugeValue, float64(c.hookQueueDepth()), "hooks")
+	metrics <- newMetric(c.buffer, prometheus.GaugeValue, float64(c.outboxDepth()), "hook_outbox")
 }
 
 // updateMetrics observes metrics for each completed extraction. The url for a folder is the watch path.
 func (u *Unpackerr) updateMetrics(resp *xtractr.Response, app starr.App, url string) {
+	u.counters.observe(resp, app)
+
 	if u.metrics == nil {
 		return
 	}
@@ -77,12 +106,124 @@ func (u *Unpackerr) updateMetrics(resp *xtractr.Response, app starr.App, url str
 	u.metrics.FilesExtracted.WithLabelValues(string(app), url).Add(float64(len(resp.NewFiles)))
 }
 
+// metricLabels returns the app and url labels for an item. The url for a folder is the watch path.
+func (u *Unpackerr) metricLabels(name string, item *Extract) (string, string) {
+	if item.App != FolderString {
+		return string(item.App), item.URL
+	}
+
+	if folder := u.folders.Folders[name]; folder != nil {
+		return string(item.App), folder.config.Path
+	}
+
+	return string(item.App), item.URL
+}
+
+// statusMetrics observes an item's status transition. This runs in the main go routine before
+// the item's status and updated time change, so the time since the last update is the time in
+// the previous status. The item's response must be updated first, so a failure has its error.
+func (u *Unpackerr) statusMetrics(name string, item *Extract, from, to ExtractStatus, now time.Time) {
+	if u.metrics == nil || from == to {
+		return
+	}
+
+	app, url := u.metricLabels(name, item)
+	u.metrics.Transitions.WithLabelValues(app, url, from.String(), to.String()).Inc()
+
+	switch {
+	case from == WAITING && to == QUEUED:
+		u.metrics.QueueWait.WithLabelValues(app, url).Observe(now.Sub(item.Updated).Seconds())
+	case from == EXTRACTED && to == IMPORTED:
+		u.metrics.ImportTime.WithLabelValues(app, url).Observe(now.Sub(item.Updated).Seconds())
+	case to == EXTRACTFAILED && item.Resp != nil:
+		u.metrics.Failures.WithLabelValues(app, url, failureReason(item.Resp.Error)).Inc()
+	}
+}
+
+// failureReason sorts an extraction error into a small set of reasons for the failures metric.
+func failureReason(err error) string {
+	if err == nil {
+		return reasonOther
+	}
+
+	msg := strings.ToLower(err.Error())
+
+	switch {
+	case errors.Is(err, syscall.ENOSPC) || strings.Contains(msg, "no space left"):
+		return reasonNoSpace
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out"):
+		return reasonTimeout
+	case strings.Contains(msg, "password") || strings.Contains(msg, "encrypted"):
+		return reasonPassword
+	case strings.Contains(msg, "corrupt") || strings.Contains(msg, "checksum") || strings.Contains(msg, "crc") ||
+		strings.Contains(msg, "unexpected eof") || strings.Contains(msg, "invalid header"):
+		return reasonCorrupt
+	default:
+		return reasonOther
+	}
+}
+
+// retryMetrics counts an extraction retry.
+func (u *Unpackerr) retryMetrics(app starr.App, url string) {
+	if u.metrics != nil {
+		u.metrics.Retries.WithLabelValues(string(app), url).Inc()
+	}
+}
+
+// hookMetrics observes the duration of a webhook request or a command hook run.
+func (u *Unpackerr) hookMetrics(kind, name string, start time.Time) {
+	if u.metrics != nil {
+		u.metrics.HookTime.WithLabelValues(kind, name).Observe(time.Since(start).Seconds())
+	}
+}
+
+// deleteFiles deletes the files in a delete request, and counts the bytes reclaimed when metrics are enabled.
+// This runs in the delete go routine.
+func (u *Unpackerr) deleteFiles(input *fileDeleteReq) {
+	if u.metrics == nil || input.App == "" {
+		u.DeleteFiles(input.Paths...)
+		return
+	}
+
+	size := pathSize(input.Paths...)
+	u.DeleteFiles(input.Paths...)
+
+	if size -= pathSize(input.Paths...); size > 0 {
+		u.metrics.BytesDeleted.WithLabelValues(string(input.App), input.URL).Add(float64(size))
+	}
+}
+
+// pathSize returns the total size of the files in the paths, and in any folders in the paths.
+func pathSize(paths ...string) int64 {
+	var size int64
+
+	for _, path := range paths {
+		_ = filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return nil //nolint:nilerr // Missing files are not counted.
+			}
+
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+
+			return nil
+		})
+	}
+
+	return size
+}
+
 // saveQueueMetrics observes metrics for each starr app queue request.
 func (u *Unpackerr) saveQueueMetrics(size int, start time.Time, app starr.App, url string, err error) {
 	if err != nil {
 		u.Errorf("%s (%s): %v", app, url, err)
 	}
 
+	u.tracer.saveFetch(app, url, start, err)
+	u.starrs.saveFetch(app, url, size, err)
+
 	if u.metrics == nil {
 		return
 	}
@@ -135,10 +276,41 @@ func (u *Unpackerr) setupMetrics() {
 			Help:    "The duration of extractions",
 			Buckets: []float64{10, 60, 300, 1800, 3600, 7200, 14400},
 		}, []string{"app", "url"}),
+		BytesDeleted: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "unpackerr_bytes_deleted_total",
+			Help: "The total number of bytes reclaimed by deleting files",
+		}, []string{"app", "url"}),
+		Failures: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "unpackerr_extract_failures_total",
+			Help: "The total number of failed extractions by reason: password, corrupt, no-space, timeout or other",
+		}, []string{"app", "url", "reason"}),
 		FilesExtracted: promauto.NewCounterVec(prometheus.CounterOpts{
 			Name: "unpackerr_files_extracted_total",
 			Help: "The total number files written to disk",
 		}, []string{"app", "url"}),
+		HookTime: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "unpackerr_hook_time_seconds",
+			Help:    "The duration of webhook requests and command hook runs",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"type", "name"}),
+		ImportTime: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "unpackerr_import_time_seconds",
+			Help:    "The duration between an extraction finishing and the Starr app importing it",
+			Buckets: []float64{10, 30, 60, 120, 300, 600, 1800, 3600},
+		}, []string{"app", "url"}),
+		QueueWait: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "unpackerr_queue_wait_time_seconds",
+			Help:    "The duration items wait before they are queued for extraction",
+			Buckets: []float64{10, 30, 60, 120, 300, 600, 1800, 3600},
+		}, []string{"app", "url"}),
+		Retries: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "unpackerr_retries_total",
+			Help: "The total number of extraction retries",
+		}, []string{"app", "url"}),
+		Transitions: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "unpackerr_status_transitions_total",
+			Help: "The total number of item status changes",
+		}, []string{"app", "url", "from", "to"}),
 		Uptime: promauto.NewCounterFunc(prometheus.CounterOpts{
 			Name: "unpackerr_uptime_seconds_total",
 			Help: "Duration Unpackerr has been running in seconds",
@@ -159,6 +331,9 @@ type Stats struct {
 	HookFail   uint
 	CmdOK      uint
 	CmdFail    uint
+	HookDrop   uint
+	EmailOK    uint
+	EmailFail  uint
 }
 
 // stats compiles and builds the statistics for the app.
@@ -166,6 +341,8 @@ func (u *Unpackerr) stats() *Stats {
 	stats := &Stats{}
 	stats.HookOK, stats.HookFail = u.WebhookCounts()
 	stats.CmdOK, stats.CmdFail = u.CmdhookCounts()
+	stats.HookDrop = u.HookDrops()
+	stats.EmailOK, stats.EmailFail = u.EmailCounts()
 
 	for name := range u.Map {
 		switch u.Map[name].Status {
@@ -0,0 +1,136 @@
+package unpackerr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golift.io/starr"
+	"golift.io/xtractr"
+)
+
+func TestFailureReason(t *testing.T) {
+	t.Parallel()
+
+	for err, reason := range map[error]string{
+		fmt.Errorf("writing: %w", syscall.ENOSPC):    reasonNoSpace,
+		errors.New("7z: wrong password"):             reasonPassword,
+		errors.New("rardecode: archive encrypted"):   reasonPassword,
+		errors.New("zip: checksum error"):            reasonCorrupt,
+		errors.New("reading: unexpected EOF"):        reasonCorrupt,
+		errors.New("i/o timeout"):                    reasonTimeout,
+		errors.New("post_extract gate scan: exit 1"): reasonOther,
+	} {
+		if got := failureReason(err); got != reason {
+			t.Fatalf("expected %v to be %s, got: %s", err, reason, got)
+		}
+	}
+
+	if got := failureReason(nil); got != reasonOther {
+		t.Fatalf("expected no error to be %s, got: %s", reasonOther, got)
+	}
+}
+
+// testMetrics returns metrics registered in a local registry, so tests do not touch the default registry.
+func testMetrics(t *testing.T) (*metrics, *prometheus.Registry) {
+	t.Helper()
+
+	labels := []string{"app", "url"}
+	testMetrics := &metrics{
+		BytesDeleted: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "deleted"}, labels),
+		Failures:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "failures"}, append(labels, "reason")),
+		ImportTime:   prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "import"}, labels),
+		QueueWait:    prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "wait"}, labels),
+		Transitions:  prometheus.NewCounterVec(prometheus.CounterOpts{Name: "transitions"}, append(labels, "from", "to")),
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(testMetrics.BytesDeleted, testMetrics.Failures,
+		testMetrics.ImportTime, testMetrics.QueueWait, testMetrics.Transitions)
+
+	return testMetrics, registry
+}
+
+// gathered returns the sum of a metric's counter values, or histogram sample sums, for every label.
+func gathered(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gathering metrics: %v", err)
+	}
+
+	var total float64
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			total += metric.GetCounter().GetValue() + metric.GetHistogram().GetSampleSum()
+		}
+	}
+
+	return total
+}
+
+func TestStatusMetrics(t *testing.T) {
+	t.Parallel()
+
+	testMetrics, registry := testMetrics(t)
+	unpackerr := &Unpackerr{metrics: testMetrics}
+	now := time.Now()
+	item := &Extract{App: starr.Radarr, URL: "http://radarr", Updated: now.Add(-time.Minute)}
+
+	unpackerr.statusMetrics("Movie", item, WAITING, QUEUED, now)
+	unpackerr.statusMetrics("Movie", item, EXTRACTED, IMPORTED, now)
+	unpackerr.statusMetrics("Movie", item, QUEUED, QUEUED, now)
+
+	item.Resp = &xtractr.Response{Error: errors.New("wrong password")}
+	unpackerr.statusMetrics("Movie", item, EXTRACTING, EXTRACTFAILED, now)
+
+	if count := gathered(t, registry, "transitions"); count != 3 {
+		t.Fatalf("expected 3 transitions, got: %v", count)
+	}
+
+	if wait := gathered(t, registry, "wait"); wait != time.Minute.Seconds() {
+		t.Fatalf("expected a minute of queue wait, got: %v", wait)
+	}
+
+	if imported := gathered(t, registry, "import"); imported != time.Minute.Seconds() {
+		t.Fatalf("expected a minute of import time, got: %v", imported)
+	}
+
+	if failures := gathered(t, registry, "failures"); failures != 1 {
+		t.Fatalf("expected one failure, got: %v", failures)
+	}
+}
+
+func TestDeleteFilesMetrics(t *testing.T) {
+	t.Parallel()
+
+	testMetrics, registry := testMetrics(t)
+	discard := log.New(io.Discard, "", 0)
+	logger := &Logger{Info: discard, Error: discard, Debug: discard}
+	unpackerr := &Unpackerr{metrics: testMetrics, Xtractr: xtractr.NewQueue(&xtractr.Config{Logger: logger})}
+	dir := t.TempDir()
+
+	defer unpackerr.Stop()
+
+	if err := os.WriteFile(filepath.Join(dir, "movie.mkv"), make([]byte, 1234), 0o600); err != nil {
+		t.Fatalf("writing test file: %v", err)
+	}
+
+	unpackerr.deleteFiles(&fileDeleteReq{Paths: []string{dir}, App: starr.Radarr, URL: "http://radarr"})
+
+	if deleted := gathered(t, registry, "deleted"); deleted != 1234 {
+		t.Fatalf("expected 1234 bytes deleted, got: %v", deleted)
+	}
+}
@@ -0,0 +1,617 @@
+package unpackerr
+
+/* MQTT publisher for extraction events and stats, with Home Assistant discovery.
+   This is a small MQTT 3.1.1 client that only publishes with QoS 0, so it needs no MQTT library.
+   A go routine reads from the broker to notice a lost connection, and the worker reconnects. */
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golift.io/cnfg"
+	"golift.io/starr"
+	"golift.io/version"
+)
+
+// MQTT defaults.
+const (
+	defaultMQTTPrefix    = "unpackerr"
+	defaultMQTTDiscovery = "homeassistant"
+	defaultMQTTInterval  = time.Minute
+	mqttKeepAlive        = time.Minute
+	mqttRetryDelay       = 10 * time.Second // Wait this long between connection attempts.
+	mqttPlainPort        = "1883"
+	mqttTLSPort          = "8883"
+)
+
+// MQTT 3.1.1 packet types and flags.
+const (
+	mqttConnect     = 0x10
+	mqttConnack     = 0x20
+	mqttPublish     = 0x30
+	mqttPingreq     = 0xC0
+	mqttDisconnect  = 0xE0
+	mqttRetainFlag  = 0x01
+	mqttProtocol    = 4
+	mqttCleanFlag   = 0x02
+	mqttWillFlag    = 0x04
+	mqttWillRetain  = 0x20
+	mqttPassFlag    = 0x40
+	mqttUserFlag    = 0x80
+	mqttMaxLength   = 268435455 // Largest remaining length a packet may have.
+	mqttLengthBytes = 4         // Most bytes the remaining length may use.
+)
+
+// Errors produced by this file.
+var (
+	ErrMQTTScheme  = errors.New("invalid mqtt broker scheme, must be one of: tcp, mqtt, ssl, tls, mqtts")
+	ErrMQTTRefused = errors.New("mqtt broker refused the connection")
+	ErrMQTTPacket  = errors.New("invalid mqtt packet")
+)
+
+// MQTTConfig defines the settings to publish events and stats to an MQTT broker.
+type MQTTConfig struct {
+	Broker        string          `json:"broker"          toml:"broker"           xml:"broker"           yaml:"broker"`
+	ClientID      string          `json:"clientId"        toml:"client_id"        xml:"client_id"        yaml:"clientId"`
+	Username      string          `json:"username"        toml:"username"         xml:"username"         yaml:"username"`
+	Password      string          `json:"password"        toml:"password"         xml:"password"         yaml:"password"`
+	Prefix        string          `json:"topicPrefix"     toml:"topic_prefix"     xml:"topic_prefix"     yaml:"topicPrefix"`
+	HomeAssistant bool            `json:"homeAssistant"   toml:"home_assistant"   xml:"home_assistant"   yaml:"homeAssistant"`
+	Discovery     string          `json:"discoveryPrefix" toml:"discovery_prefix" xml:"discovery_prefix" yaml:"discoveryPrefix"`
+	Interval      cnfg.Duration   `json:"interval"        toml:"interval"         xml:"interval"         yaml:"interval"`
+	Timeout       cnfg.Duration   `json:"timeout"         toml:"timeout"          xml:"timeout"          yaml:"timeout"`
+	IgnoreSSL     bool            `json:"ignoreSsl"       toml:"ignore_ssl"       xml:"ignore_ssl"       yaml:"ignoreSsl"`
+	Events        ExtractStatuses `json:"events"          toml:"events"           xml:"events"           yaml:"events"`
+	Exclude       StringSlice     `json:"exclude"         toml:"exclude"          xml:"exclude"          yaml:"exclude"`
+	queue         chan *mqttMessage
+	pending       []*mqttMessage // Messages waiting for the broker, oldest first.
+	conn          net.Conn
+	lost          chan error // The reader go routine reports a lost connection here.
+	lastDial      time.Time
+}
+
+// mqttMessage is one message waiting to be published.
+type mqttMessage struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// mqttSensor is a stats value published to MQTT, and a Home Assistant sensor.
+type mqttSensor struct {
+	ID    string
+	Name  string
+	Icon  string
+	Total bool // Counters only go up, gauges go up and down.
+	Value uint
+}
+
+// Enabled returns true if a broker is configured.
+func (m *MQTTConfig) Enabled() bool {
+	return m != nil && m.Broker != ""
+}
+
+func (u *Unpackerr) validateMQTT() error {
+	if !u.MQTT.Enabled() {
+		return nil
+	}
+
+	if _, _, err := u.MQTT.address(); err != nil {
+		return err
+	}
+
+	if u.MQTT.ClientID == "" {
+		hostname, _ := os.Hostname()
+		u.MQTT.ClientID = strings.Trim(defaultMQTTPrefix+"-"+strings.ToLower(hostname), "-")
+	}
+
+	u.MQTT.Prefix = strings.Trim(u.MQTT.Prefix, "/")
+	if u.MQTT.Prefix == "" {
+		u.MQTT.Prefix = defaultMQTTPrefix
+	}
+
+	if u.MQTT.Discovery = strings.Trim(u.MQTT.Discovery, "/"); u.MQTT.Discovery == "" {
+		u.MQTT.Discovery = defaultMQTTDiscovery
+	}
+
+	if u.MQTT.Interval.Duration <= 0 {
+		u.MQTT.Interval.Duration = defaultMQTTInterval
+	}
+
+	if u.MQTT.Timeout.Duration == 0 {
+		u.MQTT.Timeout.Duration = u.Timeout.Duration
+	}
+
+	if len(u.MQTT.Events) == 0 {
+		u.MQTT.Events = []ExtractStatus{WAITING}
+	}
+
+	if u.MQTT.queue == nil {
+		u.MQTT.queue = make(chan *mqttMessage, defaultHookQueue)
+	}
+
+	return nil
+}
+
+// address returns the broker host:port, and true if the connection uses TLS.
+func (m *MQTTConfig) address() (string, bool, error) {
+	broker := m.Broker
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+
+	uri, err := url.Parse(broker)
+	if err != nil {
+		return "", false, fmt.Errorf("mqtt broker: %w", err)
+	}
+
+	var useTLS bool
+
+	port := mqttPlainPort
+
+	switch strings.ToLower(uri.Scheme) {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS, port = true, mqttTLSPort
+	default:
+		return "", false, fmt.Errorf("%w: %s", ErrMQTTScheme, uri.Scheme)
+	}
+
+	if uri.Port() != "" {
+		port = uri.Port()
+	}
+
+	return net.JoinHostPort(uri.Hostname(), port), useTLS, nil
+}
+
+func (u *Unpackerr) logMQTT() {
+	if !u.MQTT.Enabled() {
+		u.Printf(" => MQTT Disabled")
+		return
+	}
+
+	discovery := "off"
+	if u.MQTT.HomeAssistant {
+		discovery = u.MQTT.Discovery
+	}
+
+	u.Printf(" => MQTT Config: %s, client id: %s, topic prefix: %s, discovery: %s, interval: %v, events: %q",
+		u.MQTT.Broker, u.MQTT.ClientID, u.MQTT.Prefix, discovery, u.MQTT.Interval, logEvents(u.MQTT.Events))
+}
+
+// Excluded returns true if an app is in the Exclude slice.
+func (m *MQTTConfig) Excluded(app starr.App) bool {
+	return m.Exclude.HasApp(app)
+}
+
+// HasEvent returns true if a status event is in the Events slice.
+func (m *MQTTConfig) HasEvent(e ExtractStatus) bool {
+	return m.Events.Has(e)
+}
+
+// queueMQTT gives messages to the MQTT worker without blocking; they're dropped if the queue is full.
+func (u *Unpackerr) queueMQTT(messages ...*mqttMessage) {
+	if !u.MQTT.Enabled() || u.MQTT.queue == nil {
+		return
+	}
+
+	for _, msg := range messages {
+		select {
+		case u.MQTT.queue <- msg:
+		default:
+			u.Errorf("[MQTT] Queue full (%d), dropped message: %s", cap(u.MQTT.queue), msg.Topic)
+		}
+	}
+}
+
+// mqttEvent publishes a status transition to <prefix>/<app>/<event>.
+func (u *Unpackerr) mqttEvent(payload *WebhookPayload) {
+	if !u.MQTT.Enabled() || !u.MQTT.HasEvent(payload.Event) || u.MQTT.Excluded(payload.App) {
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		u.Errorf("[MQTT] Encoding payload (%s = %s): %v", payload.Path, payload.Event, err)
+		return
+	}
+
+	u.queueMQTT(&mqttMessage{
+		Topic:   u.MQTT.Prefix + "/" + strings.ToLower(string(payload.App)) + "/" + payload.Event.String(),
+		Payload: data,
+	})
+}
+
+// mqttSensors returns the stats that are published as retained gauges.
+func (u *Unpackerr) mqttSensors(stats *Stats) []*mqttSensor {
+	return []*mqttSensor{
+		{ID: "waiting", Name: "Waiting", Icon: "mdi:timer-sand", Value: stats.Waiting},
+		{ID: "queued", Name: "Queued", Icon: "mdi:tray-full", Value: stats.Queued},
+		{ID: "extracting", Name: "Extracting", Icon: "mdi:package-variant", Value: stats.Extracting},
+		{ID: "extracted", Name: "Extracted", Icon: "mdi:package-variant-closed-check", Value: stats.Extracted},
+		{ID: "imported", Name: "Imported", Icon: "mdi:import", Value: stats.Imported},
+		{ID: "failed", Name: "Failed", Icon: "mdi:alert-circle", Value: stats.Failed},
+		{ID: "deleted", Name: "Deleted", Icon: "mdi:delete", Value: stats.Deleted},
+		{ID: "finished", Name: "Finished", Icon: "mdi:check-all", Total: true, Value: u.Finished},
+		{ID: "retries", Name: "Retries", Icon: "mdi:restart", Total: true, Value: u.Retries},
+		{ID: "hook_ok", Name: "Webhooks Sent", Icon: "mdi:webhook", Total: true, Value: stats.HookOK},
+		{ID: "hook_fail", Name: "Webhooks Failed", Icon: "mdi:webhook", Total: true, Value: stats.HookFail},
+		{ID: "cmd_ok", Name: "Commands Run", Icon: "mdi:console", Total: true, Value: stats.CmdOK},
+		{ID: "cmd_fail", Name: "Commands Failed", Icon: "mdi:console", Total: true, Value: stats.CmdFail},
+	}
+}
+
+// mqttStats publishes the stats counters as retained values under <prefix>/stats/.
+// This runs in the main go routine, because stats() reads the history map.
+func (u *Unpackerr) mqttStats() {
+	sensors := u.mqttSensors(u.stats())
+	messages := make([]*mqttMessage, len(sensors))
+
+	for idx, sensor := range sensors {
+		messages[idx] = &mqttMessage{
+			Topic:   u.MQTT.Prefix + "/stats/" + sensor.ID,
+			Payload: []byte(strconv.FormatUint(uint64(sensor.Value), 10)),
+			Retain:  true,
+		}
+	}
+
+	u.queueMQTT(messages...)
+}
+
+// statusTopic is where the online/offline availability is published.
+func (m *MQTTConfig) statusTopic() string {
+	return m.Prefix + "/status"
+}
+
+// discovery returns the Home Assistant discovery configs for every stats sensor.
+func (m *MQTTConfig) discovery(sensors []*mqttSensor) []*mqttMessage {
+	nodeID := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, strings.ToLower(m.ClientID))
+	device := map[string]any{
+		"identifiers":  []string{nodeID},
+		"name":         "Unpackerr",
+		"manufacturer": "Unpackerr",
+		"model":        "Unpackerr",
+		"sw_version":   version.Version,
+	}
+	messages := make([]*mqttMessage, 0, len(sensors))
+
+	for _, sensor := range sensors {
+		stateClass := "measurement"
+		if sensor.Total {
+			stateClass = "total_increasing"
+		}
+
+		data, _ := json.Marshal(map[string]any{
+			"name":               sensor.Name,
+			"unique_id":          nodeID + "_" + sensor.ID,
+			"object_id":          nodeID + "_" + sensor.ID,
+			"state_topic":        m.Prefix + "/stats/" + sensor.ID,
+			"availability_topic": m.statusTopic(),
+			"state_class":        stateClass,
+			"icon":               sensor.Icon,
+			"device":             device,
+		})
+		messages = append(messages, &mqttMessage{
+			Topic:   m.Discovery + "/sensor/" + nodeID + "/" + sensor.ID + "/config",
+			Payload: data,
+			Retain:  true,
+		})
+	}
+
+	return messages
+}
+
+// mqttWorker owns the broker connection and publishes queued messages in order.
+// A lost connection is noticed by the reader go routine, and the worker reconnects.
+func (u *Unpackerr) mqttWorker() {
+	ping := time.NewTicker(mqttKeepAlive / 2) //nolint:mnd
+	defer ping.Stop()
+
+	retry := time.NewTicker(mqttRetryDelay)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-ping.C:
+			if u.MQTT.conn != nil {
+				if err := u.MQTT.write([]byte{mqttPingreq, 0}); err != nil {
+					u.Errorf("[MQTT] Ping failed: %v", err)
+					u.MQTT.close()
+				}
+			}
+		case err := <-u.MQTT.lost:
+			u.Errorf("[MQTT] Lost connection to %s: %v", u.MQTT.Broker, err)
+			u.MQTT.close()
+			u.mqttFlush()
+		case <-retry.C:
+			if u.MQTT.conn == nil {
+				u.mqttFlush()
+			}
+		case msg, ok := <-u.MQTT.queue:
+			if !ok {
+				u.MQTT.disconnect()
+				return
+			}
+
+			u.mqttPublish(msg)
+		}
+	}
+}
+
+// mqttPublish puts a message behind any that are waiting for the broker, and publishes them.
+// While the broker is unreachable, messages wait; the oldest are dropped when too many wait.
+func (u *Unpackerr) mqttPublish(msg *mqttMessage) {
+	if len(u.MQTT.pending) >= defaultHookQueue {
+		u.Errorf("[MQTT] Not connected, %d messages waiting, dropped oldest message: %s",
+			len(u.MQTT.pending), u.MQTT.pending[0].Topic)
+		u.MQTT.pending = u.MQTT.pending[1:]
+	}
+
+	u.MQTT.pending = append(u.MQTT.pending, msg)
+	u.mqttFlush()
+}
+
+// mqttFlush connects to the broker if needed, and publishes the waiting messages in order.
+func (u *Unpackerr) mqttFlush() {
+	if u.MQTT.conn == nil {
+		if time.Since(u.MQTT.lastDial) < mqttRetryDelay {
+			u.debugf(logHooks, "[MQTT] Not connected, %d messages waiting", len(u.MQTT.pending))
+			return
+		}
+
+		if err := u.mqttConnect(); err != nil {
+			u.Errorf("[MQTT] Connecting to %s: %v (%d messages waiting)", u.MQTT.Broker, err, len(u.MQTT.pending))
+			return
+		}
+	}
+
+	for len(u.MQTT.pending) > 0 {
+		if err := u.MQTT.publish(u.MQTT.pending[0]); err != nil {
+			u.Errorf("[MQTT] Publishing %s: %v", u.MQTT.pending[0].Topic, err)
+			u.MQTT.close()
+
+			return
+		}
+
+		u.MQTT.pending = u.MQTT.pending[1:]
+	}
+}
+
+// mqttConnect connects to the broker, marks Unpackerr online and sends the discovery configs.
+func (u *Unpackerr) mqttConnect() error {
+	u.MQTT.lastDial = time.Now()
+
+	if err := u.MQTT.connect(); err != nil {
+		return err
+	}
+
+	u.Printf("[MQTT] Connected to %s as %s", u.MQTT.Broker, u.MQTT.ClientID)
+
+	messages := []*mqttMessage{{Topic: u.MQTT.statusTopic(), Payload: []byte("online"), Retain: true}}
+	if u.MQTT.HomeAssistant {
+		messages = append(messages, u.MQTT.discovery(u.mqttSensors(&Stats{}))...)
+	}
+
+	for _, msg := range messages {
+		if err := u.MQTT.publish(msg); err != nil {
+			u.MQTT.close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connect dials the broker and sends a CONNECT packet with an offline last will.
+func (m *MQTTConfig) connect() error {
+	addr, useTLS, err := m.address()
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: m.Timeout.Duration}
+
+	var conn net.Conn
+
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: m.IgnoreSSL, //nolint:gosec
+		})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("dialing: %w", err)
+	}
+
+	m.conn = conn
+
+	if err := m.write(m.connectPacket()); err != nil {
+		m.close()
+		return err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(m.Timeout.Duration))
+	reader := bufio.NewReader(conn)
+
+	kind, body, err := readMQTTPacket(reader)
+	if err != nil {
+		m.close()
+		return err
+	}
+
+	if kind&0xF0 != mqttConnack || len(body) != 2 { //nolint:mnd
+		m.close()
+		return fmt.Errorf("%w: expected connack, got type 0x%x", ErrMQTTPacket, kind)
+	}
+
+	if body[1] != 0 {
+		m.close()
+		return fmt.Errorf("%w: return code %d", ErrMQTTRefused, body[1])
+	}
+
+	m.lost = make(chan error, 1)
+	go readMQTT(conn, reader, m.lost)
+
+	return nil
+}
+
+// readMQTT reads packets from the broker until the connection fails, and sends the error to lost.
+// Nothing the broker sends is needed, but the broker answers every ping, so a read that
+// times out means the connection is dead. This runs in its own go routine for each connection.
+func readMQTT(conn net.Conn, reader *bufio.Reader, lost chan<- error) {
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(mqttKeepAlive))
+
+		if _, _, err := readMQTTPacket(reader); err != nil {
+			lost <- err
+			return
+		}
+	}
+}
+
+func (m *MQTTConfig) connectPacket() []byte {
+	flags := byte(mqttCleanFlag | mqttWillFlag | mqttWillRetain)
+	if m.Username != "" {
+		flags |= mqttUserFlag
+	}
+
+	if m.Password != "" {
+		flags |= mqttPassFlag
+	}
+
+	body := mqttString(nil, "MQTT")
+	body = append(body, mqttProtocol, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive.Seconds()))
+	body = mqttString(body, m.ClientID)
+	body = mqttString(body, m.statusTopic())
+	body = mqttString(body, "offline")
+
+	if m.Username != "" {
+		body = mqttString(body, m.Username)
+	}
+
+	if m.Password != "" {
+		body = mqttString(body, m.Password)
+	}
+
+	return mqttPacket(mqttConnect, body)
+}
+
+// publish sends a PUBLISH packet with QoS 0.
+func (m *MQTTConfig) publish(msg *mqttMessage) error {
+	kind := byte(mqttPublish)
+	if msg.Retain {
+		kind |= mqttRetainFlag
+	}
+
+	return m.write(mqttPacket(kind, append(mqttString(nil, msg.Topic), msg.Payload...)))
+}
+
+func (m *MQTTConfig) write(packet []byte) error {
+	_ = m.conn.SetWriteDeadline(time.Now().Add(m.Timeout.Duration))
+
+	if _, err := m.conn.Write(packet); err != nil {
+		return fmt.Errorf("writing to mqtt broker: %w", err)
+	}
+
+	return nil
+}
+
+// disconnect tells the broker we're leaving on purpose, so it does not publish the last will.
+func (m *MQTTConfig) disconnect() {
+	if m.conn == nil {
+		return
+	}
+
+	_ = m.publish(&mqttMessage{Topic: m.statusTopic(), Payload: []byte("offline"), Retain: true})
+	_ = m.write([]byte{mqttDisconnect, 0})
+	m.close()
+}
+
+// close closes the connection. The reader go routine for it stops, and its error is ignored.
+func (m *MQTTConfig) close() {
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn, m.lost = nil, nil
+	}
+}
+
+// mqttPacket adds the fixed header to a packet body.
+func mqttPacket(kind byte, body []byte) []byte {
+	packet := []byte{kind}
+
+	for length := len(body); ; {
+		digit := byte(length % 128) //nolint:mnd
+		if length /= 128; length > 0 {
+			digit |= 0x80
+		}
+
+		if packet = append(packet, digit); length == 0 {
+			break
+		}
+	}
+
+	return append(packet, body...)
+}
+
+// mqttString appends a length-prefixed UTF-8 string.
+func mqttString(buf []byte, str string) []byte {
+	return append(binary.BigEndian.AppendUint16(buf, uint16(len(str))), str...) //nolint:gosec
+}
+
+// readMQTTPacket reads one packet and returns its type byte and body.
+func readMQTTPacket(reader *bufio.Reader) (byte, []byte, error) {
+	kind, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading from mqtt broker: %w", err)
+	}
+
+	var length, multiplier int = 0, 1
+
+	// The remaining length is at most 4 bytes; a 5th is a malformed packet.
+	for digits := 1; ; digits++ {
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, fmt.Errorf("reading from mqtt broker: %w", err)
+		}
+
+		length += int(digit&0x7F) * multiplier //nolint:mnd
+		if multiplier *= 128; digit&0x80 == 0 {
+			break
+		} else if digits == mqttLengthBytes {
+			return 0, nil, fmt.Errorf("%w: remaining length longer than %d bytes", ErrMQTTPacket, mqttLengthBytes)
+		}
+	}
+
+	if length < 0 || length > mqttMaxLength {
+		return 0, nil, fmt.Errorf("%w: length out of range: %d", ErrMQTTPacket, length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, fmt.Errorf("reading from mqtt broker: %w", err)
+	}
+
+	return kind, body, nil
+}
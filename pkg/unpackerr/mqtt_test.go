@@ -0,0 +1,236 @@
+package unpackerr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golift.io/cnfg"
+	"golift.io/starr"
+)
+
+// fakeBroker accepts one MQTT connection, checks the CONNECT packet, and returns every PUBLISH it receives.
+func fakeBroker(t *testing.T) (string, <-chan *mqttMessage) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan *mqttMessage, 100) //nolint:mnd
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		kind, body, err := readMQTTPacket(reader)
+		if err != nil || kind != mqttConnect || !strings.Contains(string(body), "unpackerr/status") {
+			t.Errorf("expected a connect packet with a last will, got: 0x%x %q %v", kind, body, err)
+			return
+		}
+
+		_, _ = conn.Write([]byte{mqttConnack, 2, 0, 0})
+
+		for {
+			kind, body, err := readMQTTPacket(reader)
+			if err != nil {
+				close(messages)
+				return
+			}
+
+			if kind&0xF0 != mqttPublish {
+				continue
+			}
+
+			length := int(binary.BigEndian.Uint16(body))
+			messages <- &mqttMessage{
+				Topic:   string(body[2 : 2+length]),
+				Payload: body[2+length:],
+				Retain:  kind&mqttRetainFlag != 0,
+			}
+		}
+	}()
+
+	return listener.Addr().String(), messages
+}
+
+func TestMQTTPacket(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{0, 127, 128, 16383, 16384, 70000} {
+		packet := mqttPacket(mqttPublish, make([]byte, size))
+
+		kind, body, err := readMQTTPacket(bufio.NewReader(strings.NewReader(string(packet))))
+		if err != nil || kind != mqttPublish || len(body) != size {
+			t.Fatalf("size %d: expected the packet to decode, got: 0x%x, %d bytes, %v", size, kind, len(body), err)
+		}
+	}
+
+	if _, _, err := readMQTTPacket(bufio.NewReader(strings.NewReader("\x30\x05abc"))); err == nil {
+		t.Fatal("expected an error reading a short packet")
+	}
+
+	// A broker sending too many length bytes must not overflow the length and panic.
+	overflow := "\x30" + strings.Repeat("\x80", 9) + "\x01"
+	if _, _, err := readMQTTPacket(bufio.NewReader(strings.NewReader(overflow))); !errors.Is(err, ErrMQTTPacket) {
+		t.Fatalf("expected an invalid packet error for a 10 byte length, got: %v", err)
+	}
+}
+
+func TestMQTTAddress(t *testing.T) {
+	t.Parallel()
+
+	for broker, expect := range map[string]string{
+		"127.0.0.1":             "127.0.0.1:1883",
+		"tcp://broker:1884":     "broker:1884",
+		"mqtts://broker":        "broker:8883",
+		"ssl://broker.lan:8884": "broker.lan:8884",
+	} {
+		addr, _, err := (&MQTTConfig{Broker: broker}).address()
+		if err != nil || addr != expect {
+			t.Fatalf("%s: expected %s, got: %s, %v", broker, expect, addr, err)
+		}
+	}
+
+	if _, _, err := (&MQTTConfig{Broker: "http://broker"}).address(); err == nil {
+		t.Fatal("expected an error with an http broker")
+	}
+}
+
+func TestMQTT(t *testing.T) {
+	t.Parallel()
+
+	broker, messages := fakeBroker(t)
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config: &Config{
+			Timeout: cnfg.Duration{Duration: time.Second},
+			MQTT:    &MQTTConfig{Broker: broker, ClientID: "Test.Box", HomeAssistant: true, Exclude: StringSlice{"lidarr"}},
+		},
+		History: &History{Map: map[string]*Extract{"one": {Status: EXTRACTING}}},
+		Logger:  &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+
+	if err := unpackerr.validateMQTT(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	unpackerr.mqttEvent(&WebhookPayload{App: starr.Lidarr, Event: EXTRACTED}) // excluded.
+	unpackerr.mqttEvent(&WebhookPayload{App: starr.Sonarr, Path: "/some/path", Event: EXTRACTED})
+	unpackerr.mqttStats()
+
+	for range len(unpackerr.MQTT.queue) {
+		unpackerr.mqttPublish(<-unpackerr.MQTT.queue)
+	}
+
+	unpackerr.MQTT.disconnect()
+
+	received := make(map[string]*mqttMessage)
+	for msg := range messages {
+		received[msg.Topic] = msg
+	}
+
+	if msg := received["unpackerr/status"]; msg == nil || string(msg.Payload) != "offline" || !msg.Retain {
+		t.Fatalf("expected a retained offline status after disconnecting, got: %v", msg)
+	}
+
+	if received["unpackerr/lidarr/extracted"] != nil {
+		t.Fatal("expected the excluded app to not be published")
+	}
+
+	event := received["unpackerr/sonarr/extracted"]
+	if event == nil || event.Retain || !strings.Contains(string(event.Payload), "/some/path") {
+		t.Fatalf("expected the event to be published, got: %v", event)
+	}
+
+	stat := received["unpackerr/stats/extracting"]
+	if stat == nil || !stat.Retain || string(stat.Payload) != strconv.Itoa(1) {
+		t.Fatalf("expected a retained extracting count of 1, got: %v", stat)
+	}
+
+	discovery := received["homeassistant/sensor/test_box/extracting/config"]
+	if discovery == nil || !discovery.Retain {
+		t.Fatalf("expected a retained discovery config, got: %v", discovery)
+	}
+
+	var config map[string]any
+	if err := json.Unmarshal(discovery.Payload, &config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if config["state_topic"] != "unpackerr/stats/extracting" || config["unique_id"] != "test_box_extracting" {
+		t.Fatalf("unexpected discovery config: %v", config)
+	}
+}
+
+func TestMQTTLostConnection(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer listener.Close()
+
+	go func() { // Accept the connection, then hang up.
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		_, _, _ = readMQTTPacket(bufio.NewReader(conn))
+		_, _ = conn.Write([]byte{mqttConnack, 2, 0, 0})
+		conn.Close()
+	}()
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config: &Config{
+			Timeout: cnfg.Duration{Duration: time.Second},
+			MQTT:    &MQTTConfig{Broker: listener.Addr().String()},
+		},
+		Logger: &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+
+	if err := unpackerr.validateMQTT(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := unpackerr.MQTT.connect(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-unpackerr.MQTT.lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the reader to report the lost connection")
+	}
+
+	// Messages wait for the broker while it's unreachable.
+	unpackerr.MQTT.close()
+	unpackerr.MQTT.lastDial = time.Now()
+
+	for idx := range defaultHookQueue + 1 {
+		unpackerr.mqttPublish(&mqttMessage{Topic: strconv.Itoa(idx)})
+	}
+
+	if pending := unpackerr.MQTT.pending; len(pending) != defaultHookQueue || pending[0].Topic != "1" {
+		t.Fatalf("expected the newest %d messages to wait, got %d", defaultHookQueue, len(pending))
+	}
+}
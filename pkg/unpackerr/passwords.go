@@ -0,0 +1,330 @@
+package unpackerr
+
+/* Archive password lists, password files, and figuring out which password worked. */
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/nwaples/rardecode/v2"
+	"golift.io/xtractr"
+)
+
+const (
+	passwordFilePrefix = "filepath:"
+	// passwordProbeSize is how much data is read from an archive to check a password.
+	passwordProbeSize = megabyte
+	// passwordProbeMax is how many candidates are tried to find the password that worked.
+	// The password is not reported when more candidates are needed.
+	passwordProbeMax = 10
+)
+
+// Password sources, used in logs and webhook payloads.
+const (
+	sourcePath   = "path"
+	sourceGlobal = "global"
+)
+
+// firstPartRegexp matches the first volume of a multi-part rar archive.
+var firstPartRegexp = regexp.MustCompile(`(?i)\.part0*1\.rar$`)
+
+// passwordList holds literal passwords and password files.
+// Password files are re-read when they change, so new passwords do not require a restart.
+// This is only used in the main go routine.
+type passwordList struct {
+	static []string
+	files  []*passwordFile
+}
+
+type passwordFile struct {
+	path      string
+	modTime   time.Time
+	passwords []string
+}
+
+// passwordCandidate is a password to try, and where it came from.
+type passwordCandidate struct {
+	password string
+	source   string
+}
+
+// passwordProbe is shared with an extraction callback. The callback sets found after
+// extraction, and the main go routine reads it after the response arrives on a channel.
+type passwordProbe struct {
+	candidates []*passwordCandidate
+	found      *passwordCandidate
+}
+
+// newPasswordList splits literal passwords from password files, and reads each file.
+// Returns the list and the literal passwords; the file paths are kept out of the config
+// so the config file parser does not replace them with (truncated) file contents.
+func newPasswordList(passwords []string) (*passwordList, []string, error) {
+	list := &passwordList{static: []string{}}
+
+	for _, pass := range passwords {
+		if !strings.HasPrefix(pass, passwordFilePrefix) {
+			list.static = append(list.static, pass)
+			continue
+		}
+
+		file := &passwordFile{path: expandHomedir(strings.TrimPrefix(pass, passwordFilePrefix))}
+		if _, err := file.read(); err != nil {
+			return nil, nil, err
+		}
+
+		list.files = append(list.files, file)
+	}
+
+	return list, list.static, nil
+}
+
+// read (re-)reads a password file if it changed since the last read. Returns true if it was read.
+func (f *passwordFile) read() (bool, error) {
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("reading password file: %w", err)
+	}
+
+	if stat.ModTime().Equal(f.modTime) {
+		return false, nil
+	}
+
+	fileContent, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("reading password file: %w", err)
+	}
+
+	passwords := strings.Split(string(fileContent), "\n")
+	if len(passwords) > 0 && passwords[len(passwords)-1] == "" {
+		// Remove the last "password" if it's blank (newline at end of file).
+		passwords = passwords[:len(passwords)-1]
+	}
+
+	f.passwords = passwords
+	f.modTime = stat.ModTime()
+
+	return true, nil
+}
+
+// List returns all the passwords, and re-reads any password files that changed.
+// A password file that becomes unreadable keeps its last known passwords.
+func (p *passwordList) List(log Logs) []string {
+	if p == nil {
+		return nil
+	}
+
+	list := append([]string{}, p.static...)
+
+	for _, file := range p.files {
+		if changed, err := file.read(); err != nil {
+			log.Errorf("Password file %s: %v (using %d cached passwords)", file.path, err, len(file.passwords))
+		} else if changed {
+			log.Printf("Password file changed, loaded %d passwords: %s", len(file.passwords), file.path)
+		}
+
+		list = append(list, file.passwords...)
+	}
+
+	return list
+}
+
+// Count returns the number of known passwords without re-reading files.
+func (p *passwordList) Count() int {
+	if p == nil {
+		return 0
+	}
+
+	count := len(p.static)
+	for _, file := range p.files {
+		count += len(file.passwords)
+	}
+
+	return count
+}
+
+// passwordCandidates returns the passwords to try for an item, in order and without duplicates:
+// passwords from NZB and sidecar files in the download, the password in the path,
+// then the app or folder passwords, then the global passwords.
+func (u *Unpackerr) passwordCandidates(path string, local *passwordList, localSource string) *passwordProbe {
+	probe := &passwordProbe{}
+	seen := make(map[string]bool)
+	add := func(source string, passwords ...string) {
+		for _, pass := range passwords {
+			if !seen[pass] {
+				seen[pass] = true
+				probe.candidates = append(probe.candidates, &passwordCandidate{password: pass, source: source})
+			}
+		}
+	}
+
+	for _, found := range u.sidecarPasswords(path) {
+		add(found.source, found.password)
+	}
+
+	if pass := u.getPasswordFromPath(path); pass != "" {
+		add(sourcePath, pass)
+	}
+
+	add(localSource, local.List(u.Logger)...)
+	add(sourceGlobal, u.passwords.List(u.Logger)...)
+
+	return probe
+}
+
+// Passwords returns the candidate passwords, in order, for the extraction library.
+func (p *passwordProbe) Passwords() []string {
+	passwords := make([]string, len(p.candidates))
+	for idx, candidate := range p.candidates {
+		passwords[idx] = candidate.password
+	}
+
+	return passwords
+}
+
+// Masked returns the password that worked, masked for logs and webhooks, and its source.
+func (p *passwordProbe) Masked() (string, string) {
+	if p == nil || p.found == nil {
+		return "", ""
+	}
+
+	return maskPassword(p.found.password), p.found.source
+}
+
+// maskPassword hides most of a password.
+func maskPassword(pass string) string {
+	const minShown = 6
+
+	if len(pass) < minShown {
+		return "****"
+	}
+
+	return pass[:1] + "****" + pass[len(pass)-1:]
+}
+
+// passwordCallback returns an xtractr callback that figures out which password candidate opens
+// the first encrypted archive after a successful extraction. The extraction library tries every
+// password, but does not say which one worked. This runs in the extraction go routine, so it is
+// chained after every other callback, checks only one archive, and tries at most passwordProbeMax
+// candidates. Only rar and 7z archives take passwords; zip archives are never probed.
+func passwordCallback(probe *passwordProbe) func(*xtractr.Response) {
+	return func(resp *xtractr.Response) {
+		if !resp.Done || resp.Error != nil || len(probe.candidates) == 0 {
+			return
+		}
+
+		for _, archive := range resp.Archives.List() {
+			if !isFirstVolume(archive) || archiveOpens(archive, "") {
+				continue // Not encrypted, or not the first part of an archive.
+			}
+
+			probe.found = probe.find(archive)
+
+			return
+		}
+	}
+}
+
+// find returns the first candidate that opens an encrypted archive. The extraction
+// library tries the passwords in the same order, so that's the one it used.
+func (p *passwordProbe) find(archive string) *passwordCandidate {
+	if len(p.candidates) == 1 {
+		return p.candidates[0] // The archive was extracted, so the only password worked.
+	}
+
+	for _, candidate := range p.candidates[:min(len(p.candidates), passwordProbeMax)] {
+		if archiveOpens(archive, candidate.password) {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+// isFirstVolume returns true for rar and 7z files that may be opened with a password.
+func isFirstVolume(path string) bool {
+	switch lower := strings.ToLower(path); {
+	case strings.HasSuffix(lower, ".7z"), strings.HasSuffix(lower, ".7z.001"):
+		return true
+	case partRarRegexp.MatchString(lower):
+		return firstPartRegexp.MatchString(lower)
+	default:
+		return strings.HasSuffix(lower, ".rar")
+	}
+}
+
+// archiveOpens returns true if the first file in an archive can be read with the password.
+func archiveOpens(path, password string) bool {
+	if strings.HasSuffix(strings.ToLower(path), ".rar") {
+		return rarOpens(path, password)
+	}
+
+	return sevenZipOpens(path, password)
+}
+
+func rarOpens(path, password string) bool {
+	reader, err := rardecode.OpenReader(path, rardecode.Password(password))
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			return false
+		}
+
+		if !header.IsDir {
+			return probeRead(reader)
+		}
+	}
+}
+
+func sevenZipOpens(path, password string) bool {
+	reader, err := sevenzip.OpenReaderWithPassword(path, password)
+	if err != nil {
+		return false
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		fileReader, err := file.Open()
+		if err != nil {
+			return false
+		}
+		defer fileReader.Close()
+
+		return probeRead(fileReader)
+	}
+
+	return false
+}
+
+// probeRead reads the beginning of a file; a bad password fails here.
+func probeRead(reader io.Reader) bool {
+	_, err := io.CopyN(io.Discard, reader, passwordProbeSize)
+	return err == nil || errors.Is(err, io.EOF)
+}
+
+// logPassword saves and logs the (masked) password that opened an item's archives.
+// This runs in the main go routine after the extraction finishes.
+func (u *Unpackerr) logPassword(name string, item *Extract) {
+	if item == nil {
+		return
+	}
+
+	if item.Password, item.PassSource = item.probe.Masked(); item.Password != "" {
+		u.itemLog(name, item).Printf("[%s] Archive password from %s worked: %s (%s)",
+			item.App, item.PassSource, item.Password, item.Path)
+	}
+}
@@ -0,0 +1,168 @@
+package unpackerr
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPasswordListRereadsFiles(t *testing.T) {
+	t.Parallel()
+
+	passFile := filepath.Join(t.TempDir(), "passwords.txt")
+	if err := os.WriteFile(passFile, []byte("one\ntwo\n"), defaultFileMode); err != nil {
+		t.Fatalf("writing password file: %v", err)
+	}
+
+	list, static, err := newPasswordList([]string{"literal", passwordFilePrefix + passFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(static) != 1 || static[0] != "literal" {
+		t.Fatalf("expected only the literal password to remain in the config, got: %v", static)
+	}
+
+	if count := list.Count(); count != 3 {
+		t.Fatalf("expected 3 passwords, got %d", count)
+	}
+
+	if err := os.WriteFile(passFile, []byte("three\n"), defaultFileMode); err != nil {
+		t.Fatalf("writing password file: %v", err)
+	}
+
+	// Make sure the modification time changes on file systems with coarse timestamps.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(passFile, later, later); err != nil {
+		t.Fatalf("changing password file time: %v", err)
+	}
+
+	if passwords := list.List(noopLogger{}); len(passwords) != 2 || passwords[1] != "three" {
+		t.Fatalf("expected the password file to be read again, got: %v", passwords)
+	}
+
+	if _, _, err := newPasswordList([]string{passwordFilePrefix + passFile + ".missing"}); err == nil {
+		t.Fatal("expected an error for a missing password file")
+	}
+}
+
+func TestPasswordCandidatesOrder(t *testing.T) {
+	t.Parallel()
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config: &Config{passwords: &passwordList{static: []string{"global", "shared"}}},
+		Logger: &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+	local := &passwordList{static: []string{"shared", "app"}}
+
+	probe := unpackerr.passwordCandidates("/downloads/Some.Title{{frompath}}", local, "sonarr")
+
+	expected := []string{"frompath", "shared", "app", "global"}
+	if passwords := probe.Passwords(); len(passwords) != len(expected) {
+		t.Fatalf("expected %v, got: %v", expected, passwords)
+	}
+
+	for idx, pass := range probe.Passwords() {
+		if pass != expected[idx] {
+			t.Fatalf("expected %v, got: %v", expected, probe.Passwords())
+		}
+	}
+
+	if probe.candidates[1].source != "sonarr" || probe.candidates[3].source != sourceGlobal {
+		t.Fatalf("unexpected password sources: %s, %s", probe.candidates[1].source, probe.candidates[3].source)
+	}
+
+	if masked := maskPassword("supersecret"); masked != "s****t" {
+		t.Fatalf("unexpected masked password: %s", masked)
+	}
+}
+
+func TestSidecarPasswords(t *testing.T) {
+	t.Parallel()
+
+	download := t.TempDir()
+	files := map[string]string{
+		"Some.Release.nzb": `<?xml version="1.0" encoding="utf-8"?>
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+ <head>
+  <meta type="title">Some Release</meta>
+  <meta type="password">fromnzb</meta>
+ </head>
+ <file subject="not a password"><segments></segments></file>
+</nzb>`,
+		"password.txt":  "fromtxt\n\n  shared  \n",
+		"release.pwd":   "frompwd",
+		"unrelated.txt": "notapassword",
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(download, name), []byte(data), defaultFileMode); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config: &Config{
+			PasswordSidecars: defaultPasswordSidecars(),
+			passwords:        &passwordList{static: []string{"shared", "global"}},
+		},
+		Logger: &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+
+	probe := unpackerr.passwordCandidates(download, nil, "sonarr")
+	sources := map[string]string{}
+
+	for _, candidate := range probe.candidates {
+		sources[candidate.password] = candidate.source
+	}
+
+	expected := map[string]string{
+		"fromnzb": sourceNZB + " Some.Release.nzb",
+		"fromtxt": sourceSidecar + " password.txt",
+		"shared":  sourceSidecar + " password.txt",
+		"frompwd": sourceSidecar + " release.pwd",
+		"global":  sourceGlobal,
+	}
+
+	if len(sources) != len(expected) {
+		t.Fatalf("expected %d passwords, got: %v", len(expected), probe.Passwords())
+	}
+
+	for pass, source := range expected {
+		if sources[pass] != source {
+			t.Fatalf("expected password %s from %s, got: %s", pass, source, sources[pass])
+		}
+	}
+
+	if last := probe.candidates[len(probe.candidates)-1]; last.password != "global" {
+		t.Fatalf("expected sidecar passwords before global passwords, got: %v", probe.Passwords())
+	}
+
+	unpackerr.PasswordSidecars = nil
+	if passwords := unpackerr.passwordCandidates(download, nil, "sonarr").Passwords(); len(passwords) != 2 {
+		t.Fatalf("expected no sidecar passwords when disabled, got: %v", passwords)
+	}
+}
+
+func TestPasswordProbeFind(t *testing.T) {
+	t.Parallel()
+
+	only := &passwordCandidate{password: "only", source: sourceGlobal}
+	if found := (&passwordProbe{candidates: []*passwordCandidate{only}}).find("/missing.rar"); found != only {
+		t.Fatalf("expected the only candidate without opening the archive, got: %v", found)
+	}
+
+	probe := &passwordProbe{}
+	for range passwordProbeMax + 5 {
+		probe.candidates = append(probe.candidates, &passwordCandidate{password: "wrong", source: sourceGlobal})
+	}
+
+	if found := probe.find("/missing.rar"); found != nil {
+		t.Fatalf("expected no password for an archive that does not open, got: %v", found)
+	}
+}
@@ -2,6 +2,7 @@ package unpackerr
 
 import (
 	"fmt"
+	"log/slog"
 	"path/filepath"
 	"strings"
 	"time"
@@ -60,6 +61,12 @@ func (u *Unpackerr) handleProgress(exp *ExtractProgress) {
 		exp.XProg.Extracted++
 	}
 
+	if exp.XProg.Progress == nil || exp.XProg.XFile != exp.XFile {
+		exp.Extract.record(slog.LevelInfo, EXTRACTING.String(), fmt.Sprintf("Extracting archive %d/%d: %s",
+			exp.XProg.Extracted+1, exp.XProg.Archives, exp.XFile.FilePath))
+		exp.Extract.trace.nextArchive(exp.XFile.FilePath, exp.XProg.Extracted+1, time.Now())
+	}
+
 	exp.XProg.Progress = exp.Progress
 }
 
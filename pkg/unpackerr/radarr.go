@@ -41,14 +41,16 @@ func (u *Unpackerr) logRadarr() {
 		u.Printf(" => Radarr Config: 1 server: "+starrLogLine,
 			u.Radarr[0].URL, u.Radarr[0].APIKey != "", u.Radarr[0].Timeout.String(),
 			u.Radarr[0].ValidSSL, u.Radarr[0].Protocols, u.Radarr[0].Syncthing,
-			u.Radarr[0].DeleteOrig, u.Radarr[0].DeleteDelay.String(), u.Radarr[0].Paths)
+			u.Radarr[0].DeleteOrig, u.Radarr[0].DeleteDelay.String(), u.Radarr[0].Paths,
+			u.Radarr[0].MinSize, u.Radarr[0].MaxSize, u.Radarr[0].Atomic, u.Radarr[0].ExtractPath)
 	} else {
 		u.Printf(" => Radarr Config: %d servers", count)
 
 		for _, f := range u.Radarr {
 			u.Printf(starrLogPfx+starrLogLine,
 				f.URL, f.APIKey != "", f.Timeout.String(), f.ValidSSL, f.Protocols,
-				f.Syncthing, f.DeleteOrig, f.DeleteDelay.String(), f.Paths)
+				f.Syncthing, f.DeleteOrig, f.DeleteDelay.String(), f.Paths,
+				f.MinSize, f.MaxSize, f.Atomic, f.ExtractPath)
 		}
 	}
 }
@@ -56,7 +58,7 @@ func (u *Unpackerr) logRadarr() {
 // getRadarrQueue saves the Radarr Queue(s).
 func (u *Unpackerr) getRadarrQueue(server *RadarrConfig, start time.Time) {
 	if server.APIKey == "" {
-		u.Debugf("Radarr (%s): skipped, no API key", server.URL)
+		u.debugf(logPoller, "Radarr (%s): skipped, no API key", server.URL)
 		return
 	}
 
@@ -85,7 +87,8 @@ func (u *Unpackerr) checkRadarrQueue(now time.Time) {
 		for _, record := range server.Queue.Records {
 			switch x, ok := u.Map[record.Title]; {
 			case ok && x.Status == EXTRACTED && u.isComplete(record.Status, record.Protocol, server.Protocols):
-				u.Debugf("%s (%s): Item Waiting for Import (%s): %v", starr.Radarr, server.URL, record.Protocol, record.Title)
+				u.debugf(logPoller, "%s (%s): Item Waiting for Import (%s): %v",
+					starr.Radarr, server.URL, record.Protocol, record.Title)
 			case !ok && u.isComplete(record.Status, record.Protocol, server.Protocols):
 				u.Map[record.Title] = &Extract{ // Save the download to our map.
 					App:         starr.Radarr,
@@ -94,6 +97,12 @@ func (u *Unpackerr) checkRadarrQueue(now time.Time) {
 					Status:      WAITING,
 					DeleteOrig:  server.DeleteOrig,
 					DeleteDelay: server.DeleteDelay.Duration,
+					MinSize:     server.MinSize,
+					MaxSize:     server.MaxSize,
+					ExtractPath: server.ExtractPath,
+					Atomic:      server.Atomic,
+					Report:      server.Report,
+					passwords:   server.passwords,
 					Syncthing:   server.Syncthing,
 					Path:        u.getDownloadPath(record.OutputPath, starr.Radarr, record.Title, server.Paths),
 					IDs: map[string]any{
@@ -104,10 +113,11 @@ func (u *Unpackerr) checkRadarrQueue(now time.Time) {
 					},
 				}
 				u.Map[record.Title].XProg = &ExtractProgress{Extract: u.Map[record.Title]}
+				u.traceItem(record.Title, u.Map[record.Title], now)
 
 				fallthrough
 			default:
-				u.Debugf("%s: (%s): %s (%s:%d%%): %v",
+				u.debugf(logPoller, "%s: (%s): %s (%s:%d%%): %v",
 					starr.Radarr, server.URL, record.Status, record.Protocol,
 					percent(record.Sizeleft, record.Size), record.Title)
 			}
@@ -41,14 +41,16 @@ func (u *Unpackerr) logReadarr() {
 		u.Printf(" => Readarr Config: 1 server: "+starrLogLine,
 			u.Readarr[0].URL, u.Readarr[0].APIKey != "", u.Readarr[0].Timeout.String(),
 			u.Readarr[0].ValidSSL, u.Readarr[0].Protocols, u.Readarr[0].Syncthing,
-			u.Readarr[0].DeleteOrig, u.Readarr[0].DeleteDelay.String(), u.Readarr[0].Paths)
+			u.Readarr[0].DeleteOrig, u.Readarr[0].DeleteDelay.String(), u.Readarr[0].Paths,
+			u.Readarr[0].MinSize, u.Readarr[0].MaxSize, u.Readarr[0].Atomic, u.Readarr[0].ExtractPath)
 	} else {
 		u.Printf(" => Readarr Config: %d servers", count)
 
 		for _, f := range u.Readarr {
 			u.Printf(starrLogPfx+starrLogLine,
 				f.URL, f.APIKey != "", f.Timeout.String(), f.ValidSSL, f.Protocols,
-				f.Syncthing, f.DeleteOrig, f.DeleteDelay.String(), f.Paths)
+				f.Syncthing, f.DeleteOrig, f.DeleteDelay.String(), f.Paths,
+				f.MinSize, f.MaxSize, f.Atomic, f.ExtractPath)
 		}
 	}
 }
@@ -56,7 +58,7 @@ func (u *Unpackerr) logReadarr() {
 // getReadarrQueue saves the Readarr Queue(s).
 func (u *Unpackerr) getReadarrQueue(server *ReadarrConfig, start time.Time) {
 	if server.APIKey == "" {
-		u.Debugf("Readarr (%s): skipped, no API key", server.URL)
+		u.debugf(logPoller, "Readarr (%s): skipped, no API key", server.URL)
 		return
 	}
 
@@ -85,7 +87,8 @@ func (u *Unpackerr) checkReadarrQueue(now time.Time) {
 		for _, record := range server.Queue.Records {
 			switch x, ok := u.Map[record.Title]; {
 			case ok && x.Status == EXTRACTED && u.isComplete(record.Status, record.Protocol, server.Protocols):
-				u.Debugf("%s (%s): Item Waiting for Import (%s): %v", starr.Readarr, server.URL, record.Protocol, record.Title)
+				u.debugf(logPoller, "%s (%s): Item Waiting for Import (%s): %v",
+					starr.Readarr, server.URL, record.Protocol, record.Title)
 			case !ok && u.isComplete(record.Status, record.Protocol, server.Protocols):
 				u.Map[record.Title] = &Extract{
 					App:         starr.Readarr,
@@ -94,6 +97,12 @@ func (u *Unpackerr) checkReadarrQueue(now time.Time) {
 					Status:      WAITING,
 					DeleteOrig:  server.DeleteOrig,
 					DeleteDelay: server.DeleteDelay.Duration,
+					MinSize:     server.MinSize,
+					MaxSize:     server.MaxSize,
+					ExtractPath: server.ExtractPath,
+					Atomic:      server.Atomic,
+					Report:      server.Report,
+					passwords:   server.passwords,
 					Syncthing:   server.Syncthing,
 					Path:        u.getDownloadPath(record.OutputPath, starr.Readarr, record.Title, server.Paths),
 					IDs: map[string]any{
@@ -105,10 +114,11 @@ func (u *Unpackerr) checkReadarrQueue(now time.Time) {
 					},
 				}
 				u.Map[record.Title].XProg = &ExtractProgress{Extract: u.Map[record.Title]}
+				u.traceItem(record.Title, u.Map[record.Title], now)
 
 				fallthrough
 			default:
-				u.Debugf("%s: (%s): %s (%s:%d%%): %v",
+				u.debugf(logPoller, "%s: (%s): %s (%s:%d%%): %v",
 					starr.Readarr, server.URL, record.Status, record.Protocol,
 					percent(record.Sizeleft, record.Size), record.Title)
 			}
@@ -0,0 +1,336 @@
+package unpackerr
+
+/* Scheduled statistics reports. Completed extractions are counted as they finish,
+   and a summary is sent through the hooks as a report event on a schedule. */
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"golift.io/cnfg"
+	"golift.io/starr"
+	"golift.io/version"
+	"golift.io/xtractr"
+)
+
+// Report defaults.
+const (
+	ReportString         = "Unpackerr" // The app name for report events.
+	defaultReportTime    = "08:00"
+	defaultReportWeekday = "monday"
+	defaultReportSlowest = 5
+	reportTimeFormat     = "15:04"
+	reportDaily          = "daily"
+	reportWeekly         = "weekly"
+)
+
+// ErrReportSchedule is returned when the report schedule, time or weekday is invalid.
+var ErrReportSchedule = errors.New("invalid report schedule")
+
+// ReportConfig sets the schedule for statistics reports. Reports are sent as the report
+// event to every webhook, command hook, email and MQTT broker that wants the event.
+//
+//nolint:lll
+type ReportConfig struct {
+	Schedule string `json:"schedule" toml:"schedule" xml:"schedule" yaml:"schedule"` // daily, weekly or a duration.
+	Time     string `json:"time"     toml:"time"     xml:"time"     yaml:"time"`     // Local time of day for daily and weekly reports.
+	Weekday  string `json:"weekday"  toml:"weekday"  xml:"weekday"  yaml:"weekday"`  // Day of the week for weekly reports.
+	Slowest  uint   `json:"slowest"  toml:"slowest"  xml:"slowest"  yaml:"slowest"`  // How many of the slowest items to list.
+	interval time.Duration
+	hour     int // Time of day.
+	minute   int
+	weekday  time.Weekday
+}
+
+// StatsReport is the data in a report event. It covers the extractions that finished between Start and End.
+type StatsReport struct {
+	Title     string        `json:"title"`     // Daily Report, Weekly Report or Report.
+	Start     time.Time     `json:"start"`     // Start of the report period.
+	End       time.Time     `json:"end"`       // End of the report period.
+	Extracted int           `json:"extracted"` // Items extracted.
+	Failed    int           `json:"failed"`    // Failed extractions.
+	Archives  int           `json:"archives"`  // Archives extracted.
+	Files     int           `json:"files"`     // Files written.
+	Bytes     uint64        `json:"bytes"`     // Bytes written.
+	Average   cnfg.Duration `json:"average"`   // Average extraction time.
+	Apps      []*AppStats   `json:"apps"`      // Counters for each app, sorted by name.
+	Slowest   []*SlowItem   `json:"slowest"`   // The slowest extractions, slowest first.
+	Summary   string        `json:"summary"`   // Plain text summary of everything above.
+}
+
+// AppStats are the report counters for one app.
+type AppStats struct {
+	App       starr.App     `json:"app"`
+	Extracted int           `json:"extracted"`
+	Failed    int           `json:"failed"`
+	Bytes     uint64        `json:"bytes"`
+	Average   cnfg.Duration `json:"average"`
+	elapsed   time.Duration
+}
+
+// SlowItem is one of the slowest extractions in a report.
+type SlowItem struct {
+	Name    string        `json:"name"`
+	App     starr.App     `json:"app"`
+	Elapsed cnfg.Duration `json:"elapsed"`
+	Bytes   uint64        `json:"bytes"`
+}
+
+// SlowestList returns the slowest items as a plain text list, for templates.
+func (s *StatsReport) SlowestList() string {
+	var out strings.Builder
+
+	for _, item := range s.Slowest {
+		fmt.Fprintf(&out, "- %s (%s): %v\n", item.Name, item.App, item.Elapsed)
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+// reportStats collects extraction counters for the next report. This is used in the main go routine.
+// It is only created when reports are scheduled.
+type reportStats struct {
+	start    time.Time
+	apps     map[starr.App]*AppStats
+	slowest  []*SlowItem
+	limit    int
+	archives int
+	files    int
+}
+
+// Enabled returns true if reports are scheduled.
+func (r *ReportConfig) Enabled() bool {
+	return r != nil && r.Schedule != ""
+}
+
+// validateReport parses the report schedule.
+func (u *Unpackerr) validateReport() error {
+	if !u.Report.Enabled() {
+		return nil
+	}
+
+	report := u.Report
+	if report.Time == "" {
+		report.Time = defaultReportTime
+	}
+
+	if report.Weekday == "" {
+		report.Weekday = defaultReportWeekday
+	}
+
+	at, err := time.Parse(reportTimeFormat, report.Time)
+	if err != nil {
+		return fmt.Errorf("%w: time must be like 08:00: %w", ErrReportSchedule, err)
+	}
+
+	report.hour, report.minute = at.Hour(), at.Minute()
+
+	weekday := slices.IndexFunc([]time.Weekday{time.Sunday, time.Monday, time.Tuesday,
+		time.Wednesday, time.Thursday, time.Friday, time.Saturday}, func(day time.Weekday) bool {
+		return strings.EqualFold(day.String(), report.Weekday)
+	})
+	if weekday == -1 {
+		return fmt.Errorf("%w: unknown weekday: %s", ErrReportSchedule, report.Weekday)
+	}
+
+	report.weekday = time.Weekday(weekday)
+	u.counters = newReportStats(version.Started, report.Slowest)
+
+	switch schedule := strings.ToLower(report.Schedule); schedule {
+	case reportDaily, reportWeekly:
+		report.Schedule = schedule
+	default:
+		if report.interval, err = time.ParseDuration(schedule); err != nil || report.interval < time.Minute {
+			return fmt.Errorf("%w: use daily, weekly or a duration of at least 1m: %s", ErrReportSchedule, schedule)
+		}
+	}
+
+	return nil
+}
+
+func (u *Unpackerr) logReport() {
+	if !u.Report.Enabled() {
+		return
+	}
+
+	switch u.Report.Schedule {
+	case reportDaily:
+		u.Printf(" => Statistics Report: daily at %s, slowest: %d", u.Report.Time, u.Report.Slowest)
+	case reportWeekly:
+		u.Printf(" => Statistics Report: weekly on %s at %s, slowest: %d",
+			u.Report.weekday, u.Report.Time, u.Report.Slowest)
+	default:
+		u.Printf(" => Statistics Report: every %v, slowest: %d", u.Report.interval, u.Report.Slowest)
+	}
+}
+
+// next returns the time of the next report after now. Daily and weekly reports are at
+// the same wall clock time every day, including the days daylight saving time changes.
+func (r *ReportConfig) next(now time.Time) time.Time {
+	if r.interval > 0 {
+		return now.Add(r.interval)
+	}
+
+	year, month, day := now.Date()
+	next := time.Date(year, month, day, r.hour, r.minute, 0, 0, now.Location())
+
+	for days := 1; !next.After(now) || r.Schedule == reportWeekly && next.Weekday() != r.weekday; days++ {
+		next = time.Date(year, month, day+days, r.hour, r.minute, 0, 0, now.Location())
+	}
+
+	return next
+}
+
+// title returns the name of a report.
+func (r *ReportConfig) title() string {
+	switch r.Schedule {
+	case reportDaily:
+		return "Daily Report"
+	case reportWeekly:
+		return "Weekly Report"
+	default:
+		return "Report"
+	}
+}
+
+// nextReport returns a channel that fires when the next report is due, or nil if reports are disabled.
+func (u *Unpackerr) nextReport(now time.Time) <-chan time.Time {
+	if !u.Report.Enabled() {
+		return nil
+	}
+
+	next := u.Report.next(now)
+	u.Debugf("Next statistics report: %v", next.Round(time.Second))
+
+	return time.After(next.Sub(now))
+}
+
+func newReportStats(start time.Time, limit uint) *reportStats {
+	return &reportStats{start: start, apps: make(map[starr.App]*AppStats), limit: int(limit)}
+}
+
+// observe counts a completed extraction. Called from updateMetrics.
+func (r *reportStats) observe(resp *xtractr.Response, app starr.App) {
+	if r == nil || errors.Is(resp.Error, xtractr.ErrNoCompressedFiles) {
+		return
+	}
+
+	stats := r.apps[app]
+	if stats == nil {
+		stats = &AppStats{App: app}
+		r.apps[app] = stats
+	}
+
+	if resp.Error != nil {
+		stats.Failed++
+		return
+	}
+
+	stats.Extracted++
+	stats.Bytes += resp.Size
+	stats.elapsed += resp.Elapsed
+	r.archives += resp.Archives.Count() + resp.Extras.Count()
+	r.files += len(resp.NewFiles)
+
+	if r.limit == 0 {
+		return
+	}
+
+	// Keep the slowest items, slowest first.
+	item := &SlowItem{Name: resp.X.Name, App: app, Elapsed: cnfg.Duration{Duration: resp.Elapsed}, Bytes: resp.Size}
+	idx, _ := slices.BinarySearchFunc(r.slowest, item, func(a, b *SlowItem) int {
+		return cmp.Compare(b.Elapsed.Duration, a.Elapsed.Duration)
+	})
+
+	if r.slowest = slices.Insert(r.slowest, idx, item); len(r.slowest) > r.limit {
+		r.slowest = r.slowest[:r.limit]
+	}
+}
+
+// report returns the collected stats as a report.
+func (r *reportStats) report(title string, now time.Time) *StatsReport {
+	report := &StatsReport{
+		Title:    title,
+		Start:    r.start,
+		End:      now,
+		Archives: r.archives,
+		Files:    r.files,
+		Apps:     []*AppStats{},
+		Slowest:  r.slowest,
+	}
+	if report.Slowest == nil {
+		report.Slowest = []*SlowItem{}
+	}
+
+	var elapsed time.Duration
+
+	for _, stats := range r.apps {
+		if stats.Extracted > 0 {
+			stats.Average.Duration = (stats.elapsed / time.Duration(stats.Extracted)).Round(time.Millisecond)
+		}
+
+		report.Apps = append(report.Apps, stats)
+		report.Extracted += stats.Extracted
+		report.Failed += stats.Failed
+		report.Bytes += stats.Bytes
+		elapsed += stats.elapsed
+	}
+
+	slices.SortFunc(report.Apps, func(a, b *AppStats) int { return strings.Compare(string(a.App), string(b.App)) })
+
+	if report.Extracted > 0 {
+		report.Average.Duration = (elapsed / time.Duration(report.Extracted)).Round(time.Millisecond)
+	}
+
+	report.Summary = report.summary()
+
+	return report
+}
+
+// summary returns a plain text summary of the report, used by the built-in templates.
+func (s *StatsReport) summary() string {
+	var out bytes.Buffer
+
+	fmt.Fprintf(&out, "%s: %d extracted, %d failed", s.Title, s.Extracted, s.Failed)
+
+	if s.Bytes > 0 {
+		fmt.Fprintf(&out, ", %s written", humanbytes(s.Bytes))
+	}
+
+	if s.Average.Duration > 0 {
+		fmt.Fprintf(&out, ", %v average", s.Average)
+	}
+
+	out.WriteString(".\n")
+
+	for _, app := range s.Apps {
+		fmt.Fprintf(&out, "%s: %d extracted, %d failed, %s\n", app.App, app.Extracted, app.Failed, humanbytes(app.Bytes))
+	}
+
+	if len(s.Slowest) > 0 {
+		out.WriteString("\nSlowest:\n" + s.SlowestList())
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+// sendReport sends the statistics report to the hooks as a report event, and starts a new report period.
+// This runs in the main go routine on the report schedule.
+func (u *Unpackerr) sendReport(now time.Time) {
+	report := u.counters.report(u.Report.title(), now)
+	u.counters = newReportStats(now, u.Report.Slowest)
+
+	u.Printf("[Report] %s", strings.ReplaceAll(report.Summary, "\n", "; "))
+	u.runAllHooks(&Extract{
+		App:     ReportString,
+		Status:  REPORT,
+		Updated: now,
+		IDs:     map[string]any{"title": report.Title},
+		summary: report,
+	})
+}
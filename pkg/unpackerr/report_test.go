@@ -0,0 +1,128 @@
+package unpackerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"golift.io/starr"
+	"golift.io/xtractr"
+)
+
+func TestReportSchedule(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday morning.
+	now := time.Date(2024, time.March, 6, 9, 30, 0, 0, time.UTC)
+
+	for schedule, next := range map[*ReportConfig]time.Time{
+		{Schedule: "Daily", Time: "08:00"}:                        time.Date(2024, time.March, 7, 8, 0, 0, 0, time.UTC),
+		{Schedule: "daily", Time: "10:15"}:                        time.Date(2024, time.March, 6, 10, 15, 0, 0, time.UTC),
+		{Schedule: "weekly", Time: "08:00", Weekday: "Monday"}:    time.Date(2024, time.March, 11, 8, 0, 0, 0, time.UTC),
+		{Schedule: "weekly", Time: "23:00", Weekday: "wednesday"}: time.Date(2024, time.March, 6, 23, 0, 0, 0, time.UTC),
+		{Schedule: "6h"}: now.Add(6 * time.Hour),
+	} {
+		unpackerr := &Unpackerr{Config: &Config{Report: schedule}}
+		if err := unpackerr.validateReport(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", schedule.Schedule, err)
+		}
+
+		if got := schedule.next(now); !got.Equal(next) {
+			t.Fatalf("%s at %s: expected %v, got: %v", schedule.Schedule, schedule.Time, next, got)
+		}
+	}
+
+	// Daylight saving time starts on Sunday, March 10th, 2024 in New York.
+	if newYork, err := time.LoadLocation("America/New_York"); err == nil {
+		schedule := &ReportConfig{Schedule: "weekly", Time: "08:00", Weekday: "Sunday"}
+		_ = (&Unpackerr{Config: &Config{Report: schedule}}).validateReport()
+
+		if next := schedule.next(time.Date(2024, time.March, 6, 9, 30, 0, 0, newYork)); next.Hour() != 8 || next.Day() != 10 {
+			t.Fatalf("expected 08:00 on the day daylight saving time starts, got: %v", next)
+		}
+	}
+
+	for _, schedule := range []*ReportConfig{
+		{Schedule: "monthly"}, {Schedule: "30s"}, {Schedule: "daily", Time: "8am"}, {Schedule: "weekly", Weekday: "fri"},
+	} {
+		if err := (&Unpackerr{Config: &Config{Report: schedule}}).validateReport(); err == nil {
+			t.Fatalf("expected an error for schedule: %+v", schedule)
+		}
+	}
+}
+
+// goldenReport returns a report with two apps, a failure and three slow items.
+func goldenReport() *StatsReport {
+	start := time.Date(2024, time.March, 1, 8, 0, 0, 0, time.UTC)
+	counters := newReportStats(start, 2)
+
+	for idx, app := range []starr.App{starr.Radarr, starr.Sonarr, starr.Sonarr} {
+		counters.observe(&xtractr.Response{
+			X:        &xtractr.Xtract{Name: "Item " + string(rune('A'+idx))},
+			Size:     uint64(idx+1) * 1000,
+			Elapsed:  time.Duration(idx+1) * time.Minute,
+			Archives: xtractr.ArchiveList{"/downloads": []string{"a.rar", "b.rar"}},
+			NewFiles: []string{"file.mkv"},
+		}, app)
+	}
+
+	counters.observe(&xtractr.Response{Error: xtractr.ErrInvalidHead, X: &xtractr.Xtract{}}, starr.Radarr)
+	counters.observe(&xtractr.Response{Error: xtractr.ErrNoCompressedFiles, X: &xtractr.Xtract{}}, FolderString)
+
+	return counters.report("Daily Report", start.Add(24*time.Hour))
+}
+
+func TestStatsReport(t *testing.T) {
+	t.Parallel()
+
+	report := goldenReport()
+
+	if report.Extracted != 3 || report.Failed != 1 || report.Bytes != 6000 ||
+		report.Archives != 6 || report.Files != 3 || report.Average.Duration != 2*time.Minute {
+		t.Fatalf("unexpected totals: %+v", report)
+	}
+
+	if len(report.Apps) != 2 || report.Apps[0].App != starr.Radarr || report.Apps[0].Failed != 1 ||
+		report.Apps[1].Average.Duration != 150*time.Second {
+		t.Fatalf("unexpected app counters: %+v, %+v", report.Apps[0], report.Apps[1])
+	}
+
+	if len(report.Slowest) != 2 || report.Slowest[0].Name != "Item C" || report.Slowest[1].Name != "Item B" {
+		t.Fatalf("expected the two slowest items, slowest first: %+v", report.Slowest)
+	}
+
+	if !strings.HasPrefix(report.Summary, "Daily Report: 3 extracted, 1 failed") {
+		t.Fatalf("unexpected summary: %s", report.Summary)
+	}
+}
+
+func TestReportTemplates(t *testing.T) {
+	t.Parallel()
+
+	payload := goldenPayload(REPORT)
+	payload.App, payload.Path, payload.Data = ReportString, "", nil
+	payload.IDs = map[string]any{"title": "Daily Report"}
+	payload.Report = goldenReport()
+
+	for name := range webhookTemplates {
+		tmpl, err := (&WebhookConfig{TempName: name, Channel: "downloads"}).Template()
+		if err != nil {
+			t.Fatalf("%s: parsing template: %v", name, err)
+		}
+
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, payload); err != nil {
+			t.Fatalf("%s: executing template: %v", name, err)
+		}
+
+		if name != "pushover" && !json.Valid(body.Bytes()) {
+			t.Fatalf("%s: invalid json:\n%s", name, body.String())
+		}
+
+		if !strings.Contains(body.String(), "Item C") && !strings.Contains(body.String(), "Item+C") {
+			t.Fatalf("%s: the report is missing:\n%s", name, body.String())
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package unpackerr
+
+/* Archive passwords found in NZB files and password sidecar files next to a download. */
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// sidecarMaxSize is the largest password sidecar file that is read. NZB files only have their head read.
+	sidecarMaxSize = 64 * kilobyte
+	sourceNZB      = "nzb"
+	sourceSidecar  = "sidecar"
+)
+
+// defaultPasswordSidecars are the file names (globs) checked for archive passwords.
+func defaultPasswordSidecars() StringSlice {
+	return StringSlice{"*.nzb", "password.txt", "*.pwd"}
+}
+
+// validatePasswordSidecars makes sure every password sidecar glob is valid.
+func (u *Unpackerr) validatePasswordSidecars() error {
+	for _, glob := range u.PasswordSidecars {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return fmt.Errorf("password sidecar glob '%s': %w", glob, err)
+		}
+	}
+
+	return nil
+}
+
+// sidecarPasswords returns the passwords found in sidecar files in a download folder, and their sources.
+// Only the top of the folder is checked. A lone archive file has no sidecars.
+func (u *Unpackerr) sidecarPasswords(path string) []*passwordCandidate {
+	if len(u.PasswordSidecars) == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil // Not a folder, or it's gone; the extraction reports any real problem.
+	}
+
+	found := []*passwordCandidate{}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !u.isPasswordSidecar(entry.Name()) {
+			continue
+		}
+
+		file := filepath.Join(path, entry.Name())
+		source := sourceSidecar + " " + entry.Name()
+		read := readSidecarPasswords
+
+		if strings.EqualFold(filepath.Ext(entry.Name()), ".nzb") {
+			source, read = sourceNZB+" "+entry.Name(), readNZBPasswords
+		}
+
+		passwords, err := read(file)
+		if err != nil {
+			u.Errorf("Reading password sidecar %s: %v", file, err)
+			continue
+		}
+
+		for _, pass := range passwords {
+			found = append(found, &passwordCandidate{password: pass, source: source})
+		}
+	}
+
+	return found
+}
+
+// isPasswordSidecar returns true if a file name matches one of the password sidecar globs.
+func (u *Unpackerr) isPasswordSidecar(name string) bool {
+	for _, glob := range u.PasswordSidecars {
+		if match, _ := filepath.Match(strings.ToLower(glob), strings.ToLower(name)); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readSidecarPasswords reads a plain text password file; one password per line, blank lines ignored.
+func readSidecarPasswords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+
+	passwords := []string{}
+	scanner := bufio.NewScanner(io.LimitReader(file, sidecarMaxSize))
+
+	for scanner.Scan() {
+		if pass := strings.TrimSpace(scanner.Text()); pass != "" {
+			passwords = append(passwords, pass)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return passwords, fmt.Errorf("reading file: %w", err)
+	}
+
+	return passwords, nil
+}
+
+// readNZBPasswords reads the <meta type="password"> values from the head of an NZB file.
+// Decoding stops at the end of the head, so large NZB files are not read completely.
+func readNZBPasswords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.Open: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		passwords = []string{}
+		decoder   = xml.NewDecoder(file)
+		meta      struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		}
+	)
+
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return passwords, nil
+		} else if err != nil {
+			return passwords, fmt.Errorf("decoding nzb: %w", err)
+		}
+
+		switch elem := token.(type) {
+		case xml.StartElement:
+			if elem.Name.Local == "file" {
+				return passwords, nil // No more head.
+			} else if elem.Name.Local != "meta" {
+				continue
+			}
+
+			if err := decoder.DecodeElement(&meta, &elem); err != nil {
+				return passwords, fmt.Errorf("decoding nzb meta: %w", err)
+			}
+
+			if pass := strings.TrimSpace(meta.Value); strings.EqualFold(meta.Type, "password") && pass != "" {
+				passwords = append(passwords, pass)
+			}
+		case xml.EndElement:
+			if elem.Name.Local == "head" {
+				return passwords, nil
+			}
+		}
+	}
+}
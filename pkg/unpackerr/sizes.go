@@ -0,0 +1,120 @@
+package unpackerr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/bytefmt"
+	"golift.io/xtractr"
+)
+
+// ByteSize is a config value that accepts a plain number of bytes or a human size like "500KB" or "40GiB".
+type ByteSize uint64
+
+// These match the extra volumes that belong to a multi-part archive.
+var (
+	partRarRegexp = regexp.MustCompile(`(?i)^(.+)\.part\d+\.rar$`)
+	rarRegexp     = regexp.MustCompile(`(?i)^(.+)\.(rar|r\d\d)$`)
+	multi7zRegexp = regexp.MustCompile(`(?i)^(.+)\.7z\.\d{3}$`)
+)
+
+// UnmarshalText parses a human readable size from a config file or environment variable.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	str := strings.TrimSpace(string(text))
+	if str == "" {
+		*b = 0
+		return nil
+	}
+
+	if size, err := strconv.ParseUint(str, 10, 64); err == nil {
+		*b = ByteSize(size)
+		return nil
+	}
+
+	size, err := bytefmt.ToBytes(str)
+	if err != nil {
+		return fmt.Errorf("invalid size '%s': %w", str, err)
+	}
+
+	*b = ByteSize(size)
+
+	return nil
+}
+
+// MarshalText turns a size back into a human readable string.
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// String returns a human readable size.
+func (b ByteSize) String() string {
+	return bytefmt.ByteSize(uint64(b))
+}
+
+// checkSize returns a reason if the archives' total size falls outside the min and max.
+// An empty string means the archives may be extracted. A zero min or max disables that side of the check.
+func checkSize(archives xtractr.ArchiveList, minSize, maxSize ByteSize) string {
+	if minSize == 0 && maxSize == 0 {
+		return ""
+	}
+
+	switch size := archiveSize(archives); {
+	case minSize > 0 && size < uint64(minSize):
+		return fmt.Sprintf("archive size %s is below min_size %s", bytefmt.ByteSize(size), minSize)
+	case maxSize > 0 && size > uint64(maxSize):
+		return fmt.Sprintf("archive size %s is above max_size %s", bytefmt.ByteSize(size), maxSize)
+	default:
+		return ""
+	}
+}
+
+// archiveSize returns the total size of every archive in a list, including
+// the extra volumes (.r00, .part02.rar, .7z.002) that belong to each archive.
+func archiveSize(archives xtractr.ArchiveList) uint64 {
+	var (
+		size    uint64
+		counted = make(map[string]bool)
+	)
+
+	for _, archive := range archives.List() {
+		dir := filepath.Dir(archive)
+		pattern := volumeRegexp(filepath.Base(archive))
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if entry.IsDir() || counted[path] || !pattern.MatchString(entry.Name()) {
+				continue
+			}
+
+			if info, err := entry.Info(); err == nil {
+				counted[path] = true
+				size += uint64(info.Size()) //nolint:gosec // file sizes are never negative.
+			}
+		}
+	}
+
+	return size
+}
+
+// volumeRegexp returns a regular expression that matches an archive file name and all of its extra volumes.
+func volumeRegexp(name string) *regexp.Regexp {
+	switch {
+	case partRarRegexp.MatchString(name):
+		return regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(partRarRegexp.FindStringSubmatch(name)[1]) + `\.part\d+\.rar$`)
+	case rarRegexp.MatchString(name):
+		return regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(rarRegexp.FindStringSubmatch(name)[1]) + `\.(rar|r\d\d)$`)
+	case multi7zRegexp.MatchString(name):
+		return regexp.MustCompile(`(?i)^` + regexp.QuoteMeta(multi7zRegexp.FindStringSubmatch(name)[1]) + `\.7z\.\d{3}$`)
+	default:
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `$`)
+	}
+}
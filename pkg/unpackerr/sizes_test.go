@@ -0,0 +1,97 @@
+package unpackerr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golift.io/xtractr"
+)
+
+func TestByteSizeUnmarshalText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]ByteSize{
+		"":      0,
+		"1024":  1024,
+		"2K":    2048,
+		"10MB":  10 * megabyte,
+		" 1GiB": 1024 * megabyte,
+	}
+
+	for input, expected := range tests {
+		var size ByteSize
+		if err := size.UnmarshalText([]byte(input)); err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", input, err)
+		}
+
+		if size != expected {
+			t.Fatalf("parsing %q: expected %d, got %d", input, expected, size)
+		}
+	}
+
+	var size ByteSize
+	if err := size.UnmarshalText([]byte("ten megs")); err == nil {
+		t.Fatal("expected an error parsing an invalid size")
+	}
+}
+
+func TestCheckSizeCountsVolumes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]int{
+		"movie.part01.rar": 100,
+		"movie.part02.rar": 100,
+		"movie.part03.rar": 50,
+		"other.rar":        1000,
+		"subs.zip":         10,
+	}
+
+	for name, size := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o600); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+
+	archives := xtractr.ArchiveList{dir: {filepath.Join(dir, "movie.part01.rar"), filepath.Join(dir, "subs.zip")}}
+	if size := archiveSize(archives); size != 260 {
+		t.Fatalf("expected 260 bytes of archives, got %d", size)
+	}
+
+	if reason := checkSize(archives, 0, 0); reason != "" {
+		t.Fatalf("expected no reason without limits, got: %s", reason)
+	}
+
+	if reason := checkSize(archives, 261, 0); reason == "" {
+		t.Fatal("expected archives below min_size to be skipped")
+	}
+
+	if reason := checkSize(archives, 0, 259); reason == "" {
+		t.Fatal("expected archives above max_size to be skipped")
+	}
+
+	if reason := checkSize(archives, 260, 260); reason != "" {
+		t.Fatalf("expected archives within range to be allowed, got: %s", reason)
+	}
+}
+
+func TestExtractFilterSize(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, size := range map[string]int{"movie.rar": 100, "disc.iso": 5000, "album.cue": 10} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o600); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+
+	// The iso is never extracted from a starr download, so it must not count toward the size.
+	if size := archiveSize(xtractr.FindCompressedFiles(extractFilter(&Extract{Path: dir}))); size != 100 {
+		t.Fatalf("expected only the rar to count, got %d bytes", size)
+	}
+
+	if size := archiveSize(xtractr.FindCompressedFiles(xtractr.Filter{Path: dir})); size <= 100 {
+		t.Fatalf("expected the unfiltered search to find the iso, got %d bytes", size)
+	}
+}
@@ -41,14 +41,16 @@ func (u *Unpackerr) logSonarr() {
 		u.Printf(" => Sonarr Config: 1 server: "+starrLogLine,
 			u.Sonarr[0].URL, u.Sonarr[0].APIKey != "", u.Sonarr[0].Timeout.String(),
 			u.Sonarr[0].ValidSSL, u.Sonarr[0].Protocols, u.Sonarr[0].Syncthing,
-			u.Sonarr[0].DeleteOrig, u.Sonarr[0].DeleteDelay.String(), u.Sonarr[0].Paths)
+			u.Sonarr[0].DeleteOrig, u.Sonarr[0].DeleteDelay.String(), u.Sonarr[0].Paths,
+			u.Sonarr[0].MinSize, u.Sonarr[0].MaxSize, u.Sonarr[0].Atomic, u.Sonarr[0].ExtractPath)
 	} else {
 		u.Printf(" => Sonarr Config: %d servers", count)
 
 		for _, f := range u.Sonarr {
 			u.Printf(starrLogPfx+starrLogLine,
 				f.URL, f.APIKey != "", f.Timeout.String(), f.ValidSSL, f.Protocols,
-				f.Syncthing, f.DeleteOrig, f.DeleteDelay.String(), f.Paths)
+				f.Syncthing, f.DeleteOrig, f.DeleteDelay.String(), f.Paths,
+				f.MinSize, f.MaxSize, f.Atomic, f.ExtractPath)
 		}
 	}
 }
@@ -56,7 +58,7 @@ func (u *Unpackerr) logSonarr() {
 // getSonarrQueue saves the Sonarr Queue(s).
 func (u *Unpackerr) getSonarrQueue(server *SonarrConfig, start time.Time) {
 	if server.APIKey == "" {
-		u.Debugf("Sonarr (%s): skipped, no API key", server.URL)
+		u.debugf(logPoller, "Sonarr (%s): skipped, no API key", server.URL)
 		return
 	}
 
@@ -85,7 +87,7 @@ func (u *Unpackerr) checkSonarrQueue(now time.Time) {
 		for _, record := range server.Queue.Records {
 			switch x, ok := u.Map[record.Title]; {
 			case ok && x.Status == EXTRACTED && u.isComplete(record.Status, record.Protocol, server.Protocols):
-				u.Debugf("%s (%s): Item Waiting for Import: %v", starr.Sonarr, server.URL, record.Title)
+				u.debugf(logPoller, "%s (%s): Item Waiting for Import: %v", starr.Sonarr, server.URL, record.Title)
 			case !ok && u.isComplete(record.Status, record.Protocol, server.Protocols):
 				u.Map[record.Title] = &Extract{
 					App:         starr.Sonarr,
@@ -94,6 +96,12 @@ func (u *Unpackerr) checkSonarrQueue(now time.Time) {
 					Status:      WAITING,
 					DeleteOrig:  server.DeleteOrig,
 					DeleteDelay: server.DeleteDelay.Duration,
+					MinSize:     server.MinSize,
+					MaxSize:     server.MaxSize,
+					ExtractPath: server.ExtractPath,
+					Atomic:      server.Atomic,
+					Report:      server.Report,
+					passwords:   server.passwords,
 					Syncthing:   server.Syncthing,
 					Path:        u.getDownloadPath(record.OutputPath, starr.Sonarr, record.Title, server.Paths),
 					IDs: map[string]any{
@@ -105,10 +113,11 @@ func (u *Unpackerr) checkSonarrQueue(now time.Time) {
 					},
 				}
 				u.Map[record.Title].XProg = &ExtractProgress{Extract: u.Map[record.Title]}
+				u.traceItem(record.Title, u.Map[record.Title], now)
 
 				fallthrough
 			default:
-				u.Debugf("%s (%s): %s (%s:%d%%): %v (Ep: %v)",
+				u.debugf(logPoller, "%s (%s): %s (%s:%d%%): %v (Ep: %v)",
 					starr.Sonarr, server.URL, record.Status, record.Protocol,
 					percent(record.Sizeleft, record.Size), record.Title, record.EpisodeID)
 			}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -15,6 +16,7 @@ import (
 	"golift.io/cnfg"
 	"golift.io/cnfgfile"
 	"golift.io/rotatorr"
+	"golift.io/starr"
 	"golift.io/version"
 	"golift.io/xtractr"
 )
@@ -53,13 +55,18 @@ type Unpackerr struct {
 	*History
 	*xtractr.Xtractr
 	metrics  *metrics
+	tracer   *tracer
 	folders  *Folders
 	sigChan  chan os.Signal
 	updates  chan *xtractr.Response
 	progChan chan *ExtractProgress
-	hookChan chan *hookQueueItem
 	delChan  chan *fileDeleteReq
 	workChan chan []func()
+	gates    chan *gateResult
+	reports  chan *reportRequest
+	healthCh chan chan *FoldersHealth
+	starrs   *starrHealth
+	counters *reportStats
 	*Logger
 	rotatorr *rotatorr.Logger
 	menu     map[string]ui.MenuItem
@@ -71,19 +78,28 @@ type fileDeleteReq struct {
 	// PurgeEmptyRoot, when set with PurgeEmptyParent, allows purging empty parent dirs
 	// up to and including this path (e.g. the Starr app download folder). Stops above this root.
 	PurgeEmptyRoot string
+	// App and URL label the bytes deleted metric.
+	App starr.App
+	URL string
+	// span is the deleted item's trace.
+	span *span
 }
 
 // Logger provides a struct we can pass into other packages.
 type Logger struct {
-	HTTP  *log.Logger
-	Info  *log.Logger
-	Error *log.Logger
-	Debug *log.Logger
+	HTTP       *log.Logger
+	Info       *log.Logger
+	Error      *log.Logger
+	Debug      *log.Logger
+	structured *slog.Logger // Only set with a json or logfmt log format.
+	target     *logTarget   // Only set with a log target.
+	levels     *logLevels   // Runtime debug log levels.
 }
 
 // Flags are our CLI input flags.
 type Flags struct {
 	verReq     bool
+	healthReq  bool
 	ConfigFile string
 	EnvPrefix  string
 	webhook    uint
@@ -94,24 +110,30 @@ type Flags struct {
 func New() *Unpackerr {
 	return &Unpackerr{
 		Flags:    &Flags{EnvPrefix: "UN"},
-		hookChan: make(chan *hookQueueItem, updateChanBuf),
 		delChan:  make(chan *fileDeleteReq, updateChanBuf),
 		sigChan:  make(chan os.Signal),
 		workChan: make(chan []func(), 1),
+		gates:    make(chan *gateResult, updateChanBuf),
+		reports:  make(chan *reportRequest),
+		healthCh: make(chan chan *FoldersHealth),
+		starrs:   &starrHealth{instances: make(map[string]*StarrHealth)},
 		History:  &History{Map: make(map[string]*Extract)},
 		updates:  make(chan *xtractr.Response, updateChanBuf),
 		progChan: make(chan *ExtractProgress),
 		menu:     make(map[string]ui.MenuItem),
 		Config: &Config{
-			KeepHistory: defaultHistory,
-			LogQueues:   cnfg.Duration{Duration: time.Minute + time.Second},
-			MaxRetries:  defaultMaxRetries,
-			LogFiles:    defaultLogFiles,
-			Timeout:     cnfg.Duration{Duration: defaultTimeout},
-			Interval:    cnfg.Duration{Duration: defaultInterval},
-			RetryDelay:  cnfg.Duration{Duration: defaultRetryDelay},
-			StartDelay:  cnfg.Duration{Duration: defaultStartDelay},
-			DeleteDelay: cnfg.Duration{Duration: defaultDeleteDelay},
+			KeepHistory:      defaultHistory,
+			HistoryMax:       defaultHistoryMax,
+			HistoryRetention: cnfg.Duration{Duration: defaultHistoryRetention},
+			PasswordSidecars: defaultPasswordSidecars(),
+			LogQueues:        cnfg.Duration{Duration: time.Minute + time.Second},
+			MaxRetries:       defaultMaxRetries,
+			LogFiles:         defaultLogFiles,
+			Timeout:          cnfg.Duration{Duration: defaultTimeout},
+			Interval:         cnfg.Duration{Duration: defaultInterval},
+			RetryDelay:       cnfg.Duration{Duration: defaultRetryDelay},
+			StartDelay:       cnfg.Duration{Duration: defaultStartDelay},
+			DeleteDelay:      cnfg.Duration{Duration: defaultDeleteDelay},
 			Webserver: &WebServer{
 				Metrics:    false,
 				LogFiles:   defaultLogFiles,
@@ -119,6 +141,21 @@ func New() *Unpackerr {
 				ListenAddr: "0.0.0.0:5656",
 				URLBase:    "/",
 			},
+			MQTT: &MQTTConfig{
+				Prefix:        defaultMQTTPrefix,
+				HomeAssistant: true,
+				Discovery:     defaultMQTTDiscovery,
+				Interval:      cnfg.Duration{Duration: defaultMQTTInterval},
+			},
+			Tracing: &TracingConfig{
+				Service:  defaultTraceService,
+				Interval: cnfg.Duration{Duration: defaultTraceInterval},
+			},
+			Report: &ReportConfig{
+				Time:    defaultReportTime,
+				Weekday: defaultReportWeekday,
+				Slowest: defaultReportSlowest,
+			},
 		},
 		Logger: &Logger{
 			HTTP:  log.New(io.Discard, "", 0),
@@ -145,6 +182,10 @@ func Start() error {
 	if err != nil {
 		return fmt.Errorf("%s: %w", msg, err)
 	}
+
+	if unpackerr.healthReq {
+		return unpackerr.healthCheck() // don't run anything else.
+	}
 	// We cannot log anything until setupLogging() runs.
 	// We cannot run setupLogging until we unmarshal the above config.
 	unpackerr.setupLogging()
@@ -174,23 +215,24 @@ func Start() error {
 		return unpackerr.sampleWebhook(ExtractStatus(unpackerr.webhook))
 	}
 
+	unpackerr.loadHistory()
+
 	unpackerr.Xtractr = xtractr.NewQueue(&xtractr.Config{
 		Parallel: int(unpackerr.Parallel),
 		Suffix:   suffix,
-		Logger:   unpackerr.Logger,
+		Logger:   unpackerr.Logger.subsystem(logXtractr),
 		FileMode: os.FileMode(fileMode),
 		DirMode:  os.FileMode(dirMode),
 	})
 
-	if len(unpackerr.Webhook) > 0 || len(unpackerr.Cmdhook) > 0 {
-		go unpackerr.watchCmdAndWebhooks()
-	}
-
+	unpackerr.startHookWorkers()
+	unpackerr.startTracing()
 	go unpackerr.watchDeleteChannel()
 
 	unpackerr.startWebServer()
 	unpackerr.watchWorkThread()
-	unpackerr.startTray() // runs tray or waits for exit depending on hasGUI.
+	unpackerr.startTray()    // runs tray or waits for exit depending on hasGUI.
+	unpackerr.exportTraces() // send the last spans.
 
 	return nil
 }
@@ -217,7 +259,9 @@ func (u *Unpackerr) watchDeleteChannel() {
 		}
 
 		u.Debugf("Deleting files: %s", strings.Join(fileList(input.Paths...), ", "))
-		u.DeleteFiles(input.Paths...)
+		span := input.span.child("delete", spanKindInternal, time.Now(), slog.Int("paths", len(input.Paths)))
+		u.deleteFiles(input)
+		span.finish(time.Now(), nil)
 
 		if !input.PurgeEmptyParent {
 			continue
@@ -309,29 +353,18 @@ func dirIsEmpty(path string) bool {
 	return err == io.EOF //nolint:errorlint // this is still correct.
 }
 
-func (u *Unpackerr) watchCmdAndWebhooks() {
-	for hook := range u.hookChan {
-		if hook.URL != "" {
-			u.sendWebhookWithLog(hook.WebhookConfig, hook.WebhookPayload)
-		}
-
-		if hook.Command != "" {
-			u.runCmdhookWithLog(hook.WebhookConfig, hook.WebhookPayload)
-		}
-	}
-}
-
 // ParseFlags turns CLI args into usable data.
 func (u *Unpackerr) ParseFlags() *Unpackerr {
 	flag.Usage = func() {
-		fmt.Println("Usage: unpackerr [--config=filepath] [--version]") //nolint:forbidigo
+		fmt.Println("Usage: unpackerr [--config=filepath] [--version] [--healthcheck]") //nolint:forbidigo
 		flag.PrintDefaults()
 	}
 
 	flag.StringVarP(&u.ConfigFile, "config", "c", os.Getenv("UN_CONFIG_FILE"), "Poller Config File (TOML Format)")
 	flag.StringVarP(&u.EnvPrefix, "prefix", "p", "UN", "Environment Variable Prefix")
-	flag.UintVarP(&u.webhook, "webhook", "w", 0, "Send test webhook. Valid values: 1,2,3,4,5,6,7,8")
+	flag.UintVarP(&u.webhook, "webhook", "w", 0, "Send test webhook. Valid values: 1,2,3,4,5,6,7,8,10,11")
 	flag.BoolVarP(&u.verReq, "version", "v", false, "Print the version and exit.")
+	flag.BoolVar(&u.healthReq, "healthcheck", false, "Query the health endpoint of a running Unpackerr and exit.")
 	flag.Parse()
 
 	return u // so you can chain into ParseConfig.
@@ -355,6 +388,15 @@ func (u *Unpackerr) Run() {
 		u.Printf("No Starr apps or folders configured. Shut down and add some apps or folders to your config file.")
 	}
 
+	// Only publish stats to MQTT when a broker is configured.
+	var mqtt <-chan time.Time
+	if u.MQTT.Enabled() {
+		mqtt = time.NewTicker(u.MQTT.Interval.Duration).C
+		u.mqttStats()
+	}
+
+	report := u.nextReport(now) // Only send statistics reports when they're scheduled.
+
 	u.PollFolders()          // This initializes channel(s) used below.
 	u.retrieveAppQueues(now) // Get in-app queues on startup.
 
@@ -391,6 +433,22 @@ func (u *Unpackerr) Run() {
 		case now = <-progress.C:
 			// Print the collected progress info.
 			u.printProgress(now)
+		case gate := <-u.gates:
+			// The pre_extract gate hooks finished for an item.
+			u.handleGateResult(gate, now)
+		case req := <-u.reports:
+			// The web server wants an item's log.
+			u.handleReportRequest(req)
+		case reply := <-u.healthCh:
+			// The web server wants the folder watcher health.
+			reply <- u.foldersHealth()
+		case <-mqtt:
+			// Publish the stats counters to MQTT.
+			u.mqttStats()
+		case now = <-report:
+			// Send the statistics report, and schedule the next one.
+			u.sendReport(now)
+			report = u.nextReport(now)
 		}
 	}
 }
@@ -0,0 +1,570 @@
+package unpackerr
+
+/* Optional OpenTelemetry tracing. Every item gets a trace from the time it's found until it leaves the history,
+   with spans for the start delay, the Starr queue request, each archive, hooks and deletes. Finished spans are
+   collected in memory and exported in batches to an OTLP/HTTP endpoint using the JSON encoding.
+   The few OTLP message types this needs are encoded here, instead of with the OpenTelemetry SDK and
+   exporter modules, which would add many dependencies for one HTTP POST. The JSON mapping is stable. */
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"golift.io/cnfg"
+	"golift.io/starr"
+	"golift.io/version"
+	"golift.io/xtractr"
+)
+
+const (
+	defaultTraceInterval = 10 * time.Second
+	defaultTraceService  = "unpackerr"
+	traceBatchSize       = 512  // Export early when this many spans are waiting.
+	traceBufferSize      = 4096 // Spans kept while the collector is unreachable.
+)
+
+// OTLP span kinds and status codes.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	spanStatusError  = 2
+)
+
+// ErrTraceExport is returned when the collector does not accept the spans.
+var ErrTraceExport = errors.New("trace export failed")
+
+// TracingConfig defines the OTLP endpoint to export item traces to.
+type TracingConfig struct {
+	URL       string            `json:"url"         toml:"url"          xml:"url"          yaml:"url"`
+	Service   string            `json:"serviceName" toml:"service_name" xml:"service_name" yaml:"serviceName"`
+	Headers   map[string]string `json:"headers"     toml:"headers"      xml:"headers"      yaml:"headers"`
+	Interval  cnfg.Duration     `json:"interval"    toml:"interval"     xml:"interval"     yaml:"interval"`
+	Timeout   cnfg.Duration     `json:"timeout"     toml:"timeout"      xml:"timeout"      yaml:"timeout"`
+	IgnoreSSL bool              `json:"ignoreSsl"   toml:"ignore_ssl"   xml:"ignore_ssl"   yaml:"ignoreSsl"`
+}
+
+// tracer collects finished spans and exports them.
+type tracer struct {
+	config  *TracingConfig
+	client  *http.Client
+	spans   []*span
+	dropped uint
+	fetches map[string]*span // The last queue request to each Starr instance.
+	ready   chan struct{}    // Signals a full batch.
+	sync.Mutex
+}
+
+// span is one timed operation in a trace. Every method is safe to call on a nil span, so
+// callers do not check if tracing is enabled. A span's IDs do not change after it's created.
+type span struct {
+	tracer   *tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []slog.Attr
+	err      string
+}
+
+// itemTrace holds an item's open spans. Only the main go routine uses it.
+type itemTrace struct {
+	root    *span
+	extract *span
+	archive *span
+}
+
+// Enabled returns true if an OTLP endpoint is configured.
+func (t *TracingConfig) Enabled() bool {
+	return t != nil && t.URL != ""
+}
+
+func (u *Unpackerr) validateTracing() error {
+	if !u.Tracing.Enabled() {
+		return nil
+	}
+
+	if u.Tracing.Service == "" {
+		u.Tracing.Service = defaultTraceService
+	}
+
+	if u.Tracing.Interval.Duration <= 0 {
+		u.Tracing.Interval.Duration = defaultTraceInterval
+	}
+
+	if u.Tracing.Timeout.Duration == 0 {
+		u.Tracing.Timeout.Duration = u.Timeout.Duration
+	}
+
+	return nil
+}
+
+func (u *Unpackerr) logTracing() {
+	if !u.Tracing.Enabled() {
+		u.Printf(" => Tracing Disabled")
+		return
+	}
+
+	u.Printf(" => Tracing Config: %s, service name: %s, interval: %v, timeout: %v, ignore ssl: %v, headers: %d",
+		u.Tracing.URL, u.Tracing.Service, u.Tracing.Interval, u.Tracing.Timeout, u.Tracing.IgnoreSSL,
+		len(u.Tracing.Headers))
+}
+
+// startTracing creates the tracer and starts the export go routine, if tracing is enabled.
+func (u *Unpackerr) startTracing() {
+	if !u.Tracing.Enabled() {
+		return
+	}
+
+	u.tracer = newTracer(u.Tracing)
+
+	go func() {
+		ticker := time.NewTicker(u.Tracing.Interval.Duration)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-u.tracer.ready:
+			}
+
+			u.exportTraces()
+		}
+	}()
+}
+
+func newTracer(config *TracingConfig) *tracer {
+	return &tracer{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout.Duration,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: config.IgnoreSSL, //nolint:gosec
+			}},
+		},
+		fetches: make(map[string]*span),
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// exportTraces sends the waiting spans to the collector, and logs any error.
+func (u *Unpackerr) exportTraces() {
+	if u.tracer == nil {
+		return
+	}
+
+	if count, err := u.tracer.export(); err != nil {
+		u.Errorf("[Tracing] Exporting %d spans: %v", count, err)
+	} else if count > 0 {
+		u.Debugf("[Tracing] Exported %d spans", count)
+	}
+
+	if dropped := u.tracer.takeDropped(); dropped > 0 {
+		u.Errorf("[Tracing] Dropped %d oldest spans; more than %d were waiting for the collector", dropped, traceBufferSize)
+	}
+}
+
+// start begins a new trace. Returns nil if the tracer is nil.
+func (t *tracer) start(name string, kind int, start time.Time, attrs ...slog.Attr) *span {
+	if t == nil {
+		return nil
+	}
+
+	newSpan := &span{tracer: t, name: name, kind: kind, start: start, attrs: attrs}
+	_, _ = rand.Read(newSpan.traceID[:])
+	_, _ = rand.Read(newSpan.spanID[:])
+
+	return newSpan
+}
+
+// add puts a finished span into the export buffer. The oldest spans are dropped when it's full.
+func (t *tracer) add(finished *span) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.keep(finished); len(t.spans) >= traceBatchSize {
+		select {
+		case t.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// keep puts spans at the end of the export buffer, and drops the oldest spans when it's too full.
+// The caller holds the lock.
+func (t *tracer) keep(spans ...*span) {
+	t.spans = append(t.spans, spans...)
+
+	if extra := len(t.spans) - traceBufferSize; extra > 0 {
+		t.spans = t.spans[extra:]
+		t.dropped += uint(extra)
+	}
+}
+
+// takeDropped returns the number of spans dropped since it was last called.
+func (t *tracer) takeDropped() uint {
+	t.Lock()
+	defer t.Unlock()
+
+	dropped := t.dropped
+	t.dropped = 0
+
+	return dropped
+}
+
+// saveFetch records a Starr queue request, so items found in the queue can show it in their trace.
+// This runs in the app polling go routines.
+func (t *tracer) saveFetch(app starr.App, url string, start time.Time, err error) {
+	if t == nil {
+		return
+	}
+
+	fetch := &span{name: "starr.queue", kind: spanKindClient, start: start, end: time.Now(),
+		attrs: []slog.Attr{slog.String("app", string(app)), slog.String("url", url)}}
+	if err != nil {
+		fetch.err = err.Error()
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	t.fetches[string(app)+url] = fetch
+}
+
+// lastFetch returns the last queue request to a Starr instance.
+func (t *tracer) lastFetch(app starr.App, url string) *span {
+	if t == nil {
+		return nil
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	return t.fetches[string(app)+url]
+}
+
+// child begins a span under this span.
+func (s *span) child(name string, kind int, start time.Time, attrs ...slog.Attr) *span {
+	if s == nil {
+		return nil
+	}
+
+	child := &span{tracer: s.tracer, traceID: s.traceID, parentID: s.spanID,
+		name: name, kind: kind, start: start, attrs: attrs}
+	_, _ = rand.Read(child.spanID[:])
+
+	return child
+}
+
+// set adds attributes to a span that is not finished.
+func (s *span) set(attrs ...slog.Attr) *span {
+	if s != nil {
+		s.attrs = append(s.attrs, attrs...)
+	}
+
+	return s
+}
+
+// finish ends a span and queues it for export. A span only finishes once.
+func (s *span) finish(end time.Time, err error) {
+	if s == nil || !s.end.IsZero() {
+		return
+	}
+
+	if s.end = end; err != nil {
+		s.err = err.Error()
+	}
+
+	s.tracer.add(s)
+}
+
+// traceItem starts a trace for a new item. Items found in a Starr queue include the queue request.
+// This runs in the main go routine.
+func (u *Unpackerr) traceItem(name string, item *Extract, start time.Time) {
+	if u.tracer == nil || item == nil {
+		return
+	}
+
+	root := u.tracer.start("item", spanKindInternal, start, slog.String("item", name),
+		slog.String("app", string(item.App)), slog.String("path", item.Path))
+	if item.URL != "" {
+		root.set(slog.String("url", item.URL))
+	}
+
+	if fetch := u.tracer.lastFetch(item.App, item.URL); fetch != nil {
+		if fetch.start.Before(root.start) {
+			root.start = fetch.start
+		}
+
+		queue := root.child(fetch.name, fetch.kind, fetch.start, fetch.attrs...)
+		queue.err = fetch.err
+		queue.finish(fetch.end, nil)
+	}
+
+	item.trace = &itemTrace{root: root}
+}
+
+// span returns an item's root span. Hooks and deletes use it as their parent. The item may be nil.
+func (item *Extract) span() *span {
+	if item == nil || item.trace == nil {
+		return nil
+	}
+
+	return item.trace.root
+}
+
+// queued adds a span for the time an item waited to be queued.
+func (t *itemTrace) queued(since, now time.Time) {
+	if t != nil {
+		t.root.child("start_delay", spanKindInternal, since).finish(now, nil)
+	}
+}
+
+// extracting starts the extraction span when xtractr starts on an item.
+func (t *itemTrace) extracting(resp *xtractr.Response) {
+	if t != nil {
+		t.extract = t.root.child("extract", spanKindInternal, resp.Started,
+			slog.Int("queued", resp.Queued))
+	}
+}
+
+// nextArchive ends the span for the previous archive, and starts one for the next archive.
+func (t *itemTrace) nextArchive(path string, number int, now time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.archive.finish(now, nil)
+	t.archive = t.extract.child("archive", spanKindInternal, now,
+		slog.String("file", path), slog.Int("number", number))
+}
+
+// extracted ends the extraction and archive spans when xtractr finishes with an item.
+func (t *itemTrace) extracted(resp *xtractr.Response, now time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.archive.finish(now, resp.Error)
+	t.extract.set(slog.Int("archives", resp.Archives.Count()+resp.Extras.Count()),
+		slog.Int("files", len(resp.NewFiles)), slog.Int64("bytes", int64(resp.Size))) //nolint:gosec
+	t.extract.finish(now, resp.Error)
+	t.archive, t.extract = nil, nil
+}
+
+// finishTrace ends an item's trace when it leaves the history. This runs in the main go routine.
+func (u *Unpackerr) finishTrace(item *Extract, now time.Time) {
+	if item == nil || item.trace == nil {
+		return
+	}
+
+	var err error
+	if item.Status == EXTRACTFAILED && item.Resp != nil {
+		err = item.Resp.Error
+	}
+
+	item.trace.root.set(slog.String("status", item.Status.String()), slog.Int("retries", int(item.Retries)))
+	item.trace.root.finish(now, err)
+	item.trace = nil
+}
+
+// export sends the waiting spans to the collector. Spans that fail to send are kept for the next export.
+// Returns the number of spans sent.
+func (t *tracer) export() (int, error) {
+	t.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.Unlock()
+
+	if len(spans) == 0 {
+		return 0, nil
+	}
+
+	if err := t.send(spans); err != nil {
+		t.Lock()
+		pending := t.spans
+		t.spans = spans // The failed spans are older than the ones added while they were sent.
+		t.keep(pending...)
+		t.Unlock()
+
+		return len(spans), err
+	}
+
+	return len(spans), nil
+}
+
+func (t *tracer) send(spans []*span) error {
+	body, err := json.Marshal(t.otlp(spans))
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.config.Timeout.Duration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for key, value := range t.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, kilobyte))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s: %s", ErrTraceExport, resp.Status, bytes.TrimSpace(reply))
+	}
+
+	return nil
+}
+
+/* The OTLP/HTTP JSON encoding. Only the fields Unpackerr uses are included. */
+
+type otlpTraces struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []*otlpAttr `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID      string      `json:"traceId"`
+	SpanID       string      `json:"spanId"`
+	ParentSpanID string      `json:"parentSpanId,omitempty"`
+	Name         string      `json:"name"`
+	Kind         int         `json:"kind"`
+	Start        string      `json:"startTimeUnixNano"`
+	End          string      `json:"endTimeUnixNano"`
+	Attributes   []*otlpAttr `json:"attributes,omitempty"`
+	Status       otlpStatus  `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	String *string  `json:"stringValue,omitempty"`
+	Int    *string  `json:"intValue,omitempty"` // 64 bit integers are strings in OTLP JSON.
+	Bool   *bool    `json:"boolValue,omitempty"`
+	Double *float64 `json:"doubleValue,omitempty"`
+}
+
+func (t *tracer) otlp(spans []*span) *otlpTraces {
+	hostname, _ := os.Hostname()
+	resource := otlpAttrs([]slog.Attr{
+		slog.String("service.name", t.config.Service),
+		slog.String("service.version", version.Version),
+		slog.String("host.name", hostname),
+		slog.String("os.type", runtime.GOOS),
+	})
+
+	scope := &otlpScopeSpans{Scope: otlpScope{Name: defaultTraceService, Version: version.Version}}
+	for _, finished := range spans {
+		scope.Spans = append(scope.Spans, finished.otlp())
+	}
+
+	return &otlpTraces{ResourceSpans: []*otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: resource},
+		ScopeSpans: []*otlpScopeSpans{scope},
+	}}}
+}
+
+func (s *span) otlp() *otlpSpan {
+	encoded := &otlpSpan{
+		TraceID:    hex.EncodeToString(s.traceID[:]),
+		SpanID:     hex.EncodeToString(s.spanID[:]),
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      strconv.FormatInt(s.start.UnixNano(), 10),
+		End:        strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes: otlpAttrs(s.attrs),
+	}
+
+	if s.parentID != [8]byte{} {
+		encoded.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+
+	if s.err != "" {
+		encoded.Status = otlpStatus{Code: spanStatusError, Message: s.err}
+	}
+
+	return encoded
+}
+
+func otlpAttrs(attrs []slog.Attr) []*otlpAttr {
+	encoded := make([]*otlpAttr, 0, len(attrs))
+
+	for _, attr := range attrs {
+		value := otlpValue{}
+
+		switch attr.Value.Kind() { //nolint:exhaustive // Everything else is a string.
+		case slog.KindInt64:
+			str := strconv.FormatInt(attr.Value.Int64(), 10)
+			value.Int = &str
+		case slog.KindBool:
+			boolean := attr.Value.Bool()
+			value.Bool = &boolean
+		case slog.KindFloat64:
+			double := attr.Value.Float64()
+			value.Double = &double
+		case slog.KindDuration:
+			str := strconv.FormatInt(attr.Value.Duration().Nanoseconds(), 10)
+			value.Int = &str
+		default:
+			str := attr.Value.String()
+			value.String = &str
+		}
+
+		encoded = append(encoded, &otlpAttr{Key: attr.Key, Value: value})
+	}
+
+	return encoded
+}
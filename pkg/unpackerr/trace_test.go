@@ -0,0 +1,164 @@
+package unpackerr
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golift.io/cnfg"
+	"golift.io/starr"
+	"golift.io/xtractr"
+)
+
+func TestTraceExport(t *testing.T) {
+	t.Parallel()
+
+	// A stand-in for an OTLP collector.
+	received := make(chan *otlpTraces, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v1/traces" || req.Header.Get("Content-Type") != "application/json" ||
+			req.Header.Get("Authorization") != "Bearer token" {
+			http.Error(resp, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var traces otlpTraces
+		if err := json.NewDecoder(req.Body).Decode(&traces); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		received <- &traces
+	}))
+	defer collector.Close()
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{
+		Config: &Config{Tracing: &TracingConfig{URL: collector.URL + "/v1/traces",
+			Headers: map[string]string{"Authorization": "Bearer token"}}, Timeout: cnfg.Duration{Duration: time.Second}},
+		Logger: &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+
+	if err := unpackerr.validateTracing(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	unpackerr.tracer = newTracer(unpackerr.Tracing)
+	start := time.Now().Add(-time.Hour)
+	item := &Extract{App: starr.Sonarr, URL: "http://sonarr", Path: "/downloads/Show", Status: WAITING}
+
+	unpackerr.tracer.saveFetch(starr.Sonarr, "http://sonarr", start, nil)
+	unpackerr.traceItem("Show", item, start)
+	item.trace.queued(start, start.Add(time.Minute))
+	item.trace.extracting(&xtractr.Response{Started: start.Add(time.Minute)})
+	item.trace.nextArchive("/downloads/Show/show.rar", 1, start.Add(time.Minute))
+
+	resp := &xtractr.Response{Error: errors.New("corrupt"), Started: start.Add(time.Minute), Elapsed: time.Minute}
+	item.trace.extracted(resp, start.Add(2*time.Minute))
+	newPayload(item).span.child("webhook", spanKindClient, time.Now()).finish(time.Now(), nil)
+	item.span().child("delete", spanKindInternal, time.Now()).finish(time.Now(), nil)
+
+	item.Status, item.Resp = EXTRACTFAILED, resp
+	unpackerr.finishTrace(item, time.Now())
+
+	if item.trace != nil {
+		t.Fatal("expected the item's trace to be removed")
+	}
+
+	unpackerr.exportTraces()
+
+	spans := (<-received).ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 7 {
+		t.Fatalf("expected 7 spans, got: %d", len(spans))
+	}
+
+	byName := map[string]*otlpSpan{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+
+	root := byName["item"]
+	if root == nil || root.ParentSpanID != "" || root.Status.Code != spanStatusError {
+		t.Fatalf("expected a failed root span without a parent: %+v", root)
+	}
+
+	for _, span := range spans {
+		if span.TraceID != root.TraceID {
+			t.Fatalf("expected every span in the item's trace, %s is not", span.Name)
+		}
+	}
+
+	for child, parent := range map[string]string{
+		"starr.queue": "item", "start_delay": "item", "extract": "item",
+		"archive": "extract", "webhook": "item", "delete": "item",
+	} {
+		if byName[child] == nil || byName[child].ParentSpanID != byName[parent].SpanID {
+			t.Fatalf("expected %s to be a child of %s", child, parent)
+		}
+	}
+
+	if unpackerr.tracer.export(); len(unpackerr.tracer.spans) != 0 {
+		t.Fatal("expected the exported spans to be removed")
+	}
+}
+
+func TestTraceDisabled(t *testing.T) {
+	t.Parallel()
+
+	unpackerr := &Unpackerr{Config: &Config{}}
+	item := &Extract{}
+
+	// None of these may panic without a tracer.
+	unpackerr.traceItem("Show", item, time.Now())
+	item.trace.queued(time.Now(), time.Now())
+	item.trace.extracting(&xtractr.Response{})
+	item.trace.nextArchive("show.rar", 1, time.Now())
+	item.span().child("delete", spanKindInternal, time.Now()).finish(time.Now(), nil)
+	unpackerr.finishTrace(item, time.Now())
+	unpackerr.exportTraces()
+
+	if item.trace != nil || unpackerr.Tracing.Enabled() {
+		t.Fatal("expected no trace without an OTLP url")
+	}
+}
+
+func TestTraceExportFailure(t *testing.T) {
+	t.Parallel()
+
+	collector := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, _ *http.Request) {
+		http.Error(resp, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	tracer := newTracer(&TracingConfig{URL: collector.URL, Timeout: cnfg.Duration{Duration: time.Second}})
+	tracer.start("item", spanKindInternal, time.Now()).finish(time.Now(), nil)
+
+	if count, err := tracer.export(); count != 1 || !errors.Is(err, ErrTraceExport) {
+		t.Fatalf("expected an export error for 1 span, got: %d, %v", count, err)
+	}
+
+	if len(tracer.spans) != 1 {
+		t.Fatal("expected the failed span to be kept for the next export")
+	}
+
+	for range traceBufferSize {
+		tracer.start("item", spanKindInternal, time.Now()).finish(time.Now(), nil)
+	}
+
+	if _, err := tracer.export(); err == nil || len(tracer.spans) != traceBufferSize {
+		t.Fatalf("expected the buffer to stay at %d spans after a failed export, got %d", traceBufferSize, len(tracer.spans))
+	}
+
+	if dropped := tracer.takeDropped(); dropped != 1 {
+		t.Fatalf("expected the oldest span to be dropped, got %d", dropped)
+	}
+
+	if tracer.takeDropped() != 0 {
+		t.Fatal("expected the dropped count to reset after it's read")
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/Unpackerr/unpackerr/pkg/bindata"
 	"github.com/Unpackerr/unpackerr/pkg/ui"
@@ -68,11 +70,7 @@ func (u *Unpackerr) makeChannels() {
 	u.menu["logs_view"] = ui.WrapMenu(logs.AddSubMenuItem("View", "view the application log"))
 	u.menu["logs_rotate"] = ui.WrapMenu(logs.AddSubMenuItem("Rotate", "rotate log file"))
 
-	if u.Config.Debug {
-		debug := systray.AddMenuItem("Debug", "Debug Menu")
-		u.menu["debug"] = ui.WrapMenu(debug)
-		u.menu["debug_panic"] = ui.WrapMenu(debug.AddSubMenuItem("Panic", "cause an application panic"))
-	}
+	u.makeDebugChannels()
 
 	// top level
 	u.makeStatsChannels()
@@ -81,23 +79,88 @@ func (u *Unpackerr) makeChannels() {
 	u.menu["exit"] = ui.WrapMenu(systray.AddMenuItem("Quit", "Exit Unpackerr"))
 }
 
+func (u *Unpackerr) makeDebugChannels() {
+	debug := systray.AddMenuItem("Debug", "Debug Menu")
+	u.menu["debug"] = ui.WrapMenu(debug)
+	u.menu["debug_logs"] = ui.WrapMenu(
+		debug.AddSubMenuItemCheckbox("Debug Logging", "turn debug logging on and off", false))
+	u.menu["debug_timed"] = ui.WrapMenu(debug.AddSubMenuItem("Debug 15 Minutes", "turn on debug logging for 15 minutes"))
+
+	for _, subsystem := range logSubsystems() {
+		u.menu[debugSub+subsystem] = ui.WrapMenu(debug.AddSubMenuItemCheckbox("Debug "+subsystem,
+			"turn debug logging for "+subsystem+" on and off", false))
+	}
+
+	if u.Config.Debug {
+		u.menu["debug_panic"] = ui.WrapMenu(debug.AddSubMenuItem("Panic", "cause an application panic"))
+	}
+
+	u.updateDebugMenu()
+}
+
 func (u *Unpackerr) watchDebugChannels() {
-	if !u.Config.Debug {
-		return
+	for _, subsystem := range logSubsystems() {
+		go func() {
+			for range u.menu[debugSub+subsystem].Clicked() {
+				levels := u.levels.get()
+				if idx := slices.Index(levels.Subsystems, subsystem); idx == -1 {
+					levels.Subsystems = append(levels.Subsystems, subsystem)
+				} else {
+					levels.Subsystems = slices.Delete(levels.Subsystems, idx, idx+1)
+				}
+
+				u.setLogLevels(&LogLevels{Debug: levels.Debug, Subsystems: levels.Subsystems}, 0, "Tray")
+			}
+		}()
+	}
+
+	var panicked chan struct{} // nil unless debug is enabled in the config.
+	if u.Config.Debug {
+		panicked = u.menu["debug_panic"].Clicked()
 	}
 
 	for {
 		select {
 		case <-u.menu["debug"].Clicked():
-			// turn on and off debug?
-			// u.menu["debug"].Check()
-		case <-u.menu["debug_panic"].Clicked():
+			// does nothing on purpose
+		case <-u.menu["debug_logs"].Clicked():
+			levels := u.levels.get()
+			u.setLogLevels(&LogLevels{Debug: !levels.Debug, Subsystems: levels.Subsystems}, 0, "Tray")
+		case <-u.menu["debug_timed"].Clicked():
+			u.setLogLevels(&LogLevels{Debug: true, Subsystems: u.levels.get().Subsystems}, debugTimed, "Tray")
+		case <-u.levels.changed:
+			u.updateDebugMenu()
+		case <-panicked:
 			u.Printf("User Requested Application Panic, good bye.")
 			panic("user requested panic")
 		}
 	}
 }
 
+// updateDebugMenu sets the debug check boxes from the runtime log levels.
+func (u *Unpackerr) updateDebugMenu() {
+	levels := u.levels.get()
+	check := func(item ui.MenuItem, checked bool) {
+		if checked {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+
+	check(u.menu["debug_logs"], levels.Debug)
+
+	for _, subsystem := range logSubsystems() {
+		check(u.menu[debugSub+subsystem], slices.Contains(levels.Subsystems, subsystem))
+	}
+
+	if levels.Until.IsZero() {
+		u.menu["debug_timed"].SetTitle("Debug 15 Minutes")
+	} else {
+		u.menu["debug_timed"].SetTitle("Debug Until " + levels.Until.Format(time.Kitchen))
+	}
+}
+
 func (u *Unpackerr) watchGuiChannels() {
 	for {
 		select {
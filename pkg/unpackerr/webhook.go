@@ -8,49 +8,77 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"golift.io/cnfg"
 	"golift.io/starr"
 	"golift.io/version"
+	"golift.io/xtractr"
 )
 
 // WebhookConfig defines the data to send webhooks to a server.
 type WebhookConfig struct {
-	Name       string          `json:"name"         toml:"name"          xml:"name"                    yaml:"name"`
-	URL        string          `json:"url"          toml:"url"           xml:"url,omitempty"           yaml:"url"`
-	Command    string          `json:"command"      toml:"command"       xml:"command,omitempty"       yaml:"command"`
-	CType      string          `json:"contentType"  toml:"content_type"  xml:"content_type,omitempty"  yaml:"contentType"`
-	TmplPath   string          `json:"templatePath" toml:"template_path" xml:"template_path,omitempty" yaml:"templatePath"`
-	TempName   string          `json:"template"     toml:"template"      xml:"template,omitempty"      yaml:"template"`
-	Timeout    cnfg.Duration   `json:"timeout"      toml:"timeout"       xml:"timeout"                 yaml:"timeout"`
-	Shell      bool            `json:"shell"        toml:"shell"         xml:"shell"                   yaml:"shell"`
-	IgnoreSSL  bool            `json:"ignoreSsl"    toml:"ignore_ssl"    xml:"ignore_ssl,omitempty"    yaml:"ignoreSsl"`
-	Silent     bool            `json:"silent"       toml:"silent"        xml:"silent"                  yaml:"silent"`
-	Events     ExtractStatuses `json:"events"       toml:"events"        xml:"events"                  yaml:"events"`
-	Exclude    StringSlice     `json:"exclude"      toml:"exclude"       xml:"exclude"                 yaml:"exclude"`
-	Nickname   string          `json:"nickname"     toml:"nickname"      xml:"nickname,omitempty"      yaml:"nickname"`
-	Token      string          `json:"token"        toml:"token"         xml:"token,omitempty"         yaml:"token"`
-	Channel    string          `json:"channel"      toml:"channel"       xml:"channel,omitempty"       yaml:"channel"`
-	client     *http.Client
-	fails      uint
-	posts      uint
-	sync.Mutex `json:"-" toml:"-" xml:"-" yaml:"-"`
-}
-
-type hookQueueItem struct {
-	*WebhookConfig
-	*WebhookPayload
+	Name         string            `json:"name"               toml:"name"                 xml:"name"                           yaml:"name"`
+	URL          string            `json:"url"                toml:"url"                  xml:"url,omitempty"                  yaml:"url"`
+	Command      string            `json:"command"            toml:"command"              xml:"command,omitempty"              yaml:"command"`
+	CType        string            `json:"contentType"        toml:"content_type"         xml:"content_type,omitempty"         yaml:"contentType"`
+	TmplPath     string            `json:"templatePath"       toml:"template_path"        xml:"template_path,omitempty"        yaml:"templatePath"`
+	TempName     string            `json:"template"           toml:"template"             xml:"template,omitempty"             yaml:"template"`
+	Timeout      cnfg.Duration     `json:"timeout"            toml:"timeout"              xml:"timeout"                        yaml:"timeout"`
+	Shell        bool              `json:"shell"              toml:"shell"                xml:"shell"                          yaml:"shell"`
+	IgnoreSSL    bool              `json:"ignoreSsl"          toml:"ignore_ssl"           xml:"ignore_ssl,omitempty"           yaml:"ignoreSsl"`
+	Silent       bool              `json:"silent"             toml:"silent"               xml:"silent"                         yaml:"silent"`
+	Events       ExtractStatuses   `json:"events"             toml:"events"               xml:"events"                         yaml:"events"`
+	Exclude      StringSlice       `json:"exclude"            toml:"exclude"              xml:"exclude"                        yaml:"exclude"`
+	Nickname     string            `json:"nickname"           toml:"nickname"             xml:"nickname,omitempty"             yaml:"nickname"`
+	Token        string            `json:"token"              toml:"token"                xml:"token,omitempty"                yaml:"token"`
+	Channel      string            `json:"channel"            toml:"channel"              xml:"channel,omitempty"              yaml:"channel"`
+	Retries      uint              `json:"retries"            toml:"retries"              xml:"retries"                        yaml:"retries"`
+	RetryDelay   cnfg.Duration     `json:"retryDelay"         toml:"retry_delay"          xml:"retry_delay"                    yaml:"retryDelay"`
+	Outbox       string            `json:"outbox"             toml:"outbox"               xml:"outbox,omitempty"               yaml:"outbox"`
+	OutboxMax    int               `json:"outboxMax"          toml:"outbox_max"           xml:"outbox_max"                     yaml:"outboxMax"`
+	QueueSize    uint              `json:"queueSize"          toml:"queue_size"           xml:"queue_size"                     yaml:"queueSize"`
+	Headers      map[string]string `json:"headers"            toml:"headers"              xml:"headers"                        yaml:"headers"`
+	Username     string            `json:"username"           toml:"username"             xml:"username,omitempty"             yaml:"username"`
+	Password     string            `json:"password"           toml:"password"             xml:"password,omitempty"             yaml:"password"`
+	Secret       string            `json:"secret"             toml:"secret"               xml:"secret,omitempty"               yaml:"secret"`
+	Method       string            `json:"method"             toml:"method"               xml:"method,omitempty"               yaml:"method"`
+	Digest       cnfg.Duration     `json:"digest"             toml:"digest"               xml:"digest"                         yaml:"digest"`
+	DigestPath   string            `json:"digestTemplatePath" toml:"digest_template_path" xml:"digest_template_path,omitempty" yaml:"digestTemplatePath"`
+	RateLimit    uint              `json:"rateLimit"          toml:"rate_limit"           xml:"rate_limit"                     yaml:"rateLimit"`
+	Workdir      string            `json:"workdir"            toml:"workdir"              xml:"workdir,omitempty"              yaml:"workdir"`
+	Env          map[string]string `json:"env"                toml:"env"                  xml:"env"                            yaml:"env"`
+	Gate         string            `json:"gate"               toml:"gate"                 xml:"gate,omitempty"                 yaml:"gate"`
+	GateSkip     bool              `json:"gateSkip"           toml:"gate_skip"            xml:"gate_skip"                      yaml:"gateSkip"`
+	FilterURLs   StringSlice       `json:"filterUrls"         toml:"filter_urls"          xml:"filter_urls"                    yaml:"filterUrls"`
+	FilterApps   StringSlice       `json:"filterApps"         toml:"filter_apps"          xml:"filter_apps"                    yaml:"filterApps"`
+	FilterPaths  StringSlice       `json:"filterPaths"        toml:"filter_paths"         xml:"filter_paths"                   yaml:"filterPaths"`
+	ExcludePaths StringSlice       `json:"excludePaths"       toml:"exclude_paths"        xml:"exclude_paths"                  yaml:"excludePaths"`
+	FilterErrors StringSlice       `json:"filterErrors"       toml:"filter_errors"        xml:"filter_errors"                  yaml:"filterErrors"`
+	MinSize      ByteSize          `json:"minSize"            toml:"min_size"             xml:"min_size"                       yaml:"minSize"`
+	MaxSize      ByteSize          `json:"maxSize"            toml:"max_size"             xml:"max_size"                       yaml:"maxSize"`
+	client       *http.Client
+	outbox       *webhookOutbox
+	queue        chan *WebhookPayload
+	urlTmpl      *template.Template
+	args         []*template.Template
+	fails        uint
+	posts        uint
+	dropped      uint
+	sync.Mutex   `json:"-" toml:"-" xml:"-" yaml:"-"`
 }
 
 // Errors produced by this file.
 var (
 	ErrInvalidStatus = errors.New("invalid HTTP status reply")
 	ErrWebhookNoURL  = errors.New("webhook without a URL configured; fix it")
+	ErrInvalidMethod = errors.New("invalid webhook method, must be one of: GET, POST, PUT, PATCH, DELETE, HEAD")
 )
 
 // ExtractStatuses allows us to create a custom environment variable unmarshaller.
@@ -88,19 +116,47 @@ func (statuses *ExtractStatuses) MarshalENV(tag string) (map[string]string, erro
 	return map[string]string{tag: strings.Join(vals, ",")}, nil
 }
 
-// runAllHooks sends webhooks and executes command hooks.
+// runAllHooks sends webhooks, emails and MQTT messages, and executes command hooks.
 func (u *Unpackerr) runAllHooks(item *Extract) {
 	if item.Status == IMPORTED && item.App == FolderString {
 		return // This is an internal state change we don't need to fire on.
 	}
 
+	payload := newPayload(item)
+
+	for _, hook := range u.Webhook {
+		if hook.Wants(payload) {
+			u.queueHook(hook, payload)
+		}
+	}
+
+	for _, hook := range u.Cmdhook {
+		if hook.Gate == "" && hook.Wants(payload) {
+			u.queueHook(hook, payload)
+		}
+	}
+
+	for _, email := range u.Email {
+		if email.HasEvent(item.Status) && !email.Excluded(item.App) {
+			u.queueEmail(email, payload)
+		}
+	}
+
+	u.mqttEvent(payload)
+}
+
+// newPayload creates a hook payload from an item's current state.
+func newPayload(item *Extract) *WebhookPayload {
 	payload := &WebhookPayload{
-		Path:  item.Path,
-		App:   item.App,
-		IDs:   item.IDs,
-		Time:  item.Updated,
-		Data:  nil,
-		Event: item.Status,
+		Path:   item.Path,
+		App:    item.App,
+		URL:    item.URL,
+		IDs:    item.IDs,
+		Time:   item.Updated,
+		Data:   nil,
+		Event:  item.Status,
+		Report: item.summary,
+		span:   item.span(),
 		// Application Metadata.
 		Go:       runtime.Version(),
 		OS:       runtime.GOOS,
@@ -112,42 +168,50 @@ func (u *Unpackerr) runAllHooks(item *Extract) {
 	}
 
 	if item.Status <= EXTRACTED && item.Resp != nil {
-		payload.Data = &XtractPayload{
-			Files:   item.Resp.NewFiles,
-			File:    item.Resp.NewFiles,
-			Start:   item.Resp.Started,
-			Output:  item.Resp.Output,
-			Bytes:   item.Resp.Size,
-			Queue:   item.Resp.Queued,
-			Elapsed: cnfg.Duration{Duration: item.Resp.Elapsed},
-		}
+		payload.Data = newXtractPayload(item.Resp)
+		payload.Data.Password = item.Password
+		payload.Data.PassFrom = item.PassSource
+	}
 
-		for _, v := range item.Resp.Archives {
-			payload.Data.Archives = append(payload.Data.Archives, v...)
-			payload.Data.Archive = append(payload.Data.Archive, v...)
-		}
+	if item.Status == EXTRACTSKIPPED {
+		// Existing templates already print the error, so the skip reason goes there.
+		payload.Data = &XtractPayload{Error: item.Skipped}
+	}
 
-		for _, v := range item.Resp.Extras {
-			payload.Data.Archives = append(payload.Data.Archives, v...)
-			payload.Data.Archive = append(payload.Data.Archive, v...)
-		}
+	if payload.Data != nil {
+		payload.Data.HookOutput = item.HookOutput
+	}
 
-		if item.Resp.Error != nil {
-			payload.Data.Error = item.Resp.Error.Error()
-		}
+	return payload
+}
+
+// newXtractPayload creates the extraction data for a hook payload from an xtractr response.
+func newXtractPayload(resp *xtractr.Response) *XtractPayload {
+	data := &XtractPayload{
+		Files:   resp.NewFiles,
+		File:    resp.NewFiles,
+		Start:   resp.Started,
+		Output:  resp.Output,
+		Bytes:   resp.Size,
+		Queue:   resp.Queued,
+		Elapsed: cnfg.Duration{Duration: resp.Elapsed},
 	}
 
-	for _, hook := range u.Webhook {
-		if hook.HasEvent(item.Status) && !hook.Excluded(item.App) {
-			u.hookChan <- &hookQueueItem{WebhookConfig: hook, WebhookPayload: payload}
-		}
+	for _, v := range resp.Archives {
+		data.Archives = append(data.Archives, v...)
+		data.Archive = append(data.Archive, v...)
 	}
 
-	for _, hook := range u.Cmdhook {
-		if hook.HasEvent(item.Status) && !hook.Excluded(item.App) {
-			u.hookChan <- &hookQueueItem{WebhookConfig: hook, WebhookPayload: payload}
-		}
+	for _, v := range resp.Extras {
+		data.Archives = append(data.Archives, v...)
+		data.Archive = append(data.Archive, v...)
+	}
+
+	if resp.Error != nil {
+		data.Error = resp.Error.Error()
 	}
+
+	return data
 }
 
 func (u *Unpackerr) sendWebhookWithLog(hook *WebhookConfig, payload *WebhookPayload) {
@@ -161,21 +225,46 @@ func (u *Unpackerr) sendWebhookWithLog(hook *WebhookConfig, payload *WebhookPayl
 		return
 	}
 
-	bodyStr := body.String()
+	url, err := hook.PayloadURL(payload)
+	if err != nil {
+		u.Errorf("Webhook URL (%s = %s): %s: %v", payload.Path, payload.Event, hook.Name, err)
+		return
+	}
+
+	u.deliverWebhook(hook, payload, url, body.String())
+}
+
+// deliverWebhook sends a rendered webhook body with retries, and saves it to the outbox when it fails.
+func (u *Unpackerr) deliverWebhook(hook *WebhookConfig, payload *WebhookPayload, url, bodyStr string) {
+	if hook.outbox.Len() > 0 && !u.flushOutbox(hook) {
+		// Older payloads are still waiting; keep this one behind them so they arrive in order.
+		u.saveToOutbox(hook, payload, url, bodyStr)
+		return
+	}
 
-	if reply, err := hook.Send(&body); err != nil {
-		u.Debugf("Webhook Payload: %s", bodyStr)
+	if reply, err := u.sendWebhookWithRetries(hook, payload, url, bodyStr); err != nil {
+		u.debugf(logHooks, "Webhook Payload: %s", bodyStr)
 		u.Errorf("Webhook (%s = %s): %s: %v", payload.Path, payload.Event, hook.Name, err)
-		u.Debugf("Webhook Response: %s", string(reply))
+		u.debugf(logHooks, "Webhook Response: %s", string(reply))
+
+		if hook.outbox != nil {
+			u.saveToOutbox(hook, payload, url, bodyStr)
+		}
 	} else if !hook.Silent {
-		u.Debugf("Webhook Payload: %s", bodyStr)
+		u.debugf(logHooks, "Webhook Payload: %s", bodyStr)
 		u.Printf("[Webhook] Posted Payload (%s = %s): %s: OK", payload.Path, payload.Event, hook.Name)
 	}
 }
 
 // Send marshals an any into json and POSTs it to a URL.
 func (w *WebhookConfig) Send(body io.Reader) ([]byte, error) {
-	if w.URL == "" {
+	return w.SendTo(w.URL, body)
+}
+
+// SendTo sends a request to a URL rendered from the webhook's URL template.
+// The request uses the webhook's method; GET and HEAD requests do not include the body.
+func (w *WebhookConfig) SendTo(url string, body io.Reader) ([]byte, error) {
+	if url == "" {
 		return nil, ErrWebhookNoURL
 	}
 
@@ -187,7 +276,7 @@ func (w *WebhookConfig) Send(body io.Reader) ([]byte, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), w.Timeout.Duration+time.Second)
 	defer cancel()
 
-	resp, err := w.send(ctx, body)
+	resp, err := w.send(ctx, url, body)
 	if err != nil {
 		w.fails++
 	}
@@ -195,17 +284,26 @@ func (w *WebhookConfig) Send(body io.Reader) ([]byte, error) {
 	return resp, err
 }
 
-func (w *WebhookConfig) send(ctx context.Context, body io.Reader) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, body)
+func (w *WebhookConfig) send(ctx context.Context, url string, body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("reading payload: %w", err)
+	}
+
+	if w.Method == http.MethodGet || w.Method == http.MethodHead {
+		data = nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, w.method(), url, bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", w.CType)
+	w.setHeaders(req, data, time.Now())
 
 	res, err := w.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("POSTing payload: %w", err)
+		return nil, fmt.Errorf("%sing payload: %w", w.method(), err)
 	}
 	defer res.Body.Close()
 
@@ -220,6 +318,56 @@ func (w *WebhookConfig) send(ctx context.Context, body io.Reader) ([]byte, error
 	return reply, nil
 }
 
+// method returns the HTTP method for a webhook; POST is the default.
+func (w *WebhookConfig) method() string {
+	if w.Method == "" {
+		return http.MethodPost
+	}
+
+	return w.Method
+}
+
+// validateURL checks the webhook method, and parses the URL as a template if it has one.
+func (w *WebhookConfig) validateURL() error {
+	switch w.Method = strings.ToUpper(strings.TrimSpace(w.Method)); w.Method {
+	case "":
+		w.Method = http.MethodPost
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead:
+	default:
+		return fmt.Errorf("%w: %s: %s", ErrInvalidMethod, w.Name, w.Method)
+	}
+
+	if !strings.Contains(w.URL, "{{") {
+		return nil
+	}
+
+	var err error
+	if w.urlTmpl, err = template.New("url").Funcs(w.templateFuncs()).Parse(w.URL); err != nil {
+		return fmt.Errorf("webhook %s: url template: %w", w.Name, err)
+	}
+
+	return nil
+}
+
+// PayloadURL returns the URL for a payload. URL templates are rendered with the payload.
+// Values are not escaped; templates escape them with the pathescape and urlquery functions.
+func (w *WebhookConfig) PayloadURL(payload *WebhookPayload) (string, error) {
+	if w.urlTmpl == nil {
+		return w.URL, nil
+	}
+
+	var rendered strings.Builder
+	if err := w.urlTmpl.Execute(&rendered, payload); err != nil {
+		return "", fmt.Errorf("executing url template: %w", err)
+	}
+
+	if _, err := url.Parse(rendered.String()); err != nil {
+		return "", fmt.Errorf("url template made an invalid url (escape values with pathescape or urlquery): %w", err)
+	}
+
+	return rendered.String(), nil
+}
+
 func (u *Unpackerr) validateWebhook() error { //nolint:cyclop
 	for idx := range u.Webhook {
 		u.Webhook[idx].Command = ""
@@ -252,6 +400,20 @@ func (u *Unpackerr) validateWebhook() error { //nolint:cyclop
 			u.Webhook[idx].Events = []ExtractStatus{WAITING}
 		}
 
+		if err := u.Webhook[idx].validateHeaders(); err != nil {
+			return err
+		}
+
+		if err := u.Webhook[idx].validateURL(); err != nil {
+			return err
+		}
+
+		if err := u.Webhook[idx].validateFilters(); err != nil {
+			return err
+		}
+
+		u.Webhook[idx].setupQueue()
+
 		if u.Webhook[idx].client == nil {
 			u.Webhook[idx].client = &http.Client{
 				Timeout: u.Webhook[idx].Timeout.Duration,
@@ -262,7 +424,7 @@ func (u *Unpackerr) validateWebhook() error { //nolint:cyclop
 		}
 	}
 
-	return nil
+	return u.validateOutboxes()
 }
 
 func (u *Unpackerr) logWebhook() {
@@ -292,8 +454,40 @@ func (u *Unpackerr) logWebhook() {
 			vars += ", exclude: \"" + strings.Join(hook.Exclude, "; ") + `"`
 		}
 
-		u.Printf("%s: %s, timeout: %v, ignore ssl: %v, silent: %v%s, events: %q",
-			prefix, hook.Name, hook.Timeout, hook.IgnoreSSL, hook.Silent, vars, logEvents(hook.Events))
+		if hook.Retries > 0 {
+			vars += fmt.Sprintf(", retries: %d, retry_delay: %v", hook.Retries, hook.RetryDelay)
+		}
+
+		if hook.outbox != nil {
+			vars += fmt.Sprintf(", outbox: %s (%d/%d waiting)", hook.outbox.path, hook.outbox.Len(), hook.OutboxMax)
+		}
+
+		if len(hook.Headers) > 0 {
+			vars += fmt.Sprintf(", headers: %d", len(hook.Headers))
+		}
+
+		if hook.Username != "" || hook.Password != "" {
+			vars += ", basic auth: " + hook.Username
+		}
+
+		if hook.Secret != "" {
+			vars += ", signed: true"
+		}
+
+		if hook.Digest.Duration > 0 {
+			vars += fmt.Sprintf(", digest: %v", hook.Digest)
+		}
+
+		if hook.RateLimit > 0 {
+			vars += fmt.Sprintf(", rate_limit: %d/min", hook.RateLimit)
+		}
+
+		if hook.hasFilters() {
+			vars += ", filters: " + hook.logFilters()
+		}
+
+		u.Printf("%s: %s, method: %s, timeout: %v, ignore ssl: %v, silent: %v, queue: %d%s, events: %q", prefix,
+			hook.Name, hook.method(), hook.Timeout, hook.IgnoreSSL, hook.Silent, hook.QueueSize, vars, logEvents(hook.Events))
 	}
 }
 
@@ -318,7 +512,18 @@ func logEvents(events []ExtractStatus) string {
 
 // Excluded returns true if an app is in the Exclude slice.
 func (w *WebhookConfig) Excluded(app starr.App) bool {
-	for _, exclude := range w.Exclude {
+	return w.Exclude.HasApp(app)
+}
+
+// HasEvent returns true if a status event is in the Events slice.
+// Also returns true if the Events slice has only one value of WAITING.
+func (w *WebhookConfig) HasEvent(e ExtractStatus) bool {
+	return w.Events.Has(e)
+}
+
+// HasApp returns true if an app is in the slice. Used for hook exclusions.
+func (slice StringSlice) HasApp(app starr.App) bool {
+	for _, exclude := range slice {
 		if strings.EqualFold(exclude, string(app)) {
 			return true
 		}
@@ -327,11 +532,11 @@ func (w *WebhookConfig) Excluded(app starr.App) bool {
 	return false
 }
 
-// HasEvent returns true if a status event is in the Events slice.
-// Also returns true if the Events slice has only one value of WAITING.
-func (w *WebhookConfig) HasEvent(e ExtractStatus) bool {
-	for _, status := range w.Events {
-		if (status == WAITING && len(w.Events) == 1) || status == e {
+// Has returns true if a status event is in the slice.
+// Also returns true if the slice has only one value of WAITING (all events).
+func (statuses ExtractStatuses) Has(e ExtractStatus) bool {
+	for _, status := range statuses {
+		if (status == WAITING && len(statuses) == 1) || status == e {
 			return true
 		}
 	}
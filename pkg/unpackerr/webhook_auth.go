@@ -0,0 +1,66 @@
+package unpackerr
+
+/* Custom headers, basic auth and payload signatures for outbound webhooks. */
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook signature headers. Receivers compute the HMAC-SHA256 of "<timestamp>.<body>" with the
+// shared secret, compare it to the signature, and reject requests with an old timestamp.
+const (
+	SignatureHeader = "X-Unpackerr-Signature"
+	TimestampHeader = "X-Unpackerr-Timestamp"
+	signaturePrefix = "sha256="
+)
+
+// ErrInvalidHeader is returned when a webhook has a header without a name.
+var ErrInvalidHeader = errors.New("webhook header has an invalid name")
+
+// validateHeaders makes sure every custom header can be sent.
+func (w *WebhookConfig) validateHeaders() error {
+	for name := range w.Headers {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " :\r\n") {
+			return fmt.Errorf("%w: webhook %s: '%s'", ErrInvalidHeader, w.Name, name)
+		}
+	}
+
+	return nil
+}
+
+// setHeaders adds the content type, custom headers, basic auth and signature to a webhook request.
+// Custom headers may replace the content type; basic auth and the signature replace custom headers.
+func (w *WebhookConfig) setHeaders(req *http.Request, body []byte, now time.Time) {
+	req.Header.Set("Content-Type", w.CType)
+
+	for name, value := range w.Headers {
+		req.Header.Set(name, value)
+	}
+
+	if w.Username != "" || w.Password != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, signaturePrefix+SignPayload(w.Secret, timestamp, body))
+	}
+}
+
+// SignPayload returns the hex encoded HMAC-SHA256 of a timestamp and a webhook body.
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
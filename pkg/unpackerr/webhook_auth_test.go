@@ -0,0 +1,52 @@
+package unpackerr
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookHeaders(t *testing.T) {
+	t.Parallel()
+
+	hook := &WebhookConfig{
+		Name:     "signed",
+		CType:    "application/json",
+		Headers:  map[string]string{"X-Api-Key": "key", "Content-Type": "text/plain"},
+		Username: "user",
+		Password: "pass",
+		Secret:   "secret",
+	}
+
+	if err := hook.validateHeaders(); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	body := []byte(`{"event":"extracted"}`)
+	req := httptest.NewRequest(http.MethodPost, "http://localhost/hook", bytes.NewReader(body))
+	hook.setHeaders(req, body, time.Unix(1700000000, 0))
+
+	if req.Header.Get("X-Api-Key") != "key" || req.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("custom headers were not set: %v", req.Header)
+	}
+
+	if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Fatalf("basic auth was not set: %v", req.Header)
+	}
+
+	if timestamp := req.Header.Get(TimestampHeader); timestamp != "1700000000" {
+		t.Fatalf("unexpected timestamp: %s", timestamp)
+	}
+
+	// echo -n '1700000000.{"event":"extracted"}' | openssl dgst -sha256 -hmac secret
+	const expected = "sha256=f4491b0780e4cb4fb155403838a7d92d0de25e65d2267b41394c7a09701dc59e"
+	if signature := req.Header.Get(SignatureHeader); signature != expected {
+		t.Fatalf("unexpected signature: %s", signature)
+	}
+
+	if err := (&WebhookConfig{Headers: map[string]string{"Bad Header": "x"}}).validateHeaders(); err == nil {
+		t.Fatal("expected an error for a header name with a space")
+	}
+}
@@ -0,0 +1,365 @@
+package unpackerr
+
+/* Webhook digests and rate limits. A digest collects payloads and sends one summary message. */
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"golift.io/version"
+)
+
+const (
+	rateLimitWindow = time.Minute // rate_limit is the number of webhooks allowed in this window.
+	digestTitles    = 25          // Only this many titles are listed in a digest summary.
+)
+
+// WebhookDigest is the data for a digest template. It summarizes every payload collected during a digest interval.
+type WebhookDigest struct {
+	Start    time.Time         `json:"start"`    // Time of the first payload.
+	End      time.Time         `json:"end"`      // Time of the last payload.
+	Count    int               `json:"count"`    // Number of payloads.
+	Events   []*DigestEvent    `json:"events"`   // Payload count for each event, in event order.
+	Titles   []string          `json:"titles"`   // Unique titles (or folder names), in order.
+	Bytes    uint64            `json:"bytes"`    // Total bytes written.
+	Failed   int               `json:"failed"`   // Number of failed extractions and deletes.
+	Payloads []*WebhookPayload `json:"-"`        // Every payload in the digest.
+	Summary  string            `json:"summary"`  // Plain text summary of everything above.
+	Go       string            `json:"go"`       // Version of go compiled with
+	OS       string            `json:"os"`       // Operating system: linux, windows, darwin
+	Arch     string            `json:"arch"`     // Architecture: amd64, armhf
+	Version  string            `json:"version"`  // Application Version
+	Revision string            `json:"revision"` // Application Revision
+}
+
+// DigestEvent is the number of payloads for one event in a digest.
+type DigestEvent struct {
+	Event ExtractStatus `json:"event"`
+	Count int           `json:"count"`
+}
+
+// WebhookDigestTemplateJSON is the digest template for notifiarr, home assistant and custom templates.
+const WebhookDigestTemplateJSON = `{
+  "digest": true,
+  "name": {{encode name}},
+  "count": {{.Count}},
+  "failed": {{.Failed}},
+  "start": "{{timestamp .Start}}",
+  "end": "{{timestamp .End}}",
+  "events": { {{- $s := separator ", "}}{{range .Events}}{{call $s}}"{{.Event}}": {{.Count}}{{end -}} },
+  "titles": {{encode .Titles}},
+  "bytes": {{.Bytes}},
+  "summary": {{encode .Summary}},
+  "version": "{{.Version}}"
+}
+`
+
+// WebhookDigestTemplateDiscord is the digest template for discord.com.
+const WebhookDigestTemplateDiscord = `{
+  "username": "{{nickname}}",
+  "avatar_url": "https://raw.githubusercontent.com/wiki/Unpackerr/unpackerr/images/logo.png",
+  "embeds": [{
+    "title": "Unpackerr: {{.Count}} events",
+    "timestamp": "{{timestamp .End}}",
+    "color": {{if .Failed}}10038562{{else}}1752220{{end}},
+    "description": {{encode .Summary}},
+    "footer": {
+     "text": "v{{.Version}}-{{.Revision}} ({{.OS}}/{{.Arch}})",
+     "icon_url": "https://docs.golift.io/integrations/golift.png"
+    }
+  }]
+}
+`
+
+// WebhookDigestTemplateTelegram is the digest template for Telegram.
+const WebhookDigestTemplateTelegram = `{
+  "chat_id": "{{nickname}}",
+  "disable_web_page_preview": true,
+  "text": {{encode (printf "Unpackerr: %d events\n\n%s" .Count .Summary)}}
+}
+`
+
+// WebhookDigestTemplateSlack is the digest template for Slack.
+const WebhookDigestTemplateSlack = `{
+  "username": "{{nickname}}",
+  {{if channel}}"channel": "{{channel}}",{{end}}
+  "icon_url": "https://raw.githubusercontent.com/wiki/Unpackerr/unpackerr/images/logo.png",
+  "text": {{encode (printf "*Unpackerr: %d events*\n%s" .Count .Summary)}}
+}
+`
+
+// WebhookDigestTemplatePushover is the digest template for Pushover.
+const WebhookDigestTemplatePushover = `token={{token}}&user={{channel}}&` +
+	`title={{formencode (printf "Unpackerr: %d events" .Count)}}&` +
+	`{{if nickname}}device={{nickname}}&{{end}}message={{formencode .Summary}}`
+
+// WebhookDigestTemplateGotify is the digest template for Gotify.
+const WebhookDigestTemplateGotify = `{
+  "title": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Count}} events",
+  "message": {{encode .Summary}}
+}
+`
+
+// WebhookDigestTemplateNtfy is the digest template for ntfy.sh.
+const WebhookDigestTemplateNtfy = `{
+  "topic": {{encode channel}},
+  "title": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Count}} events",
+  "message": {{encode .Summary}},
+  "priority": {{if .Failed}}4{{else}}2{{end}},
+  "tags": ["{{if .Failed}}warning{{else}}package{{end}}", "digest"]
+}
+`
+
+// WebhookDigestTemplateMatrix is the digest template for Matrix.
+const WebhookDigestTemplateMatrix = `{
+  "msgtype": "m.notice",
+  "body": {{encode (printf "%s: %d events\n%s" (or nickname "Unpackerr") .Count .Summary)}}
+}
+`
+
+// WebhookDigestTemplateTeams is the digest template for Microsoft Teams.
+const WebhookDigestTemplateTeams = `{
+  "type": "message",
+  "attachments": [{
+    "contentType": "application/vnd.microsoft.card.adaptive",
+    "contentUrl": null,
+    "content": {
+      "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+      "type": "AdaptiveCard",
+      "version": "1.4",
+      "msteams": {"width": "Full"},
+      "body": [
+        {
+          "type": "TextBlock",
+          "size": "Medium",
+          "weight": "Bolder",
+          "color": "{{if .Failed}}Attention{{else}}Default{{end}}",
+          "text": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Count}} events"
+        },
+        {"type": "TextBlock", "text": {{encode .Summary}}, "wrap": true}
+      ]
+    }
+  }]
+}
+`
+
+// WebhookDigestTemplateApprise is the digest template for the Apprise API.
+const WebhookDigestTemplateApprise = `{
+  "title": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Count}} events",
+  "body": {{encode .Summary}},
+  "type": "{{if .Failed}}warning{{else}}info{{end}}"{{if channel}},
+  "tag": {{encode channel}}{{end}}
+}
+`
+
+// webhookDigestTemplates are the built-in digest templates, by webhook template name.
+//
+//nolint:gochecknoglobals
+var webhookDigestTemplates = map[string]string{
+	"notifiarr":     WebhookDigestTemplateJSON,
+	"discord":       WebhookDigestTemplateDiscord,
+	"telegram":      WebhookDigestTemplateTelegram,
+	"slack":         WebhookDigestTemplateSlack,
+	"pushover":      WebhookDigestTemplatePushover,
+	"gotify":        WebhookDigestTemplateGotify,
+	"ntfy":          WebhookDigestTemplateNtfy,
+	"matrix":        WebhookDigestTemplateMatrix,
+	"teams":         WebhookDigestTemplateTeams,
+	"apprise":       WebhookDigestTemplateApprise,
+	"homeassistant": WebhookDigestTemplateJSON,
+}
+
+// DigestTemplate returns the digest template for this webhook. It matches the webhook's
+// template, unless a digest template file is provided. Custom templates use the JSON digest.
+//
+//nolint:wrapcheck
+func (w *WebhookConfig) DigestTemplate() (*template.Template, error) {
+	template := template.New("digest").Funcs(w.templateFuncs())
+
+	if w.DigestPath == "" {
+		if digest := webhookDigestTemplates[w.templateName()]; digest != "" {
+			return template.Parse(digest)
+		}
+
+		return template.Parse(WebhookDigestTemplateJSON)
+	}
+
+	s, err := os.ReadFile(w.DigestPath)
+	if err != nil {
+		return nil, fmt.Errorf("digest template file: %w", err)
+	}
+
+	return template.Parse(string(s))
+}
+
+// NewWebhookDigest summarizes a list of payloads.
+func NewWebhookDigest(payloads []*WebhookPayload) *WebhookDigest {
+	digest := &WebhookDigest{
+		Count:    len(payloads),
+		Payloads: payloads,
+		Go:       runtime.Version(),
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Version:  version.Version,
+		Revision: version.Revision,
+	}
+	counts := make(map[ExtractStatus]int)
+	seen := make(map[string]bool)
+
+	for _, payload := range payloads {
+		if digest.Start.IsZero() || payload.Time.Before(digest.Start) {
+			digest.Start = payload.Time
+		}
+
+		if payload.Time.After(digest.End) {
+			digest.End = payload.Time
+		}
+
+		if counts[payload.Event]++; payload.Event == EXTRACTFAILED || payload.Event == DELETEFAILED {
+			digest.Failed++
+		}
+
+		if payload.Data != nil && payload.Event == EXTRACTED {
+			digest.Bytes += payload.Data.Bytes
+		}
+
+		if title := payloadTitle(payload); title != "" && !seen[title] {
+			seen[title] = true
+			digest.Titles = append(digest.Titles, title)
+		}
+	}
+
+	for event := WAITING; event <= EXTRACTSKIPPED; event++ {
+		if count := counts[event]; count > 0 {
+			digest.Events = append(digest.Events, &DigestEvent{Event: event, Count: count})
+		}
+	}
+
+	digest.Summary = digest.summary()
+
+	return digest
+}
+
+// payloadTitle returns the title from a payload, or the folder name when there is no title.
+func payloadTitle(payload *WebhookPayload) string {
+	if title, ok := payload.IDs["title"].(string); ok && title != "" {
+		return title
+	}
+
+	if payload.Path == "" {
+		return ""
+	}
+
+	return filepath.Base(payload.Path)
+}
+
+// summary returns a plain text summary of the digest, used by the built-in digest templates.
+func (d *WebhookDigest) summary() string {
+	var out bytes.Buffer
+
+	fmt.Fprintf(&out, "%d events for %d items", d.Count, len(d.Titles))
+
+	if d.Bytes > 0 {
+		fmt.Fprintf(&out, ", %s extracted", humanbytes(d.Bytes))
+	}
+
+	out.WriteString(".\n")
+
+	for _, event := range d.Events {
+		fmt.Fprintf(&out, "%s: %d\n", event.Event.Desc(), event.Count)
+	}
+
+	if len(d.Titles) > 0 {
+		out.WriteString("\n")
+	}
+
+	for idx, title := range d.Titles {
+		if idx >= digestTitles {
+			fmt.Fprintf(&out, "...and %d more\n", len(d.Titles)-digestTitles)
+			break
+		}
+
+		fmt.Fprintf(&out, "- %s\n", title)
+	}
+
+	return strings.TrimSpace(out.String())
+}
+
+// rateLimiter counts the webhooks sent in the last minute.
+type rateLimiter struct {
+	limit uint
+	sent  []time.Time
+}
+
+// allow returns true, and counts a webhook, if one more webhook may be sent now.
+func (r *rateLimiter) allow(now time.Time) bool {
+	if r.limit == 0 {
+		return true
+	}
+
+	for cutoff := now.Add(-rateLimitWindow); len(r.sent) > 0 && !r.sent[0].After(cutoff); {
+		r.sent = r.sent[1:]
+	}
+
+	if uint(len(r.sent)) >= r.limit {
+		return false
+	}
+
+	r.sent = append(r.sent, now)
+
+	return true
+}
+
+// digestInterval returns how often collected payloads are sent. Rate limited webhooks
+// without a digest send their overflow once a minute. Zero means payloads are never collected.
+func (w *WebhookConfig) digestInterval() time.Duration {
+	if w.Digest.Duration > 0 {
+		return w.Digest.Duration
+	}
+
+	if w.RateLimit > 0 {
+		return rateLimitWindow
+	}
+
+	return 0
+}
+
+// sendDigestWithLog sends the collected payloads. One payload is sent as a normal webhook.
+func (u *Unpackerr) sendDigestWithLog(hook *WebhookConfig, payloads []*WebhookPayload) {
+	if len(payloads) == 0 {
+		return
+	} else if len(payloads) == 1 {
+		u.sendWebhookWithLog(hook, payloads[0])
+		return
+	}
+
+	digest := NewWebhookDigest(payloads)
+	last := payloads[len(payloads)-1]
+	// This is only used in logs and the outbox.
+	label := &WebhookPayload{Path: fmt.Sprintf("digest of %d", digest.Count), Event: last.Event, Time: digest.End}
+
+	var body bytes.Buffer
+
+	if tmpl, err := hook.DigestTemplate(); err != nil {
+		u.Errorf("Webhook Digest Template (%s): %s: %v", label.Path, hook.Name, err)
+		return
+	} else if err = tmpl.Execute(&body, digest); err != nil {
+		u.Errorf("Webhook Digest (%s): %s: %v", label.Path, hook.Name, err)
+		return
+	}
+
+	// URL templates get the newest payload.
+	url, err := hook.PayloadURL(last)
+	if err != nil {
+		u.Errorf("Webhook URL (%s): %s: %v", label.Path, hook.Name, err)
+		return
+	}
+
+	u.deliverWebhook(hook, label, url, body.String())
+}
@@ -0,0 +1,133 @@
+package unpackerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func digestPayloads() []*WebhookPayload {
+	var payloads []*WebhookPayload
+
+	for episode, event := range []ExtractStatus{QUEUED, EXTRACTING, EXTRACTED, EXTRACTED, EXTRACTFAILED} {
+		payload := goldenPayload(event)
+		payload.Time = payload.Time.Add(time.Duration(episode) * time.Minute)
+		payload.IDs["title"] = "Show S01E0" + string(rune('1'+episode/2))
+		payloads = append(payloads, payload)
+	}
+
+	return payloads
+}
+
+func TestWebhookDigest(t *testing.T) {
+	t.Parallel()
+
+	digest := NewWebhookDigest(digestPayloads())
+	digest.Go, digest.OS, digest.Arch, digest.Version, digest.Revision = "go1.22.0", "linux", "amd64", "0.14.5", "abc1234"
+
+	if digest.Count != 5 || digest.Failed != 1 || len(digest.Titles) != 3 || len(digest.Events) != 4 {
+		t.Fatalf("unexpected digest: %d events, %d failed, titles: %v, events: %d",
+			digest.Count, digest.Failed, digest.Titles, len(digest.Events))
+	}
+
+	if digest.Bytes != 2*1234567009 {
+		t.Fatalf("expected only extracted bytes to be counted, got: %d", digest.Bytes)
+	}
+
+	if !digest.End.Equal(digest.Start.Add(4 * time.Minute)) {
+		t.Fatalf("unexpected digest times: %v - %v", digest.Start, digest.End)
+	}
+
+	for _, expect := range []string{"5 events for 3 items", EXTRACTED.Desc() + ": 2", "- Show S01E02"} {
+		if !strings.Contains(digest.Summary, expect) {
+			t.Fatalf("expected the summary to contain '%s', got:\n%s", expect, digest.Summary)
+		}
+	}
+
+	for name := range webhookDigestTemplates {
+		if name == "pushover" {
+			continue // form encoded.
+		}
+
+		tmpl, err := (&WebhookConfig{TempName: name, Channel: "downloads"}).DigestTemplate()
+		if err != nil {
+			t.Fatalf("%s: parsing digest template: %v", name, err)
+		}
+
+		var body bytes.Buffer
+		if err := tmpl.Execute(&body, digest); err != nil {
+			t.Fatalf("%s: executing digest template: %v", name, err)
+		}
+
+		if !json.Valid(body.Bytes()) {
+			t.Fatalf("%s: invalid json:\n%s", name, body.String())
+		}
+
+		if name == "discord" || name == "notifiarr" {
+			checkGolden(t, filepath.Join("testdata", "webhooks", name+".digest.golden"), body.Bytes())
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	limiter := &rateLimiter{limit: 2}
+
+	if !limiter.allow(now) || !limiter.allow(now.Add(time.Second)) {
+		t.Fatal("expected the first two webhooks to be allowed")
+	}
+
+	if limiter.allow(now.Add(2 * time.Second)) {
+		t.Fatal("expected the third webhook in a minute to be limited")
+	}
+
+	if !limiter.allow(now.Add(rateLimitWindow)) {
+		t.Fatal("expected a webhook to be allowed after the first one left the window")
+	}
+
+	if unlimited := (&rateLimiter{}); !unlimited.allow(now) || !unlimited.allow(now) || !unlimited.allow(now) {
+		t.Fatal("expected no limit when the limit is zero")
+	}
+}
+
+func TestSendDigest(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- string(body)
+	}))
+	defer server.Close()
+
+	discard := log.New(io.Discard, "", 0)
+	unpackerr := &Unpackerr{Logger: &Logger{Info: discard, Error: discard, Debug: discard}}
+	hook := &WebhookConfig{URL: server.URL, Name: "test", TempName: "discord", CType: "application/json"}
+	hook.client = server.Client()
+
+	if err := hook.validateURL(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	unpackerr.sendDigestWithLog(hook, digestPayloads())
+
+	if body := <-received; !strings.Contains(body, `"title": "Unpackerr: 5 events"`) {
+		t.Fatalf("expected a digest, got:\n%s", body)
+	}
+
+	// A single payload is sent with the normal template.
+	unpackerr.sendDigestWithLog(hook, digestPayloads()[:1])
+
+	if body := <-received; !strings.Contains(body, QUEUED.Desc()) || strings.Contains(body, "events") {
+		t.Fatalf("expected a normal webhook, got:\n%s", body)
+	}
+}
@@ -0,0 +1,373 @@
+package unpackerr
+
+/* Webhook retries with backoff, and an on-disk outbox for payloads that could not be delivered. */
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHookRetryDelay = 5 * time.Second
+	maximumHookRetryDelay = 5 * time.Minute
+	outboxInterval        = time.Minute // How often undelivered payloads are sent again.
+	outboxFileMode        = 0o600       // Payloads may contain tokens.
+	defaultOutboxMax      = 1000        // Payloads kept in an outbox; the oldest are dropped.
+	outboxCompact         = 100         // The outbox file is rewritten after this many stale lines.
+)
+
+// ErrDuplicateOutbox is returned when two webhooks use the same outbox file.
+var ErrDuplicateOutbox = errors.New("webhook outbox file used more than once")
+
+// webhookOutbox holds undelivered webhook payloads, in order, and saves them to a file.
+// The file is a log with one JSON record per line. Added payloads are appended, and so is the
+// sequence number of each delivered or dropped payload. The file is rewritten with only the
+// waiting payloads when it has more stale lines than waiting payloads, and when it's opened.
+// The metrics collector reads the depth from another go routine, so this has a lock.
+type webhookOutbox struct {
+	path  string
+	max   int
+	items []*outboxItem
+	seq   uint64 // Sequence number of the newest payload.
+	stale int    // Lines in the file for payloads that are gone.
+	sync.Mutex
+}
+
+// outboxItem is a rendered webhook payload waiting to be delivered.
+type outboxItem struct {
+	Seq    uint64    `json:"seq"`
+	Queued time.Time `json:"queued"`
+	Path   string    `json:"path"`
+	Event  string    `json:"event"`
+	URL    string    `json:"url,omitempty"`
+	Body   string    `json:"body"`
+}
+
+// outboxRecord is one line in the outbox file: a payload, or the sequence
+// number of the newest payload that was delivered or dropped.
+type outboxRecord struct {
+	*outboxItem
+
+	Done uint64 `json:"done,omitempty"`
+}
+
+// newWebhookOutbox opens a webhook outbox and loads any payloads saved before a restart.
+// Up to limit payloads are kept.
+func newWebhookOutbox(path string, limit int) (*webhookOutbox, error) {
+	outbox := &webhookOutbox{path: expandHomedir(path), max: limit, items: []*outboxItem{}}
+
+	data, err := os.ReadFile(outbox.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return outbox, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading webhook outbox: %w", err)
+	}
+
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return outbox, nil
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for idx, line := range lines {
+		record := outboxRecord{outboxItem: &outboxItem{}}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			if idx == len(lines)-1 {
+				break // A crash while appending leaves half a line at the end.
+			}
+
+			return nil, fmt.Errorf("decoding webhook outbox %s line %d: %w", outbox.path, idx+1, err)
+		}
+
+		if record.Done > 0 {
+			outbox.forget(record.Done)
+		} else {
+			outbox.items = append(outbox.items, record.outboxItem)
+			outbox.seq = max(outbox.seq, record.Seq)
+		}
+	}
+
+	if outbox.max > 0 && len(outbox.items) > outbox.max {
+		outbox.forget(outbox.items[len(outbox.items)-outbox.max-1].Seq)
+	}
+
+	// Rewrite the file so appended lines never follow half a line.
+	return outbox, outbox.save()
+}
+
+// Len returns the number of undelivered payloads. Safe to call on a nil outbox.
+func (o *webhookOutbox) Len() int {
+	if o == nil {
+		return 0
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	return len(o.items)
+}
+
+// add puts a payload at the end of the outbox and saves it. When the outbox is full,
+// the oldest payloads are dropped to make room. Returns the number of dropped payloads.
+func (o *webhookOutbox) add(item *outboxItem) (int, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	o.seq++
+	item.Seq = o.seq
+	o.items = append(o.items, item)
+	records := []*outboxRecord{{outboxItem: item}}
+
+	dropped := 0
+	if o.max > 0 && len(o.items) > o.max {
+		dropped = len(o.items) - o.max
+		done := o.items[dropped-1].Seq
+		o.forget(done)
+		records = append(records, &outboxRecord{Done: done})
+	}
+
+	return dropped, o.write(records...)
+}
+
+// first returns the oldest payload in the outbox, or nil.
+func (o *webhookOutbox) first() *outboxItem {
+	o.Lock()
+	defer o.Unlock()
+
+	if len(o.items) == 0 {
+		return nil
+	}
+
+	return o.items[0]
+}
+
+// remove deletes the oldest payload from the outbox after it's delivered, and saves it.
+func (o *webhookOutbox) remove() error {
+	o.Lock()
+	defer o.Unlock()
+
+	if len(o.items) == 0 {
+		return nil
+	}
+
+	done := o.items[0].Seq
+	o.forget(done)
+
+	return o.write(&outboxRecord{Done: done})
+}
+
+// forget removes the payloads up to and including a sequence number. Payloads are
+// always delivered or dropped oldest first, so they are at the front of the list.
+func (o *webhookOutbox) forget(done uint64) {
+	count := 0
+	for count < len(o.items) && o.items[count].Seq <= done {
+		count++
+	}
+
+	o.items = o.items[count:]
+	o.stale += count + 1 // The payload lines, and the line saying they're done.
+}
+
+// write appends records to the outbox file, or rewrites it when it has too many stale lines.
+func (o *webhookOutbox) write(records ...*outboxRecord) error {
+	if o.stale >= max(outboxCompact, len(o.items)) {
+		return o.save()
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("encoding webhook outbox: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), defaultDirMode); err != nil {
+		return fmt.Errorf("making webhook outbox folder: %w", err)
+	}
+
+	file, err := os.OpenFile(o.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, outboxFileMode)
+	if err != nil {
+		return fmt.Errorf("writing webhook outbox: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("writing webhook outbox: %w", err)
+	}
+
+	return nil
+}
+
+// save writes the waiting payloads to a temporary file and renames it, so a crash never leaves half a file.
+func (o *webhookOutbox) save() error {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	for _, item := range o.items {
+		if err := encoder.Encode(&outboxRecord{outboxItem: item}); err != nil {
+			return fmt.Errorf("encoding webhook outbox: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.path), defaultDirMode); err != nil {
+		return fmt.Errorf("making webhook outbox folder: %w", err)
+	}
+
+	tmpFile := o.path + ".tmp"
+	if err := os.WriteFile(tmpFile, buf.Bytes(), outboxFileMode); err != nil {
+		return fmt.Errorf("writing webhook outbox: %w", err)
+	}
+
+	if err := os.Rename(tmpFile, o.path); err != nil {
+		return fmt.Errorf("writing webhook outbox: %w", err)
+	}
+
+	o.stale = 0
+
+	return nil
+}
+
+// validateOutboxes sets the retry defaults and opens the outbox for each webhook that has one.
+func (u *Unpackerr) validateOutboxes() error {
+	paths := make(map[string]bool)
+
+	for _, hook := range u.Webhook {
+		if hook.RetryDelay.Duration <= 0 {
+			hook.RetryDelay.Duration = defaultHookRetryDelay
+		}
+
+		if hook.OutboxMax <= 0 {
+			hook.OutboxMax = defaultOutboxMax
+		}
+
+		if hook.Outbox == "" || hook.outbox != nil {
+			continue
+		}
+
+		outbox, err := newWebhookOutbox(hook.Outbox, hook.OutboxMax)
+		if err != nil {
+			return fmt.Errorf("webhook %s: %w", hook.Name, err)
+		}
+
+		if paths[outbox.path] {
+			return fmt.Errorf("%w: %s", ErrDuplicateOutbox, outbox.path)
+		}
+
+		paths[outbox.path] = true
+		hook.outbox = outbox
+	}
+
+	return nil
+}
+
+// hookBackoff returns how long to wait before a webhook retry. The delay doubles every
+// attempt up to a maximum, and up to 25% jitter is added so many retries do not line up.
+func hookBackoff(base time.Duration, attempt uint) time.Duration {
+	delay := base
+
+	for range attempt {
+		if delay *= 2; delay >= maximumHookRetryDelay {
+			delay = maximumHookRetryDelay
+			break
+		}
+	}
+
+	return delay + rand.N(delay/4+1) //nolint:gosec // jitter does not need a secure random number.
+}
+
+// sendWebhookWithRetries POSTs a webhook body, and tries again with backoff when it fails.
+func (u *Unpackerr) sendWebhookWithRetries(
+	hook *WebhookConfig, payload *WebhookPayload, url, body string,
+) ([]byte, error) {
+	for attempt := uint(0); ; attempt++ {
+		start := time.Now()
+		span := payload.span.child("webhook", spanKindClient, start, slog.String("hook", hook.Name),
+			slog.String("event", payload.Event.String()), slog.Int("attempt", int(attempt)+1)) //nolint:gosec
+		reply, err := hook.SendTo(url, strings.NewReader(body))
+		u.hookMetrics("webhook", hook.Name, start)
+		span.finish(time.Now(), err)
+		if err == nil || attempt >= hook.Retries {
+			return reply, err
+		}
+
+		wait := hookBackoff(hook.RetryDelay.Duration, attempt)
+		u.debugf(logHooks, "Webhook (%s = %s): %s: attempt %d/%d failed, retrying in %v: %v",
+			payload.Path, payload.Event, hook.Name, attempt+1, hook.Retries+1, wait.Round(time.Millisecond), err)
+		time.Sleep(wait)
+	}
+}
+
+// saveToOutbox puts an undelivered webhook payload into the webhook's outbox.
+func (u *Unpackerr) saveToOutbox(hook *WebhookConfig, payload *WebhookPayload, url, body string) {
+	dropped, err := hook.outbox.add(&outboxItem{
+		Queued: time.Now(),
+		Path:   payload.Path,
+		Event:  payload.Event.String(),
+		URL:    url,
+		Body:   body,
+	})
+	if dropped > 0 {
+		u.Errorf("Webhook (%s = %s): %s: outbox is full (outbox_max: %d), dropped %d oldest payload(s)",
+			payload.Path, payload.Event, hook.Name, hook.OutboxMax, dropped)
+	}
+
+	if err != nil {
+		u.Errorf("Webhook (%s = %s): %s: %v", payload.Path, payload.Event, hook.Name, err)
+		return
+	}
+
+	u.Printf("[Webhook] Saved Payload to outbox (%s = %s): %s: %d waiting",
+		payload.Path, payload.Event, hook.Name, hook.outbox.Len())
+}
+
+// flushOutbox sends the payloads in a webhook's outbox, oldest first. It stops at the first
+// failure, so the payloads stay in order. Returns true if the outbox is empty.
+func (u *Unpackerr) flushOutbox(hook *WebhookConfig) bool {
+	for item := hook.outbox.first(); item != nil; item = hook.outbox.first() {
+		url := item.URL
+		if url == "" {
+			url = hook.URL
+		}
+
+		start := time.Now()
+		_, err := hook.SendTo(url, strings.NewReader(item.Body))
+		u.hookMetrics("webhook", hook.Name, start)
+
+		if err != nil {
+			u.debugf(logHooks, "Webhook outbox: %s: %d waiting: %v", hook.Name, hook.outbox.Len(), err)
+			return false
+		}
+
+		if err := hook.outbox.remove(); err != nil {
+			u.Errorf("Webhook outbox: %s: %v", hook.Name, err)
+		}
+
+		if !hook.Silent {
+			u.Printf("[Webhook] Posted Payload from outbox (%s = %s): %s: OK, queued %v ago",
+				item.Path, item.Event, hook.Name, time.Since(item.Queued).Round(time.Second))
+		}
+	}
+
+	return true
+}
+
+// outboxDepth returns the number of undelivered payloads in all webhook outboxes.
+func (u *Unpackerr) outboxDepth() int {
+	var depth int
+
+	for _, hook := range u.Webhook {
+		depth += hook.outbox.Len()
+	}
+
+	return depth
+}
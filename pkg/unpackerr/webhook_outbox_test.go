@@ -0,0 +1,167 @@
+package unpackerr
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golift.io/cnfg"
+)
+
+func TestHookBackoff(t *testing.T) {
+	t.Parallel()
+
+	for attempt, base := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if wait := hookBackoff(time.Second, uint(attempt)); wait < base || wait > base+base/4 {
+			t.Fatalf("attempt %d: expected a wait between %v and %v, got: %v", attempt, base, base+base/4, wait)
+		}
+	}
+
+	if wait := hookBackoff(time.Minute, 20); wait > maximumHookRetryDelay+maximumHookRetryDelay/4 {
+		t.Fatalf("expected the wait to be capped, got: %v", wait)
+	}
+}
+
+func TestWebhookOutbox(t *testing.T) {
+	t.Parallel()
+
+	var (
+		lock     sync.Mutex
+		received []string
+		down     = true
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+
+	discard := log.New(io.Discard, "", 0)
+	outboxFile := filepath.Join(t.TempDir(), "outbox.json")
+	unpackerr := &Unpackerr{
+		Config: &Config{Webhook: []*WebhookConfig{{
+			URL:        server.URL,
+			Name:       "test",
+			Retries:    1,
+			RetryDelay: cnfg.Duration{Duration: time.Millisecond},
+			Outbox:     outboxFile,
+			client:     server.Client(),
+		}}},
+		Logger: &Logger{Info: discard, Error: discard, Debug: discard},
+	}
+
+	if err := unpackerr.validateOutboxes(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hook := unpackerr.Webhook[0]
+
+	for _, body := range []string{"first", "second"} {
+		if _, err := unpackerr.sendWebhookWithRetries(hook, &WebhookPayload{}, server.URL, body); err == nil {
+			t.Fatal("expected an error while the server is down")
+		}
+
+		unpackerr.saveToOutbox(hook, &WebhookPayload{Path: body}, server.URL, body)
+	}
+
+	if posts, fails := hook.Counts(); posts != 4 || fails != 4 {
+		t.Fatalf("expected 4 failed posts with one retry each, got %d posts and %d failures", posts, fails)
+	}
+
+	// A restart loads the saved payloads.
+	outbox, err := newWebhookOutbox(outboxFile, defaultOutboxMax)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if outbox.Len() != 2 || unpackerr.outboxDepth() != 2 {
+		t.Fatalf("expected 2 saved payloads, got %d", outbox.Len())
+	}
+
+	hook.outbox = outbox
+
+	lock.Lock()
+	down = false
+	lock.Unlock()
+
+	if !unpackerr.flushOutbox(hook) {
+		t.Fatal("expected the outbox to be empty after it was sent")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(received) != 2 || received[0] != "first" || received[1] != "second" {
+		t.Fatalf("expected the payloads in order, got: %v", received)
+	}
+
+	if outbox, _ = newWebhookOutbox(outboxFile, defaultOutboxMax); outbox.Len() != 0 {
+		t.Fatalf("expected the saved outbox to be empty, got %d", outbox.Len())
+	}
+}
+
+func TestWebhookOutboxMax(t *testing.T) {
+	t.Parallel()
+
+	outboxFile := filepath.Join(t.TempDir(), "outbox.json")
+
+	outbox, err := newWebhookOutbox(outboxFile, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for idx := range 5 {
+		dropped, err := outbox.add(&outboxItem{Body: strconv.Itoa(idx)})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if expect := max(0, min(1, idx-2)); dropped != expect {
+			t.Fatalf("payload %d: expected %d dropped, got %d", idx, expect, dropped)
+		}
+	}
+
+	if err := outbox.remove(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Payloads are appended; the file is not rewritten until it has enough stale lines.
+	data, _ := os.ReadFile(outboxFile)
+	if lines := strings.Count(string(data), "\n"); lines != 8 {
+		t.Fatalf("expected 5 payload lines and 3 done lines, got %d lines:\n%s", lines, data)
+	}
+
+	// Half a line from a crash is ignored, and the file is compacted when it's opened.
+	if err := os.WriteFile(outboxFile, append(data, `{"seq":9,"bo`...), outboxFileMode); err != nil {
+		t.Fatalf("writing outbox: %v", err)
+	}
+
+	if outbox, err = newWebhookOutbox(outboxFile, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if first := outbox.first(); outbox.Len() != 2 || first.Body != "3" || outbox.seq != 5 {
+		t.Fatalf("expected payloads 3 and 4 after a restart, got %d: %+v", outbox.Len(), first)
+	}
+
+	if data, _ = os.ReadFile(outboxFile); strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("expected the outbox file to be compacted, got:\n%s", data)
+	}
+}
@@ -6,12 +6,13 @@ import (
 	"time"
 
 	"golift.io/cnfg"
+	"golift.io/starr"
 	"golift.io/version"
 	"golift.io/xtractr"
 )
 
 func (u *Unpackerr) sampleWebhook(e ExtractStatus) error {
-	u.Printf("Sending sample webhooks and exiting! (-w %d passed)", e)
+	u.Printf("Sending sample webhooks and emails and exiting! (-w %d passed)", e)
 
 	payload := samplePayload()
 	switch payload.Event = e; payload.Event {
@@ -42,12 +43,20 @@ func (u *Unpackerr) sampleWebhook(e ExtractStatus) error {
 	case DELETEFAILED:
 		payload.Data.Elapsed.Duration = 0
 		payload.Data.Error = "unable to delete files"
+	case EXTRACTSKIPPED:
+		payload.Data = &XtractPayload{Error: "archive size 12K is below min_size 1M"}
+	case REPORT:
+		payload = sampleReport(payload)
 	}
 
 	for _, hook := range u.Webhook {
 		u.sendWebhookWithLog(hook, payload)
 	}
 
+	for _, email := range u.Email {
+		u.sendEmailWithLog(email, payload)
+	}
+
 	return nil
 }
 
@@ -79,3 +88,27 @@ func samplePayload() *WebhookPayload {
 		},
 	}
 }
+
+// sampleReport turns a sample payload into a sample statistics report.
+func sampleReport(payload *WebhookPayload) *WebhookPayload {
+	counters := newReportStats(time.Now().Add(-24*time.Hour), defaultReportSlowest)
+	for idx, app := range []starr.App{starr.Radarr, starr.Sonarr, starr.Sonarr, FolderString} {
+		counters.observe(&xtractr.Response{
+			X:        &xtractr.Xtract{Name: fmt.Sprintf("/this/is/a/path/%s.%d", app, idx)},
+			Size:     uint64(idx+1) * 1234567009,
+			Elapsed:  time.Duration(idx+1) * time.Minute,
+			Archives: xtractr.ArchiveList{"/this/is/a/path": []string{"archive.rar"}},
+			NewFiles: []string{"/this/is/a/path/file.mkv"},
+		}, app)
+	}
+
+	counters.observe(&xtractr.Response{Error: xtractr.ErrInvalidHead, X: &xtractr.Xtract{}}, starr.Lidarr)
+
+	payload.Report = counters.report("Daily Report", time.Now())
+	payload.App = ReportString
+	payload.Path = ""
+	payload.IDs = map[string]any{"title": payload.Report.Title}
+	payload.Data = nil
+
+	return payload
+}
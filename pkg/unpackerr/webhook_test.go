@@ -0,0 +1,79 @@
+package unpackerr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookMethodAndURLTemplate(t *testing.T) {
+	t.Parallel()
+
+	var method, path, body string
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+	}))
+	defer server.Close()
+
+	hook := &WebhookConfig{
+		Name:   "ping",
+		URL:    server.URL + "/ping/{{.App}}/{{.Event}}",
+		Method: "get",
+		client: server.Client(),
+	}
+
+	if err := hook.validateURL(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	url, err := hook.PayloadURL(&WebhookPayload{App: "Sonarr", Event: EXTRACTED})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := hook.SendTo(url, strings.NewReader("payload")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if method != http.MethodGet || path != "/ping/Sonarr/extracted" || body != "" {
+		t.Fatalf("unexpected request: %s %s, body: %q", method, path, body)
+	}
+
+	hook.Method = http.MethodPut
+	if _, err := hook.SendTo(server.URL, strings.NewReader("payload")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if method != http.MethodPut || body != "payload" {
+		t.Fatalf("unexpected request: %s, body: %q", method, body)
+	}
+
+	if err := (&WebhookConfig{Method: "TRACE"}).validateURL(); err == nil {
+		t.Fatal("expected an error for an invalid method")
+	}
+
+	hook.URL = server.URL + "/ping/{{pathescape .Path}}?app={{urlquery .App}}"
+	if err := hook.validateURL(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if url, _ = hook.PayloadURL(&WebhookPayload{Path: "/tv/Show?#1", App: "A&B"}); url != server.URL+
+		"/ping/%2Ftv%2FShow%3F%231?app=A%26B" {
+		t.Fatalf("expected escaped template values, got: %s", url)
+	}
+
+	hook.URL = server.URL + "/ping/{{.Path}}"
+	_ = hook.validateURL()
+
+	if _, err := hook.PayloadURL(&WebhookPayload{Path: "line\nbreak"}); err == nil {
+		t.Fatal("expected an error for an invalid rendered url")
+	}
+
+	if err := (&WebhookConfig{URL: "http://localhost/{{.Event"}).validateURL(); err == nil {
+		t.Fatal("expected an error for an invalid url template")
+	}
+}
@@ -17,11 +17,14 @@ import (
 type WebhookPayload struct {
 	Path   string         `json:"path"`                // Path for the extracted item.
 	App    starr.App      `json:"app"`                 // Application Triggering Event
+	URL    string         `json:"-"`                   // Starr app instance URL; used by hook filters.
 	IDs    map[string]any `json:"ids,omitempty"`       // Arbitrary IDs from each app.
 	Event  ExtractStatus  `json:"unpackerr_eventtype"` // The type of the event.
 	Time   time.Time      `json:"time"`                // Time of this event.
 	Data   *XtractPayload `json:"data,omitempty"`      // Payload from extraction process.
+	Report *StatsReport   `json:"report,omitempty"`    // Statistics for report events.
 	Config *WebhookConfig `json:"-"`                   // Payload from extraction process.
+	span   *span          // The item's trace; hook spans go under it.
 	// Application Metadata.
 	Go       string    `json:"go"`       // Version of go compiled with
 	OS       string    `json:"os"`       // Operating system: linux, windows, darwin
@@ -34,16 +37,19 @@ type WebhookPayload struct {
 
 // XtractPayload is a rewrite of xtractr.Response.
 type XtractPayload struct {
-	Error    string        `json:"error,omitempty"`    // error only during extractfailed
-	Archive  []string      `json:"archive,omitempty"`  // list of all archive files extracted
-	Archives StringSlice   `json:"archives,omitempty"` // list of all archive files extracted
-	Files    StringSlice   `json:"files,omitempty"`    // list of all files extracted
-	File     []string      `json:"file,omitempty"`     // list of all files extracted
-	Start    time.Time     `json:"start"`              // start time of extraction
-	Output   string        `json:"output,omitempty"`   // temporary items folder
-	Bytes    uint64        `json:"bytes,omitempty"`    // Bytes written
-	Elapsed  cnfg.Duration `json:"elapsed"`            // Duration as a string: 5m32s
-	Queue    int           `json:"queue,omitempty"`    // Extraction Queue Size
+	Error      string        `json:"error,omitempty"`          // error only during extractfailed
+	Archive    []string      `json:"archive,omitempty"`        // list of all archive files extracted
+	Archives   StringSlice   `json:"archives,omitempty"`       // list of all archive files extracted
+	Files      StringSlice   `json:"files,omitempty"`          // list of all files extracted
+	File       []string      `json:"file,omitempty"`           // list of all files extracted
+	Start      time.Time     `json:"start"`                    // start time of extraction
+	Output     string        `json:"output,omitempty"`         // temporary items folder
+	Bytes      uint64        `json:"bytes,omitempty"`          // Bytes written
+	Elapsed    cnfg.Duration `json:"elapsed"`                  // Duration as a string: 5m32s
+	Queue      int           `json:"queue,omitempty"`          // Extraction Queue Size
+	Password   string        `json:"password,omitempty"`       // Masked password that opened the archives.
+	PassFrom   string        `json:"passwordSource,omitempty"` // Where the password came from: path, app, folder, global.
+	HookOutput string        `json:"hookOutput,omitempty"`     // Output from the pre_extract and post_extract gate hooks.
 }
 
 // WebhookTemplateNotifiarr is the default template
@@ -63,8 +69,10 @@ const WebhookTemplateNotifiarr = `{
     "start": "{{.Data.Start}}",
     "tmp_folder": {{encode .Data.Output}},
     "bytes": "{{.Data.Bytes}}",
-    "elapsed": "{{.Data.Elapsed}}"
+    "elapsed": "{{.Data.Elapsed}}"{{ if .Data.HookOutput }},
+    "hook_output": {{encode .Data.HookOutput}}{{ end }}
     },
+{{ end }}{{ if .Report }}    "report": {{encode .Report}},
 {{ end }}    "go_version": "{{.Go}}",
   "os": "{{.OS}}",
   "arch": "{{.Arch}}",
@@ -80,6 +88,14 @@ const WebhookTemplateTelegram = `{
   "parse_mode": "HTML",
   "disable_web_page_preview": true,
   "text": "<b><a href=\"https://github.com/Unpackerr/unpackerr/releases\">Unpackerr</a></b>: {{.Event.Desc -}}
+  {{ if .Report }}
+    {{- with .Report}}\n<b>{{.Title}}</b>: {{.Start.Format "Jan 2 15:04"}} - {{.End.Format "Jan 2 15:04" -}}
+    \n\n <b>Extracted</b>: {{.Extracted}}\n <b>Failed</b>: {{.Failed}}\n <b>Size</b>: {{humanbytes .Bytes -}}
+    \n <b>Average</b>: {{.Average -}}
+    {{ range .Apps}}\n <b>{{.App}}</b>: {{.Extracted}} extracted, {{.Failed}} failed{{end -}}
+    {{ if .Slowest}}\n\n<b>Slowest</b>:{{range .Slowest}}\n - {{rawencode (html .Name)}}: {{.Elapsed}}{{end}}{{end -}}
+    {{end -}}
+  {{ else -}}
     \n<b>Title</b>: {{rawencode (index .IDs "title") -}}
     \n<b>App</b>: {{.App -}}
     \n\n<b>Path</b>: <code>{{rawencode .Path}}</code>
@@ -90,6 +106,7 @@ const WebhookTemplateTelegram = `{
     {{ if .Data.Bytes}}\n <b>Size</b>: {{humanbytes .Data.Bytes}}{{end -}}
     {{ if and (gt .Event 1) (lt .Event 5)}}\n <b>Queue</b>: {{.Data.Queue}}{{end -}}
     {{ if .Data.Error}}\n\n <b>ERROR</b>: <pre>{{rawencode .Data.Error}}</pre>\n{{end -}}
+  {{end -}}
   {{end -}}"
 }
 `
@@ -98,13 +115,14 @@ const WebhookTemplateTelegram = `{
 
 const WebhookTemplateGotify = `{
   "title": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Event.Desc}}",
-  "message": "**App**: {{.App}}  \n**Name**: {{rawencode (index .IDs "title")}}  \n**Path**: {{rawencode .Path -}}
+  "message": "{{if .Report}}{{rawencode .Report.Summary}}{{else -}}
+    **App**: {{.App}}  \n**Name**: {{rawencode (index .IDs "title")}}  \n**Path**: {{rawencode .Path -}}
     {{ if .Data.Elapsed.Duration }}  \n**Elapsed**: {{.Data.Elapsed}}{{end -}}
     {{ if .Data.Archives }}  \n**RARs**: {{len .Data.Archives}}{{end -}}
     {{ if .Data.Files }}  \n**Files**: {{len .Data.Files}}{{end -}}
     {{ if .Data.Bytes }}  \n**Bytes**: {{humanbytes .Data.Bytes}}{{end -}}
     {{ if and (gt .Event 1) (lt .Event 5) }}  \n**Queue**: {{.Data.Queue}}{{end -}}
-    {{ if .Data.Error}}  \n**ERROR**:\n~~~\n{{rawencode .Data.Error}}\n~~~{{end}}",
+    {{ if .Data.Error}}  \n**ERROR**:\n~~~\n{{rawencode .Data.Error}}\n~~~{{end}}{{end}}",
   "extras": {
     "client::display": {
       "contentType": "text/markdown"
@@ -136,8 +154,18 @@ const WebhookTemplateDiscord = `{
             {{- else if(eq 3 .Event)}}10038562
             {{- else if(eq 4 .Event)}}786176
             {{- else if(eq 5 .Event)}}12745742
+            {{- else if(eq 11 .Event)}}3447003
             {{- else}}16711695{{end}},
-    "fields": [
+    "fields": [{{ if .Report }}{{ with .Report }}
+     {"name": "Extracted", "value": "{{.Extracted}}", "inline": true},
+     {"name": "Failed", "value": "{{.Failed}}", "inline": true},
+     {"name": "Size", "value": "{{humanbytes .Bytes}}", "inline": true},
+     {"name": "Average", "value": "{{.Average}}", "inline": true}
+     {{- range .Apps}},
+     {"name": "{{.App}}", "value": "{{.Extracted}} extracted, {{.Failed}} failed", "inline": true}{{end}}
+     {{- if .Slowest}},
+     {"name": "Slowest", "value": {{encode .SlowestList}}, "inline": false}{{end}}
+     {{end}}{{ else }}
      {"name": "Path", "value": {{encode .Path}}, "inline": false},
      {"name": "App", "value": "{{.App}}", "inline": true}{{ if .Data }}
      {{ if .Data.Archives}},{"name": "Archives", "value": "{{len .Data.Archives}}", "inline": true}
@@ -151,7 +179,7 @@ const WebhookTemplateDiscord = `{
      {{ if and (gt .Event 1) (lt .Event 5)}},{"name": "Queue", "value": "{{.Data.Queue}}", "inline": true}
      {{end -}}
      {{ if .Data.Error }},{"name": "Error", "value": {{encode .Data.Error}}, "inline": false}
-     {{end}}{{end -}}
+     {{end}}{{end}}{{end -}}
     ],
     "footer": {
      "text": "v{{.Version}}-{{.Revision}} ({{.OS}}/{{.Arch}})",
@@ -162,7 +190,17 @@ const WebhookTemplateDiscord = `{
 `
 
 const WebhookTemplatePushover = `token={{token}}&user={{channel}}&html=1&title={{formencode .Event.Desc}}&` +
-	`{{if nickname}}device={{nickname}}&{{end}}message=<pre><b>App</b>: {{.App}}
+	`{{if nickname}}device={{nickname}}&{{end}}message=<pre>{{ if .Report -}}
+{{ with .Report }}<b>{{.Title}}</b>: {{.Start.Format "Jan 2 15:04"}} - {{.End.Format "Jan 2 15:04"}}
+<b>Extracted</b>: {{.Extracted}}
+<b>Failed</b>: {{.Failed}}
+<b>Bytes</b>: {{humanbytes .Bytes}}
+<b>Average</b>: {{.Average}}
+{{ range .Apps}}<b>{{.App}}</b>: {{.Extracted}} extracted, {{.Failed}} failed
+{{end}}{{ if .Slowest}}<b>Slowest</b>:
+{{formencode .SlowestList}}
+{{end}}{{end}}{{ else -}}
+<b>App</b>: {{.App}}
 <b>Name</b>: {{formencode (index .IDs "title")}}
 <b>Path</b>: {{formencode .Path}}
 {{ if .Data -}}
@@ -173,7 +211,7 @@ const WebhookTemplatePushover = `token={{token}}&user={{channel}}&html=1&title={
 {{end}}{{ if and (gt .Event 1) (lt .Event 5)}}<b>Queue</b>: {{.Data.Queue}}
 {{end}}{{ if .Data.Error}}
 <font color="#FF0000"><b>ERROR</b>: {{formencode .Data.Error}}</font>
-{{end}}{{end -}}</pre>`
+{{end}}{{end}}{{end -}}</pre>`
 
 // WebhookTemplateSlack is a built-in template for sending a message to Slack.
 const WebhookTemplateSlack = `
@@ -195,14 +233,32 @@ const WebhookTemplateSlack = `
         "type": "mrkdwn",
         "text": {{encode (print ":star: *" (index .IDs "title") "*")}}
       }
-    },
+    },{{ if .Report }}{{ with .Report }}
+    {
+      "type": "section",
+      "fields": [
+        {"type": "mrkdwn", "text": "*Extracted*\n{{.Extracted}}"},
+        {"type": "mrkdwn", "text": "*Failed*\n{{.Failed}}"},
+        {"type": "mrkdwn", "text": "*Size*\n{{humanbytes .Bytes}}"},
+        {"type": "mrkdwn", "text": "*Average*\n{{.Average}}"}
+        {{- range .Apps}},
+        {"type": "mrkdwn", "text": "*{{.App}}*\n{{.Extracted}} extracted, {{.Failed}} failed"}{{end}}
+      ]
+    },{{ if .Slowest }}
+    {
+      "type": "section",
+      "text": {
+        "type": "mrkdwn",
+        "text": {{encode (print "*Slowest*\n" .SlowestList)}}
+      }
+    },{{end}}{{end}}{{ else }}
     {
       "type": "section",
       "text": {
         "type": "mrkdwn",
         "text": {{encode (print "*Path*: " .Path)}}
       }
-    },
+    },{{end}}
     {
       "type": "section",
       "fields": [
@@ -250,14 +306,168 @@ const WebhookTemplateSlack = `
 }
 `
 
-// Template returns a template specific to this webhook.
-//
-//nolint:wrapcheck
-func (w *WebhookConfig) Template() (*template.Template, error) {
-	template := template.New("webhook").Funcs(template.FuncMap{
+// WebhookTemplateNtfy is a built-in template for ntfy.sh JSON publishing. POST it to the ntfy
+// server root URL and put the topic in the channel setting. Failures get a higher priority.
+const WebhookTemplateNtfy = `{
+  "topic": {{encode channel}},
+  "title": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Event.Desc}}",
+  "message": "{{if .Report}}{{rawencode .Report.Summary}}{{else -}}
+    {{rawencode (index .IDs "title")}}\nApp: {{.App}}\nPath: {{rawencode .Path -}}
+  {{ if .Data }}
+    {{- if .Data.Elapsed.Duration}}\nElapsed: {{.Data.Elapsed}}{{end -}}
+    {{ if .Data.Archives}}\nArchives: {{len .Data.Archives}}{{end -}}
+    {{ if .Data.Files}}\nFiles: {{len .Data.Files}}{{end -}}
+    {{ if .Data.Bytes}}\nSize: {{humanbytes .Data.Bytes}}{{end -}}
+    {{ if .Data.Error}}\nError: {{rawencode .Data.Error}}{{end -}}
+  {{end}}{{end}}",
+  "priority": {{if or (eq .Event 3) (eq .Event 7)}}4{{else if eq .Event 4}}3{{else}}2{{end}},
+  "tags": [
+    "{{if eq .Event 1}}inbox_tray
+      {{- else if eq .Event 2}}package
+      {{- else if eq .Event 3}}x
+      {{- else if eq .Event 4}}white_check_mark
+      {{- else if eq .Event 5}}tada
+      {{- else if eq .Event 7}}warning
+      {{- else if eq .Event 10}}fast_forward
+      {{- else if eq .Event 11}}bar_chart
+      {{- else}}wastebasket{{end}}",
+    "{{.Event}}",
+    {{encode .App}}
+  ]
+}
+`
+
+// WebhookTemplateMatrix is a built-in template for a Matrix client-server m.room.message event.
+// Send it to /_matrix/client/v3/rooms/{roomId}/send/m.room.message/{txnId} with method PUT.
+const WebhookTemplateMatrix = `{
+  "msgtype": "m.notice",
+  "body": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Event.Desc}}\n
+    {{- if .Report}}{{rawencode .Report.Summary}}{{else}}{{rawencode (index .IDs "title") -}}
+    \nApp: {{.App}}\nPath: {{rawencode .Path -}}
+  {{ if .Data }}
+    {{- if .Data.Elapsed.Duration}}\nElapsed: {{.Data.Elapsed}}{{end -}}
+    {{ if .Data.Files}}\nFiles: {{len .Data.Files}}{{end -}}
+    {{ if .Data.Bytes}}\nSize: {{humanbytes .Data.Bytes}}{{end -}}
+    {{ if .Data.Error}}\nError: {{rawencode .Data.Error}}{{end -}}
+  {{end}}{{end}}",
+  "format": "org.matrix.custom.html",
+  "formatted_body": "<b>{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Event.Desc -}}
+    </b><br>{{if .Report}}{{with .Report}}<b>{{.Title}}</b>: {{.Start.Format "Jan 2 15:04"}} - {{.End.Format "Jan 2 15:04" -}}
+    <br><b>Extracted</b>: {{.Extracted}}<br><b>Failed</b>: {{.Failed}}<br><b>Size</b>: {{humanbytes .Bytes -}}
+    <br><b>Average</b>: {{.Average}}{{range .Apps}}<br><b>{{.App}}</b>: {{.Extracted}} extracted, {{.Failed}} failed{{end -}}
+    {{ if .Slowest}}<br><b>Slowest</b>:<pre>{{rawencode (html .SlowestList)}}</pre>{{end}}{{end}}{{else -}}
+    {{rawencode (html (index .IDs "title")) -}}
+    <br><b>App</b>: {{.App}}<br><b>Path</b>: <code>{{rawencode (html .Path)}}</code>
+  {{- if .Data }}
+    {{- if .Data.Elapsed.Duration}}<br><b>Elapsed</b>: {{.Data.Elapsed}}{{end -}}
+    {{ if .Data.Files}}<br><b>Files</b>: {{len .Data.Files}}{{end -}}
+    {{ if .Data.Bytes}}<br><b>Size</b>: {{humanbytes .Data.Bytes}}{{end -}}
+    {{ if .Data.Error}}<br><b>Error</b>: <pre>{{rawencode (html .Data.Error)}}</pre>{{end -}}
+  {{end}}{{end}}"
+}
+`
+
+// WebhookTemplateTeams is a built-in template for Microsoft Teams (workflows) with an adaptive card.
+const WebhookTemplateTeams = `{
+  "type": "message",
+  "attachments": [{
+    "contentType": "application/vnd.microsoft.card.adaptive",
+    "contentUrl": null,
+    "content": {
+      "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+      "type": "AdaptiveCard",
+      "version": "1.4",
+      "msteams": {"width": "Full"},
+      "body": [
+        {
+          "type": "TextBlock",
+          "size": "Medium",
+          "weight": "Bolder",
+          "color": "{{if or (eq .Event 3) (eq .Event 7)}}Attention{{else if eq .Event 4}}Good{{else}}Default{{end}}",
+          "text": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Event.Desc}}"
+        },
+        {"type": "TextBlock", "text": {{encode (index .IDs "title")}}, "wrap": true},
+        {
+          "type": "FactSet",
+          "facts": [{{ if .Report }}{{ with .Report }}
+            {"title": "Extracted", "value": "{{.Extracted}}"},
+            {"title": "Failed", "value": "{{.Failed}}"},
+            {"title": "Size", "value": "{{humanbytes .Bytes}}"},
+            {"title": "Average", "value": "{{.Average}}"}{{range .Apps}},
+            {"title": "{{.App}}", "value": "{{.Extracted}} extracted, {{.Failed}} failed"}{{end}}{{end}}{{ else }}
+            {"title": "App", "value": "{{.App}}"},
+            {"title": "Path", "value": {{encode .Path}}}{{ end }}{{ if .Data }}
+            {{- if .Data.Archives}},
+            {"title": "Archives", "value": "{{len .Data.Archives}}"}{{end}}
+            {{- if .Data.Files}},
+            {"title": "Files", "value": "{{len .Data.Files}}"}{{end}}
+            {{- if .Data.Bytes}},
+            {"title": "Size", "value": "{{humanbytes .Data.Bytes}}"}{{end}}
+            {{- if .Data.Elapsed.Duration}},
+            {"title": "Elapsed", "value": "{{.Data.Elapsed}}"}{{end}}{{end}},
+            {"title": "Version", "value": "v{{.Version}}-{{.Revision}} ({{.OS}}/{{.Arch}})"}
+          ]
+        }{{if and .Data .Data.Error}},
+        {"type": "TextBlock", "text": {{encode .Data.Error}}, "color": "Attention", "wrap": true}{{end}}
+        {{- if and .Report .Report.Slowest}},
+        {"type": "TextBlock", "text": {{encode (print "Slowest:\n\n" .Report.SlowestList)}}, "wrap": true}{{end}}
+      ]
+    }
+  }]
+}
+`
+
+// WebhookTemplateApprise is a built-in template for the Apprise API /notify endpoint.
+// The channel setting, if provided, is sent as the Apprise tag.
+const WebhookTemplateApprise = `{
+  "title": "{{if nickname}}{{nickname}}{{else}}Unpackerr{{end}}: {{.Event.Desc}}",
+  "body": "**{{rawencode (index .IDs "title")}}**\n{{if .Report}}{{rawencode .Report.Summary}}{{else -}}
+    **App**: {{.App}}\n**Path**: {{rawencode .Path -}}
+  {{ if .Data }}
+    {{- if .Data.Elapsed.Duration}}\n**Elapsed**: {{.Data.Elapsed}}{{end -}}
+    {{ if .Data.Archives}}\n**Archives**: {{len .Data.Archives}}{{end -}}
+    {{ if .Data.Files}}\n**Files**: {{len .Data.Files}}{{end -}}
+    {{ if .Data.Bytes}}\n**Size**: {{humanbytes .Data.Bytes}}{{end -}}
+    {{ if .Data.Error}}\n**Error**: {{rawencode .Data.Error}}{{end -}}
+  {{end}}{{end}}",
+  "type": "{{if or (eq .Event 3) (eq .Event 7)}}failure
+    {{- else if eq .Event 4}}success
+    {{- else if eq .Event 10}}warning
+    {{- else}}info{{end}}",
+  "format": "markdown"{{if channel}},
+  "tag": {{encode channel}}{{end}}
+}
+`
+
+// WebhookTemplateHomeAssistant is a built-in template for Home Assistant webhook triggers.
+// Every value is available in automations as trigger.json.<name>.
+const WebhookTemplateHomeAssistant = `{
+  "event": "{{.Event}}",
+  "event_id": {{printf "%d" .Event}},
+  "description": "{{.Event.Desc}}",
+  "name": {{encode name}},
+  "app": "{{.App}}",
+  "title": {{encode (index .IDs "title")}},
+  "path": {{encode .Path}},
+  "ids": {{encode .IDs}},
+  "time": "{{timestamp .Time}}",{{if .Data}}
+  "archives": {{len .Data.Archives}},
+  "files": {{len .Data.Files}},
+  "bytes": {{.Data.Bytes}},
+  "elapsed": "{{.Data.Elapsed}}",
+  "error": {{encode .Data.Error}},{{end}}{{if .Report}}
+  "report": {{encode .Report}},{{end}}
+  "version": "{{.Version}}"
+}
+`
+
+// templateFuncs returns the functions available in webhook body and URL templates.
+func (w *WebhookConfig) templateFuncs() template.FuncMap {
+	return template.FuncMap{
 		"encode":     func(v any) string { b, _ := json.Marshal(v); return string(b) },
 		"rawencode":  func(v any) string { b, _ := json.Marshal(v); return strings.Trim(string(b), `"`) }, // yuck
 		"formencode": url.QueryEscape,
+		"pathescape": url.PathEscape,
 		"separator":  separator,
 		"humanbytes": humanbytes,
 		"nickname":   func() string { return w.Nickname },
@@ -265,49 +475,129 @@ func (w *WebhookConfig) Template() (*template.Template, error) {
 		"token":      func() string { return w.Token },
 		"timestamp":  func(t time.Time) string { return t.Format(time.RFC3339) },
 		"name":       func() string { return w.Name },
-	})
+	}
+}
+
+// webhookTemplates are the built-in templates, by name.
+//
+//nolint:gochecknoglobals
+var webhookTemplates = map[string]string{
+	"notifiarr":     WebhookTemplateNotifiarr,
+	"discord":       WebhookTemplateDiscord,
+	"telegram":      WebhookTemplateTelegram,
+	"slack":         WebhookTemplateSlack,
+	"pushover":      WebhookTemplatePushover,
+	"gotify":        WebhookTemplateGotify,
+	"ntfy":          WebhookTemplateNtfy,
+	"matrix":        WebhookTemplateMatrix,
+	"teams":         WebhookTemplateTeams,
+	"apprise":       WebhookTemplateApprise,
+	"homeassistant": WebhookTemplateHomeAssistant,
+}
 
+// Template returns a template specific to this webhook.
+//
+//nolint:wrapcheck
+func (w *WebhookConfig) Template() (*template.Template, error) {
+	template := template.New("webhook").Funcs(w.templateFuncs())
+
+	name := w.templateName()
+	if name != "" {
+		return template.Parse(webhookTemplates[name])
+	}
+
+	s, err := os.ReadFile(w.TmplPath)
+	if err != nil {
+		return nil, fmt.Errorf("template file: %w", err)
+	}
+
+	return template.Parse(string(s))
+}
+
+// templateName returns the name of the built-in template this webhook uses,
+// or an empty string when it uses a custom template file.
+func (w *WebhookConfig) templateName() string {
 	// Providing a template name that exists overrides template_path.
-	// Do not add a 'default' case here.
-	switch strings.ToLower(w.TempName) {
-	case "notifiarr", "default":
-		return template.Parse(WebhookTemplateNotifiarr)
-	case "discord":
-		return template.Parse(WebhookTemplateDiscord)
-	case "telegram":
-		return template.Parse(WebhookTemplateTelegram)
-	case "slack":
-		return template.Parse(WebhookTemplateSlack)
-	case "pushover":
-		return template.Parse(WebhookTemplatePushover)
-	case "gotify":
-		return template.Parse(WebhookTemplateGotify)
+	if name := strings.ToLower(w.TempName); name == "default" {
+		return "notifiarr"
+	} else if webhookTemplates[name] != "" {
+		return name
 	}
 
-	// Figure out which template to use based on URL or template_path.
-	switch url := strings.ToLower(w.URL); {
+	// Figure out which template to use based on the URL's host name or a well-known path, or template_path.
+	// Self-hosted services are detected when their name is the first part of the host name, like http://ntfy:80.
+	rawURL := strings.ToLower(w.URL)
+
+	uri, err := url.Parse(rawURL)
+	if err != nil {
+		uri = &url.URL{}
+	}
+
+	host, path := uri.Hostname(), uri.Path
+	label, _, _ := strings.Cut(host, ".")
+
+	switch {
 	default:
-		fallthrough
-	case strings.Contains(url, "discordnotifier.com"), strings.Contains(url, "notifiarr.com"):
-		return template.Parse(WebhookTemplateNotifiarr)
+		return legacyTemplateName(rawURL)
+	case strings.Contains(rawURL, "discordnotifier.com"), strings.Contains(rawURL, "notifiarr.com"):
+		return "notifiarr"
 	case w.TmplPath != "":
-		s, err := os.ReadFile(w.TmplPath)
-		if err != nil {
-			return nil, fmt.Errorf("template file: %w", err)
+		return ""
+	case hostIs(host, "discord.com", "discordapp.com"):
+		return "discord"
+	case hostIs(host, "api.telegram.org"):
+		return "telegram"
+	case hostIs(host, "hooks.slack.com"):
+		return "slack"
+	case hostIs(host, "pushover.net"):
+		return "pushover"
+	case label == "gotify", path == "/message":
+		return "gotify"
+	case hostIs(host, "ntfy.sh"), label == "ntfy":
+		return "ntfy"
+	case strings.HasPrefix(path, "/_matrix/client/"):
+		return "matrix"
+	case hostIs(host, "webhook.office.com", "logic.azure.com", "powerplatform.com"):
+		return "teams"
+	case label == "apprise", strings.HasPrefix(path, "/notify/"):
+		return "apprise"
+	case strings.HasPrefix(path, "/api/webhook/"):
+		return "homeassistant"
+	}
+}
+
+// legacyTemplates are the templates that were picked by a name anywhere in the url, like a gotify
+// server behind a proxy at https://proxy.example.com/gotify/message. They still are, when nothing
+// more specific matches, so existing configs keep their template.
+var legacyTemplates = []struct{ match, name string }{ //nolint:gochecknoglobals
+	{"discord.com", "discord"},
+	{"discordapp.com", "discord"},
+	{"api.telegram.org", "telegram"},
+	{"hooks.slack.com", "slack"},
+	{"pushover.net", "pushover"},
+	{"gotify", "gotify"},
+}
+
+// legacyTemplateName returns the template for a url that contains a legacy template's name, or notifiarr.
+func legacyTemplateName(rawURL string) string {
+	for _, legacy := range legacyTemplates {
+		if strings.Contains(rawURL, legacy.match) {
+			return legacy.name
 		}
+	}
 
-		return template.Parse(string(s))
-	case strings.Contains(url, "discord.com"), strings.Contains(url, "discordapp.com"):
-		return template.Parse(WebhookTemplateDiscord)
-	case strings.Contains(url, "api.telegram.org"):
-		return template.Parse(WebhookTemplateTelegram)
-	case strings.Contains(url, "hooks.slack.com"):
-		return template.Parse(WebhookTemplateSlack)
-	case strings.Contains(url, "pushover.net"):
-		return template.Parse(WebhookTemplatePushover)
-	case strings.Contains(url, "gotify"):
-		return template.Parse(WebhookTemplateGotify)
+	return "notifiarr"
+}
+
+// hostIs returns true if a host name is one of the domains, or a subdomain of one.
+func hostIs(host string, domains ...string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
 	}
+
+	return false
 }
 
 func separator(separator string) func() string {
@@ -323,7 +613,7 @@ func separator(separator string) func() string {
 	}
 }
 
-func humanbytes(size int64) string {
+func humanbytes(size uint64) string {
 	const byteUnit = 1024
 
 	// This is from https://yourbasic.org/golang/formatting-byte-size-to-human-readable-format/
@@ -332,7 +622,7 @@ func humanbytes(size int64) string {
 		return fmt.Sprintf("%dB", size)
 	}
 
-	div, exp := int64(byteUnit), 0
+	div, exp := uint64(byteUnit), 0
 
 	for n := size / byteUnit; n >= byteUnit; n /= byteUnit {
 		div *= byteUnit
@@ -0,0 +1,139 @@
+package unpackerr
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golift.io/cnfg"
+)
+
+//nolint:gochecknoglobals // go test -run TestWebhookTemplates ./pkg/unpackerr -update
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// goldenPayload is samplePayload() with every value that changes between runs replaced.
+func goldenPayload(event ExtractStatus) *WebhookPayload {
+	started := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	payload := samplePayload()
+	payload.Event = event
+	payload.IDs["downloadId"] = "some-id-goes-here"
+	payload.Time = started.Add(time.Minute)
+	payload.Go, payload.OS, payload.Arch = "go1.22.0", "linux", "amd64"
+	payload.Version, payload.Revision, payload.Branch, payload.Started = "0.14.5", "abc1234", "main", started
+	payload.Data.Start = started
+	payload.Data.Elapsed = cnfg.Duration{Duration: 83 * time.Second}
+	payload.Data.Bytes = 1234567009
+
+	return payload
+}
+
+func TestWebhookTemplateName(t *testing.T) {
+	t.Parallel()
+
+	for url, name := range map[string]string{
+		"https://discord.com/api/webhooks/1/token":              "discord",
+		"https://gotify.example.com/message?token=x":            "gotify",
+		"http://10.0.0.5:8080/message?token=x":                  "gotify",
+		"https://ntfy.example.com/downloads":                    "ntfy",
+		"https://example.com/ntfy-apprise/hook":                 "notifiarr",
+		"https://proxy.example.com/gotify/message?token=x":      "gotify",
+		"https://my-discord.com.example.org/api/webhook/x":      "homeassistant",
+		"https://discord.example.com/api/webhooks/1/token":      "notifiarr",
+		"https://proxy.example.com/hooks.slack.com/services/x":  "slack",
+		"http://apprise:8000/notify/unpackerr":                  "apprise",
+		"https://hooks.example.com/matrix?server=ntfy.sh&x=y":   "notifiarr",
+		"https://chat.example.com/_matrix/client/v3/rooms/!r:x": "matrix",
+	} {
+		if detected := (&WebhookConfig{URL: url}).templateName(); detected != name {
+			t.Fatalf("%s: expected the %s template, got: %s", url, name, detected)
+		}
+	}
+
+	// An explicit template wins over the url.
+	if name := (&WebhookConfig{URL: "https://discord.com/api", TempName: "Slack"}).templateName(); name != "slack" {
+		t.Fatalf("expected the explicit template, got: %s", name)
+	}
+}
+
+func TestWebhookTemplates(t *testing.T) {
+	t.Parallel()
+
+	templates := map[string]string{
+		"ntfy":          "https://ntfy.sh/",
+		"matrix":        "https://matrix.org/_matrix/client/v3/rooms/!room:matrix.org/send/m.room.message/{{.Time}}",
+		"teams":         "https://prod-00.westus.logic.azure.com/workflows/id/triggers/manual/paths/invoke",
+		"apprise":       "http://apprise:8000/notify/unpackerr",
+		"homeassistant": "http://homeassistant.local:8123/api/webhook/unpackerr",
+	}
+
+	for name, url := range templates {
+		byName, err := (&WebhookConfig{TempName: name, Channel: "downloads"}).Template()
+		if err != nil {
+			t.Fatalf("%s: parsing template: %v", name, err)
+		}
+
+		byURL, err := (&WebhookConfig{URL: url, Channel: "downloads"}).Template()
+		if err != nil {
+			t.Fatalf("%s: parsing template: %v", name, err)
+		}
+
+		var golden, detected bytes.Buffer
+		if err := byName.Execute(&golden, goldenPayload(EXTRACTED)); err != nil {
+			t.Fatalf("%s: executing template: %v", name, err)
+		}
+
+		if err := byURL.Execute(&detected, goldenPayload(EXTRACTED)); err != nil {
+			t.Fatalf("%s: executing template: %v", name, err)
+		}
+
+		if golden.String() != detected.String() {
+			t.Fatalf("%s: the template was not detected from the url: %s", name, url)
+		}
+
+		checkGolden(t, filepath.Join("testdata", "webhooks", name+".golden"), golden.Bytes())
+
+		// Every event must produce valid json, with and without extraction data.
+		for event := QUEUED; event <= EXTRACTSKIPPED; event++ {
+			payload := goldenPayload(event)
+			if event == QUEUED {
+				payload.Data = nil
+			}
+
+			var body bytes.Buffer
+			if err := byName.Execute(&body, payload); err != nil {
+				t.Fatalf("%s: %s: executing template: %v", name, event, err)
+			}
+
+			if !json.Valid(body.Bytes()) {
+				t.Fatalf("%s: %s: invalid json:\n%s", name, event, body.String())
+			}
+		}
+	}
+}
+
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), defaultDirMode); err != nil {
+			t.Fatalf("making testdata folder: %v", err)
+		}
+
+		if err := os.WriteFile(path, got, defaultFileMode); err != nil {
+			t.Fatalf("writing golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run go test with -update to create it): %v", err)
+	}
+
+	if !bytes.Equal(want, got) {
+		t.Fatalf("%s does not match (run go test with -update if this is expected):\n%s", path, got)
+	}
+}
@@ -1,11 +1,13 @@
 package unpackerr
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"net/netip"
 	"path"
 	"strings"
 
@@ -14,13 +16,18 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// apiKeyHeader is the request header that carries the web server's api_key.
+const apiKeyHeader = "X-API-Key"
+
 type WebServer struct {
 	Metrics    bool        `json:"metrics"     toml:"metrics"       xml:"metrics"       yaml:"metrics"`
 	Pprof      bool        `json:"pprof"       toml:"pprof"         xml:"pprof"         yaml:"pprof"`
 	LogFiles   int         `json:"logFiles"    toml:"log_files"     xml:"log_files"     yaml:"logFiles"`
 	LogFileMb  int         `json:"logFileMb"   toml:"log_file_mb"   xml:"log_file_mb"   yaml:"logFileMb"`
 	ListenAddr string      `json:"listenAddr"  toml:"listen_addr"   xml:"listen_addr"   yaml:"listenAddr"`
+	APIKey     string      `json:"apiKey"      toml:"api_key"       xml:"api_key"       yaml:"apiKey"`
 	LogFile    string      `json:"logFile"     toml:"log_file"      xml:"log_file"      yaml:"logFile"`
+	LogTarget  string      `json:"logTarget"   toml:"log_target"    xml:"log_target"    yaml:"logTarget"`
 	SSLCrtFile string      `json:"sslCertFile" toml:"ssl_cert_file" xml:"ssl_cert_file" yaml:"sslCertFile"`
 	SSLKeyFile string      `json:"sslKeyFile"  toml:"ssl_key_file"  xml:"ssl_key_file"  yaml:"sslKeyFile"`
 	URLBase    string      `json:"urlbase"     toml:"urlbase"       xml:"urlbase"       yaml:"urlbase"`
@@ -30,8 +37,10 @@ type WebServer struct {
 	server     *http.Server
 }
 
+// Enabled returns true if the web server has a listen address. The health and API endpoints are
+// always served then; /metrics is only served when metrics are enabled.
 func (w *WebServer) Enabled() bool {
-	return w != nil && w.Metrics && w.ListenAddr != ""
+	return w != nil && w.ListenAddr != ""
 }
 
 func (u *Unpackerr) logWebserver() {
@@ -50,8 +59,12 @@ func (u *Unpackerr) logWebserver() {
 		ssl = "s"
 	}
 
-	u.Printf(" => Starting webserver. Listen address: http%s://%v%s (%d upstreams)",
-		ssl, addr, u.Webserver.URLBase, len(u.Webserver.Upstreams))
+	u.Printf(" => Starting webserver. Listen address: http%s://%v%s (%d upstreams, metrics: %v, api key: %v)",
+		ssl, addr, u.Webserver.URLBase, len(u.Webserver.Upstreams), u.Webserver.Metrics, u.Webserver.APIKey != "")
+
+	if u.Webserver.LogTarget != "" {
+		u.Printf(" => Webserver Log Target: %s", u.Webserver.LogTarget)
+	}
 }
 
 func (u *Unpackerr) startWebServer() {
@@ -90,6 +103,13 @@ func (u *Unpackerr) startWebServer() {
 
 func (u *Unpackerr) webRoutes() {
 	u.Webserver.router.GET(path.Join(u.Webserver.URLBase, "/"), Index)
+	u.Webserver.router.GET(path.Join(u.Webserver.URLBase, "/api/v1/health"), u.handleHealth)
+	// These expose file paths, hook output and log levels, so they require the api key.
+	u.Webserver.router.GET(path.Join(u.Webserver.URLBase, "/api/v1/queue/*name"), u.apiAuth(u.handleItemLog))
+	u.Webserver.router.GET(path.Join(u.Webserver.URLBase, "/api/v1/history"), u.apiAuth(u.handleHistory))
+	u.Webserver.router.GET(path.Join(u.Webserver.URLBase, "/api/v1/loglevel"), u.apiAuth(u.handleLogLevel))
+	u.Webserver.router.PUT(path.Join(u.Webserver.URLBase, "/api/v1/loglevel"), u.apiAuth(u.handleLogLevel))
+	u.Webserver.router.POST(path.Join(u.Webserver.URLBase, "/api/v1/loglevel"), u.apiAuth(u.handleLogLevel))
 
 	if u.Webserver.Pprof {
 		u.registerPprof()
@@ -109,6 +129,30 @@ func (u *Unpackerr) webRoutes() {
 	}
 }
 
+// apiAuth wraps an API handler. Requests must carry the api_key in the X-API-Key header.
+// Without an api_key, only requests from the local host are allowed. A trusted upstream proxy
+// forwards requests from anywhere, so its requests are never local, even on the same host.
+func (u *Unpackerr) apiAuth(handle httprouter.Handle) httprouter.Handle {
+	return func(resp http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		switch key := req.Header.Get(apiKeyHeader); {
+		case u.Webserver.APIKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(u.Webserver.APIKey)) == 1:
+			handle(resp, req, params)
+		case u.Webserver.APIKey != "":
+			http.Error(resp, "missing or invalid "+apiKeyHeader+" header", http.StatusUnauthorized)
+		case isLoopback(req.RemoteAddr) && !u.Webserver.allow.Contains(req.RemoteAddr):
+			handle(resp, req, params)
+		default:
+			http.Error(resp, "set webserver api_key to use the API from other hosts", http.StatusForbidden)
+		}
+	}
+}
+
+// isLoopback returns true if a request's remote address is the local host.
+func isLoopback(remoteAddr string) bool {
+	addr, err := netip.ParseAddrPort(remoteAddr)
+	return err == nil && addr.Addr().Unmap().IsLoopback()
+}
+
 // registerPprof adds Go's built-in pprof handlers for runtime profiling.
 // Access heap profiles at /debug/pprof/heap, goroutine dumps at /debug/pprof/goroutine, etc.
 func (u *Unpackerr) registerPprof() {
@@ -159,6 +203,8 @@ func (u *Unpackerr) fixForwardedFor(next http.Handler) http.Handler {
 			r.Header.Set("X-Forwarded-For", strings.Trim(x[l:len(x)-1], ", "))
 		}
 
+		u.debugf(logHTTP, "[HTTP] %s %s from %s (client: %s, agent: %s)",
+			r.Method, r.URL, r.RemoteAddr, r.Header.Get("X-Forwarded-For"), r.UserAgent())
 		next.ServeHTTP(w, r)
 	})
 }
@@ -49,14 +49,16 @@ func (u *Unpackerr) logWhisparr() {
 		u.Printf(" => Whisparr Config: 1 server: "+starrLogLine,
 			u.Whisparr[0].URL, u.Whisparr[0].APIKey != "", u.Whisparr[0].Timeout,
 			u.Whisparr[0].ValidSSL, u.Whisparr[0].Protocols, u.Whisparr[0].Syncthing,
-			u.Whisparr[0].DeleteOrig, u.Whisparr[0].DeleteDelay.Duration, u.Whisparr[0].Paths)
+			u.Whisparr[0].DeleteOrig, u.Whisparr[0].DeleteDelay.Duration, u.Whisparr[0].Paths,
+			u.Whisparr[0].MinSize, u.Whisparr[0].MaxSize, u.Whisparr[0].Atomic, u.Whisparr[0].ExtractPath)
 	} else if count != 0 {
 		u.Printf(" => Whisparr Config: %d servers", count)
 
 		for _, f := range u.Whisparr {
 			u.Printf(starrLogPfx+starrLogLine,
 				f.URL, f.APIKey != "", f.Timeout, f.ValidSSL, f.Protocols,
-				f.Syncthing, f.DeleteOrig, f.DeleteDelay.Duration, f.Paths)
+				f.Syncthing, f.DeleteOrig, f.DeleteDelay.Duration, f.Paths,
+				f.MinSize, f.MaxSize, f.Atomic, f.ExtractPath)
 		}
 	}
 }
@@ -64,7 +66,7 @@ func (u *Unpackerr) logWhisparr() {
 // getWhisparrQueue saves the Whisparr Queue(s).
 func (u *Unpackerr) getWhisparrQueue(server *RadarrConfig, start time.Time) {
 	if server.APIKey == "" {
-		u.Debugf("Whisparr (%s): skipped, no API key", server.URL)
+		u.debugf(logPoller, "Whisparr (%s): skipped, no API key", server.URL)
 		return
 	}
 
@@ -94,7 +96,8 @@ func (u *Unpackerr) checkWhisparrQueue(now time.Time) {
 		for _, record := range server.Queue.Records {
 			switch x, ok := u.Map[record.Title]; {
 			case ok && x.Status == EXTRACTED && u.isComplete(record.Status, record.Protocol, server.Protocols):
-				u.Debugf("%s (%s): Item Waiting for Import (%s): %v", starr.Whisparr, server.URL, record.Protocol, record.Title)
+				u.debugf(logPoller, "%s (%s): Item Waiting for Import (%s): %v",
+					starr.Whisparr, server.URL, record.Protocol, record.Title)
 			case !ok && u.isComplete(record.Status, record.Protocol, server.Protocols):
 				u.Map[record.Title] = &Extract{
 					App:         starr.Whisparr,
@@ -103,6 +106,12 @@ func (u *Unpackerr) checkWhisparrQueue(now time.Time) {
 					Status:      WAITING,
 					DeleteOrig:  server.DeleteOrig,
 					DeleteDelay: server.DeleteDelay.Duration,
+					MinSize:     server.MinSize,
+					MaxSize:     server.MaxSize,
+					ExtractPath: server.ExtractPath,
+					Atomic:      server.Atomic,
+					Report:      server.Report,
+					passwords:   server.passwords,
 					Path:        u.getDownloadPath(record.OutputPath, starr.Whisparr, record.Title, server.Paths),
 					IDs: map[string]any{
 						"downloadId": record.DownloadID,
@@ -112,10 +121,11 @@ func (u *Unpackerr) checkWhisparrQueue(now time.Time) {
 					},
 				}
 				u.Map[record.Title].XProg = &ExtractProgress{Extract: u.Map[record.Title]}
+				u.traceItem(record.Title, u.Map[record.Title], now)
 
 				fallthrough
 			default:
-				u.Debugf("%s: (%s): %s (%s:%d%%): %v",
+				u.debugf(logPoller, "%s: (%s): %s (%s:%d%%): %v",
 					starr.Whisparr, server.URL, record.Status, record.Protocol,
 					percent(record.Sizeleft, record.Size), record.Title)
 			}